@@ -12,3 +12,22 @@ type Action struct {
 	// Params contains additional values related to the action
 	Params json.RawMessage
 }
+
+// Undoer is an optional interface that game drivers can implement to undo
+// their last executed action. Games of drivers which don't implement it are rebuilt
+// replaying all their actions but the last one, which is only possible if the
+// outcome of actions doesn't depend on chance. Drivers of games with random elements,
+// like Acquire drawing tiles, must implement it for their players to be able to undo
+// actions, as undo proposals fail with an undo_unsupported error otherwise.
+type Undoer interface {
+	// Undo puts the game back in the state it had before the last executed action
+	Undo() error
+}
+
+// ActionTyper is an optional interface that game drivers can implement to declare
+// the types of the actions they accept, so messages of any other type are rejected
+// before reaching them
+type ActionTyper interface {
+	// ActionTypes returns the types of all actions accepted by the driver
+	ActionTypes() []string
+}
@@ -8,6 +8,8 @@ type AI interface {
 	// FeedGameStatus updates the AI client with the current status of the game
 	FeedGameStatus(json.RawMessage) error
 
-	// Play makes the AI choose an action, returning it
+	// Play makes the AI choose an action, returning it.
+	// An action with an empty type means there is nothing to play, and the bot
+	// is taken out of the game.
 	Play() Action
 }
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+// LegalActionLister is an optional interface that game drivers can implement
+// to tell clients and bots which actions a player can currently do
+type LegalActionLister interface {
+	// LegalActions returns the actions the player with the passed number can do,
+	// with the parameters of each possible choice, or none if he/she is not in turn
+	LegalActions(playerNumber int) ([]Action, error)
+}
+
+// LegalActionsFeeder is an optional interface that AIs can implement to be told
+// the legal actions of their player before being asked to play
+type LegalActionsFeeder interface {
+	// FeedLegalActions updates the AI with the actions it can currently do
+	FeedLegalActions(actions []Action)
+}
+
+// RandomAI is an AI which plays a random legal action, so drivers implementing
+// LegalActionLister can offer a bot level without any game specific logic
+type RandomAI struct {
+	actions []Action
+	rn      *rand.Rand
+}
+
+// NewRandomAI returns a new RandomAI instance
+func NewRandomAI() *RandomAI {
+	return &RandomAI{
+		rn: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// FeedGameStatus does nothing, as RandomAI only takes legal actions into account
+func (a *RandomAI) FeedGameStatus(json.RawMessage) error {
+	return nil
+}
+
+// FeedLegalActions stores the actions the AI can currently do
+func (a *RandomAI) FeedLegalActions(actions []Action) {
+	a.actions = actions
+}
+
+// Play returns one of the legal actions chosen at random,
+// or an empty action, which takes the bot out of the game, if there are none
+func (a *RandomAI) Play() Action {
+	if len(a.actions) == 0 {
+		return Action{}
+	}
+	return a.actions[a.rn.Intn(len(a.actions))]
+}
@@ -0,0 +1,20 @@
+package api
+
+// Standing holds the final position of a player in a finished game
+type Standing struct {
+	PlayerNumber int
+
+	// Rank is the final position of the player, 1 being the winner.
+	// Tied players share the same rank.
+	Rank int
+
+	// Score is the final score of the player, its meaning depends on the game
+	Score int
+}
+
+// ResultsReporter is an optional interface that game drivers can implement
+// to report the final standings of a game once it is over
+type ResultsReporter interface {
+	// FinalStandings returns the final standings of the game
+	FinalStandings() ([]Standing, error)
+}
@@ -0,0 +1,8 @@
+package api
+
+// Snapshotter is an optional interface that game drivers can implement
+// to have the state of their running games persisted when the server shuts down
+type Snapshotter interface {
+	// Snapshot returns the serialized state of the game, from which it could be restored
+	Snapshot() ([]byte, error)
+}
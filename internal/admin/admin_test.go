@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/svera/sackson-server/internal/ban"
+	"github.com/svera/sackson-server/internal/hub"
+)
+
+type fakeOperator struct {
+	destroyed   map[string]string
+	kicked      []string
+	messages    map[string]string
+	notices     []string
+	maintenance bool
+	bans        *ban.List
+}
+
+func newFakeOperator() *fakeOperator {
+	return &fakeOperator{destroyed: map[string]string{}, messages: map[string]string{}, bans: ban.NewList()}
+}
+
+func (f *fakeOperator) RoomsInfo() []hub.RoomInfo {
+	return []hub.RoomInfo{{ID: "VWXYZ", Driver: "acquire", Owner: "Sergio", Players: []string{"Sergio"}}}
+}
+
+func (f *fakeOperator) ClientsInfo() map[string][]hub.ClientInfo {
+	return map[string][]hub.ClientInfo{"acquire": {{SessionID: "abc", Name: "Sergio"}}}
+}
+
+func (f *fakeOperator) DestroyRoom(id string, reason string) error {
+	if id != "VWXYZ" {
+		return errors.New(hub.InexistentRoom)
+	}
+	f.destroyed[id] = reason
+	return nil
+}
+
+func (f *fakeOperator) KickClient(sessionID string) error {
+	if sessionID != "abc" {
+		return errors.New(hub.InexistentClient)
+	}
+	f.kicked = append(f.kicked, sessionID)
+	return nil
+}
+
+func (f *fakeOperator) MessageClient(sessionID string, text string) error {
+	if sessionID != "abc" {
+		return errors.New(hub.InexistentClient)
+	}
+	f.messages[sessionID] = text
+	return nil
+}
+
+func (f *fakeOperator) Broadcast(text string) {
+	f.notices = append(f.notices, text)
+}
+
+func (f *fakeOperator) Maintenance() bool {
+	return f.maintenance
+}
+
+func (f *fakeOperator) SetMaintenance(enabled bool) {
+	f.maintenance = enabled
+}
+
+func (f *fakeOperator) BanEntries() []ban.Entry {
+	return f.bans.Entries()
+}
+
+func (f *fakeOperator) Ban(e ban.Entry) error {
+	return f.bans.Add(e)
+}
+
+func (f *fakeOperator) Unban(kind string, value string) error {
+	return f.bans.Remove(kind, value)
+}
+
+func (f *fakeOperator) BanClient(sessionID string, reason string) error {
+	if sessionID != "abc" {
+		return errors.New(hub.InexistentClient)
+	}
+	return f.bans.BanClient("", sessionID, "", reason)
+}
+
+func request(op Operator, method string, url string, body string, token string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	RegisterRoutes(r, op, "secret")
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestsWithoutValidTokenAreRejected(t *testing.T) {
+	op := newFakeOperator()
+	for _, token := range []string{"", "wrong"} {
+		if w := request(op, http.MethodGet, "/admin/rooms", "", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("Request with token '%s' must be rejected with status %d, got %d", token, http.StatusUnauthorized, w.Code)
+		}
+	}
+}
+
+func TestListRooms(t *testing.T) {
+	var rooms []hub.RoomInfo
+
+	w := request(newFakeOperator(), http.MethodGet, "/admin/rooms", "", "secret")
+	if err := json.NewDecoder(w.Body).Decode(&rooms); err != nil || len(rooms) != 1 || rooms[0].ID != "VWXYZ" {
+		t.Errorf("Rooms list must contain room VWXYZ, got %s", w.Body.String())
+	}
+}
+
+func TestDestroyRoom(t *testing.T) {
+	op := newFakeOperator()
+
+	if w := request(op, http.MethodDelete, "/admin/rooms/VWXYZ?reason=ter", "", "secret"); w.Code != http.StatusNoContent {
+		t.Errorf("Destroying an existing room must return status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if op.destroyed["VWXYZ"] != "ter" {
+		t.Errorf("Room must be destroyed with the passed reason, got '%s'", op.destroyed["VWXYZ"])
+	}
+	if w := request(op, http.MethodDelete, "/admin/rooms/ABCDE", "", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Destroying an inexistent room must return status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestKickAndMessageClient(t *testing.T) {
+	op := newFakeOperator()
+
+	request(op, http.MethodPost, "/admin/clients/abc/message", `{"message": "Hello"}`, "secret")
+	if op.messages["abc"] != "Hello" {
+		t.Errorf("Client must receive the message, got '%s'", op.messages["abc"])
+	}
+	request(op, http.MethodPost, "/admin/clients/abc/kick", "", "secret")
+	if len(op.kicked) != 1 {
+		t.Errorf("Client must be kicked")
+	}
+	if w := request(op, http.MethodPost, "/admin/clients/abc/message", `{}`, "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Empty messages must be rejected with status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestBroadcastNotice(t *testing.T) {
+	op := newFakeOperator()
+
+	request(op, http.MethodPost, "/admin/notice", `{"message": "Restarting soon"}`, "secret")
+	if len(op.notices) != 1 || op.notices[0] != "Restarting soon" {
+		t.Errorf("Notice must be broadcast, got %v", op.notices)
+	}
+}
+
+func TestToggleMaintenance(t *testing.T) {
+	op := newFakeOperator()
+
+	request(op, http.MethodPut, "/admin/maintenance", `{"enabled": true}`, "secret")
+	if !op.maintenance {
+		t.Errorf("Maintenance mode must be enabled")
+	}
+	w := request(op, http.MethodGet, "/admin/maintenance", "", "secret")
+	if strings.TrimSpace(w.Body.String()) != `{"enabled":true}` {
+		t.Errorf("Maintenance mode must be reported as enabled, got %s", w.Body.String())
+	}
+	if w := request(op, http.MethodPut, "/admin/maintenance", `nope`, "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Invalid bodies must be rejected with status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestManageBans(t *testing.T) {
+	op := newFakeOperator()
+
+	if w := request(op, http.MethodPost, "/admin/bans", `{"kind": "address", "value": "10.0.0.1"}`, "secret"); w.Code != http.StatusNoContent {
+		t.Errorf("Banning an address must return status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := request(op, http.MethodPost, "/admin/bans", `{"kind": "name", "value": "Sergio"}`, "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Invalid bans must be rejected with status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	request(op, http.MethodPost, "/admin/clients/abc/ban?reason=flooding", "", "secret")
+
+	var entries []ban.Entry
+	w := request(op, http.MethodGet, "/admin/bans", "", "secret")
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil || len(entries) != 2 || entries[1].Reason != "flooding" {
+		t.Fatalf("Bans list must contain the banned address and session, got %s", w.Body.String())
+	}
+	if w := request(op, http.MethodDelete, "/admin/bans/address/10.0.0.1", "", "secret"); w.Code != http.StatusNoContent {
+		t.Errorf("Lifting a ban must return status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := request(op, http.MethodDelete, "/admin/bans/address/10.0.0.1", "", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Lifting an inexistent ban must return status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,208 @@
+// Package admin implements an HTTP API which lets server operators inspect
+// and act on the rooms and clients of a running server.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/svera/sackson-server/internal/ban"
+	"github.com/svera/sackson-server/internal/httpjson"
+	"github.com/svera/sackson-server/internal/hub"
+)
+
+// Operator defines the operations available through the admin API,
+// implemented by the hub
+type Operator interface {
+	RoomsInfo() []hub.RoomInfo
+	ClientsInfo() map[string][]hub.ClientInfo
+	DestroyRoom(id string, reason string) error
+	KickClient(sessionID string) error
+	MessageClient(sessionID string, text string) error
+	Broadcast(text string)
+	Maintenance() bool
+	SetMaintenance(enabled bool)
+	BanEntries() []ban.Entry
+	Ban(e ban.Entry) error
+	Unban(kind string, value string) error
+	BanClient(sessionID string, reason string) error
+}
+
+type notice struct {
+	Message string `json:"message"`
+}
+
+type maintenance struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RegisterRoutes adds the admin endpoints to the passed router, all of them requiring
+// the passed token to be sent in an "Authorization: Bearer" header:
+//   GET /admin/rooms lists all rooms
+//   DELETE /admin/rooms/{id}?reason=adm destroys a room, sending the passed reason to its clients
+//   GET /admin/clients lists connected clients by game
+//   POST /admin/clients/{session}/kick expels a client from his/her room and disconnects him/her
+//   POST /admin/clients/{session}/ban?reason=flooding bans the user, session and address of a client and disconnects him/her
+//   POST /admin/clients/{session}/message sends a notice to a client, e.g. {"message": "Hello"}
+//   POST /admin/notice sends a notice to all connected clients, e.g. {"message": "Restarting soon"}
+//   GET /admin/maintenance tells whether maintenance mode is enabled
+//   PUT /admin/maintenance enables or disables maintenance mode, e.g. {"enabled": true}
+//   GET /admin/bans lists the users, sessions and addresses banned from the server
+//   POST /admin/bans bans an identifier, e.g. {"kind": "address", "value": "10.0.0.1", "reason": "flooding"}
+//   DELETE /admin/bans/{kind}/{value} lifts a ban
+func RegisterRoutes(r *mux.Router, op Operator, token string) {
+	s := r.PathPrefix("/admin").Subrouter()
+	s.HandleFunc("/rooms", authorized(token, roomsHandler(op))).Methods(http.MethodGet)
+	s.HandleFunc("/rooms/{id}", authorized(token, destroyRoomHandler(op))).Methods(http.MethodDelete)
+	s.HandleFunc("/clients", authorized(token, clientsHandler(op))).Methods(http.MethodGet)
+	s.HandleFunc("/clients/{session}/kick", authorized(token, kickClientHandler(op))).Methods(http.MethodPost)
+	s.HandleFunc("/clients/{session}/ban", authorized(token, banClientHandler(op))).Methods(http.MethodPost)
+	s.HandleFunc("/clients/{session}/message", authorized(token, messageClientHandler(op))).Methods(http.MethodPost)
+	s.HandleFunc("/notice", authorized(token, noticeHandler(op))).Methods(http.MethodPost)
+	s.HandleFunc("/maintenance", authorized(token, maintenanceHandler(op))).Methods(http.MethodGet)
+	s.HandleFunc("/maintenance", authorized(token, setMaintenanceHandler(op))).Methods(http.MethodPut)
+	s.HandleFunc("/bans", authorized(token, bansHandler(op))).Methods(http.MethodGet)
+	s.HandleFunc("/bans", authorized(token, banHandler(op))).Methods(http.MethodPost)
+	s.HandleFunc("/bans/{kind}/{value}", authorized(token, unbanHandler(op))).Methods(http.MethodDelete)
+}
+
+// authorized rejects requests without the admin token, comparing it in constant time
+func authorized(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		sent := strings.TrimPrefix(header, "Bearer ")
+		if token == "" || sent == header || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func roomsHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpjson.Write(w, op.RoomsInfo())
+	}
+}
+
+func clientsHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpjson.Write(w, op.ClientsInfo())
+	}
+}
+
+func destroyRoomHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := op.DestroyRoom(mux.Vars(r)["id"], r.FormValue("reason")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func kickClientHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := op.KickClient(mux.Vars(r)["session"]); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func banClientHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := op.BanClient(mux.Vars(r)["session"], r.FormValue("reason")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func messageClientHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, ok := decodeNotice(w, r)
+		if !ok {
+			return
+		}
+		if err := op.MessageClient(mux.Vars(r)["session"], n.Message); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func noticeHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, ok := decodeNotice(w, r)
+		if !ok {
+			return
+		}
+		op.Broadcast(n.Message)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func maintenanceHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpjson.Write(w, maintenance{Enabled: op.Maintenance()})
+	}
+}
+
+func setMaintenanceHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var m maintenance
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, "Invalid maintenance mode", http.StatusBadRequest)
+			return
+		}
+		op.SetMaintenance(m.Enabled)
+		httpjson.Write(w, m)
+	}
+}
+
+func bansHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpjson.Write(w, op.BanEntries())
+	}
+}
+
+func banHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var e ban.Entry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, "Invalid ban", http.StatusBadRequest)
+			return
+		}
+		if err := op.Ban(e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func unbanHandler(op Operator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := op.Unban(mux.Vars(r)["kind"], mux.Vars(r)["value"]); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeNotice(w http.ResponseWriter, r *http.Request) (notice, bool) {
+	var n notice
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil || strings.TrimSpace(n.Message) == "" {
+		http.Error(w, "Invalid message", http.StatusBadRequest)
+		return n, false
+	}
+	return n, true
+}
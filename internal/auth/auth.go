@@ -0,0 +1,40 @@
+// Package auth authenticates the users who connect to the server,
+// validating the token they send when opening the websocket connection.
+// Authenticators are pluggable, this package providing one which validates
+// JSON Web Tokens signed with HMAC-SHA256.
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Error messages returned from authenticators
+const (
+	MissingToken = "missing_token"
+	InvalidToken = "invalid_token"
+	ExpiredToken = "expired_token"
+)
+
+// User holds the identity of an authenticated user
+type User struct {
+	// ID is a stable identifier of the user, which doesn't change between connections
+	ID string
+	// Name is the name shown to other players
+	Name string
+}
+
+// Authenticator defines the methods an authentication method must implement
+type Authenticator interface {
+	Authenticate(token string) (User, error)
+}
+
+// TokenFromRequest returns the bearer token sent in the Authorization header of the request,
+// or in its "token" parameter, as browsers cannot set headers when opening websockets
+func TokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return r.FormValue("token")
+}
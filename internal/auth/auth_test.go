@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestJWT(now time.Time) *JWT {
+	j := NewJWT("secret")
+	j.now = func() time.Time {
+		return now
+	}
+	return j
+}
+
+func TestJWTAuthenticatesSignedToken(t *testing.T) {
+	j := newTestJWT(time.Unix(1000, 0))
+	token, _ := j.Sign(Claims{Subject: "42", Name: "Sergio", ExpiresAt: 2000})
+
+	user, err := j.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Authenticate must not return an error for a valid token, got %s", err.Error())
+	}
+	if user.ID != "42" || user.Name != "Sergio" {
+		t.Errorf("Authenticate must return user 42 named Sergio, got %+v", user)
+	}
+}
+
+func TestJWTUsesSubjectIfNoName(t *testing.T) {
+	j := newTestJWT(time.Unix(1000, 0))
+	token, _ := j.Sign(Claims{Subject: "42"})
+
+	if user, _ := j.Authenticate(token); user.Name != "42" {
+		t.Errorf("Authenticate must use the subject as name if there's none, got '%s'", user.Name)
+	}
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	j := newTestJWT(time.Unix(1000, 0))
+	valid, _ := j.Sign(Claims{Subject: "42"})
+	otherSecret, _ := NewJWT("other").Sign(Claims{Subject: "42"})
+	expired, _ := j.Sign(Claims{Subject: "42", ExpiresAt: 1000})
+	notYetValid, _ := j.Sign(Claims{Subject: "42", NotBefore: 1001})
+	noSubject, _ := j.Sign(Claims{Name: "Sergio"})
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+
+	tests := map[string]string{
+		"":          MissingToken,
+		"abc":       InvalidToken,
+		otherSecret: InvalidToken,
+		tampered:    InvalidToken,
+		expired:     ExpiredToken,
+		notYetValid: InvalidToken,
+		noSubject:   InvalidToken,
+	}
+	for token, expected := range tests {
+		if _, err := j.Authenticate(token); err == nil || err.Error() != expected {
+			t.Errorf("Authenticate must return error '%s' for token '%s', got %v", expected, token, err)
+		}
+	}
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?g=acquire&token=fromquery", nil)
+	if token := TokenFromRequest(r); token != "fromquery" {
+		t.Errorf("Token must be taken from the query if there's no Authorization header, got '%s'", token)
+	}
+
+	r.Header.Set("Authorization", "Bearer fromheader")
+	if token := TokenFromRequest(r); token != "fromheader" {
+		t.Errorf("Token must be taken from the Authorization header, got '%s'", token)
+	}
+}
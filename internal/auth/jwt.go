@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Claims holds the JSON Web Token claims used to identify users
+type Claims struct {
+	// Subject is the user ID
+	Subject string `json:"sub"`
+	// Name is the display name of the user, the subject is used if empty
+	Name string `json:"name,omitempty"`
+	// ExpiresAt is the Unix time after which the token is not valid anymore, 0 if it doesn't expire
+	ExpiresAt int64 `json:"exp,omitempty"`
+	// NotBefore is the Unix time before which the token is not valid yet
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// JWT authenticates users with JSON Web Tokens signed with HMAC-SHA256 (HS256)
+// using a secret shared with the issuer of the tokens
+type JWT struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewJWT returns a new JWT instance which validates tokens signed with secret
+func NewJWT(secret string) *JWT {
+	return &JWT{
+		secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// Authenticate validates the token's signature and time claims, returning the user it identifies
+func (j *JWT) Authenticate(token string) (User, error) {
+	var h header
+	var c Claims
+
+	if token == "" {
+		return User{}, errors.New(MissingToken)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return User{}, errors.New(InvalidToken)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, j.sign(parts[0]+"."+parts[1])) {
+		return User{}, errors.New(InvalidToken)
+	}
+	if err = decodeSegment(parts[0], &h); err != nil || h.Algorithm != "HS256" {
+		return User{}, errors.New(InvalidToken)
+	}
+	if err = decodeSegment(parts[1], &c); err != nil || c.Subject == "" {
+		return User{}, errors.New(InvalidToken)
+	}
+
+	now := j.now().Unix()
+	if c.ExpiresAt > 0 && now >= c.ExpiresAt {
+		return User{}, errors.New(ExpiredToken)
+	}
+	if c.NotBefore > 0 && now < c.NotBefore {
+		return User{}, errors.New(InvalidToken)
+	}
+
+	if c.Name == "" {
+		c.Name = c.Subject
+	}
+	return User{ID: c.Subject, Name: c.Name}, nil
+}
+
+// Sign returns a token with the passed claims, which can be used to issue tokens
+// for development and testing
+func (j *JWT) Sign(c Claims) (string, error) {
+	encodedHeader, err := encodeSegment(header{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeSegment(c)
+	if err != nil {
+		return "", err
+	}
+	unsigned := encodedHeader + "." + encodedClaims
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(j.sign(unsigned)), nil
+}
+
+func (j *JWT) sign(unsigned string) []byte {
+	mac := hmac.New(sha256.New, j.secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
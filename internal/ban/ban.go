@@ -0,0 +1,179 @@
+// Package ban keeps lists of the users, sessions and network addresses
+// which are not allowed to play, either in the whole server or in a room.
+package ban
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of banned identifiers
+const (
+	User    = "user"
+	Session = "session"
+	Address = "address"
+)
+
+// Errors returned from lists
+var (
+	ErrInvalidEntry = errors.New("ban: invalid entry")
+	ErrNotFound     = errors.New("ban: entry not found")
+)
+
+// Entry is a banned identifier
+type Entry struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// List holds banned identifiers. It is safe for concurrent use.
+type List struct {
+	mutex   sync.RWMutex
+	entries map[string]Entry
+	// path of the file where entries are written every time they change, empty to keep them only in memory
+	path string
+}
+
+// NewList returns an empty list kept in memory
+func NewList() *List {
+	return &List{entries: map[string]Entry{}}
+}
+
+// NewFileList returns a list which writes its entries to a JSON file every time
+// they change, loading the entries previously stored in it, if it exists
+func NewFileList(path string) (*List, error) {
+	l := NewList()
+	l.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []Entry
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	for _, e := range stored {
+		l.entries[key(e.Kind, e.Value)] = e
+	}
+	return l, nil
+}
+
+func key(kind, value string) string {
+	return kind + ":" + value
+}
+
+// Add bans the identifier of the passed entry, replacing any previous entry for it
+func (l *List) Add(e Entry) error {
+	if (e.Kind != User && e.Kind != Session && e.Kind != Address) || e.Value == "" {
+		return ErrInvalidEntry
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries[key(e.Kind, e.Value)] = e
+	return l.write()
+}
+
+// BanClient bans all the known identifiers of a client, ignoring empty ones
+func (l *List) BanClient(userID, sessionID, address, reason string) error {
+	identifiers := map[string]string{User: userID, Session: sessionID, Address: address}
+	for _, kind := range []string{User, Session, Address} {
+		if identifiers[kind] == "" {
+			continue
+		}
+		if err := l.Add(Entry{Kind: kind, Value: identifiers[kind], Reason: reason}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove lifts the ban on the passed identifier
+func (l *List) Remove(kind, value string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, ok := l.entries[key(kind, value)]; !ok {
+		return ErrNotFound
+	}
+	delete(l.entries, key(kind, value))
+	return l.write()
+}
+
+// Banned returns true if any of the passed identifiers is banned, ignoring empty ones
+func (l *List) Banned(userID, sessionID, address string) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, k := range []string{key(User, userID), key(Session, sessionID), key(Address, address)} {
+		if _, ok := l.entries[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns all banned identifiers, oldest first
+func (l *List) Entries() []Entry {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	entries := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		}
+		return key(entries[i].Kind, entries[i].Value) < key(entries[j].Kind, entries[j].Value)
+	})
+	return entries
+}
+
+// write replaces the list's file contents with the current entries, if it has one.
+// A temporary file is used so the stored entries are never left half written.
+func (l *List) write() error {
+	if l.path == "" {
+		return nil
+	}
+	stored := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		stored = append(stored, e)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
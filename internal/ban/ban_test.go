@@ -0,0 +1,55 @@
+package ban
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBanned(t *testing.T) {
+	l := NewList()
+	if err := l.BanClient("", "abc", "10.0.0.1", "Flooding"); err != nil {
+		t.Fatalf("Banning a client must not return an error, got %s", err.Error())
+	}
+
+	if !l.Banned("", "abc", "") || !l.Banned("user1", "", "10.0.0.1") {
+		t.Errorf("Clients with any banned identifier must be banned")
+	}
+	if l.Banned("", "", "") || l.Banned("user1", "def", "10.0.0.2") {
+		t.Errorf("Clients without banned identifiers must not be banned")
+	}
+	if len(l.Entries()) != 2 {
+		t.Errorf("Empty identifiers must not be banned, got %v", l.Entries())
+	}
+	if err := l.Add(Entry{Kind: "name", Value: "Sergio"}); err != ErrInvalidEntry {
+		t.Errorf("Unknown kinds must return error '%v', got %v", ErrInvalidEntry, err)
+	}
+}
+
+func TestFileListPersistsEntries(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ban")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bans.json")
+
+	l, err := NewFileList(path)
+	if err != nil {
+		t.Fatalf("Creating a list with no file must not return an error, got %s", err.Error())
+	}
+	l.Add(Entry{Kind: User, Value: "user1"})
+	l.Add(Entry{Kind: Address, Value: "10.0.0.1"})
+	if err = l.Remove(Address, "10.0.0.1"); err != nil {
+		t.Fatalf("Removing an entry must not return an error, got %s", err.Error())
+	}
+	if err = l.Remove(Address, "10.0.0.1"); err != ErrNotFound {
+		t.Errorf("Removing an inexistent entry must return error '%v', got %v", ErrNotFound, err)
+	}
+
+	reloaded, err := NewFileList(path)
+	if err != nil {
+		t.Fatalf("Loading a list must not return an error, got %s", err.Error())
+	}
+	if entries := reloaded.Entries(); len(entries) != 1 || !reloaded.Banned("user1", "", "") {
+		t.Errorf("Reloaded list must keep its entries, got %v", entries)
+	}
+}
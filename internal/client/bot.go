@@ -8,9 +8,13 @@ import (
 	"time"
 
 	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/codec"
 	"github.com/svera/sackson-server/internal/events"
 
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/metrics"
 )
 
 // BotClient is a struct that implements the client interface,
@@ -19,6 +23,7 @@ import (
 // connection
 type BotClient struct {
 	name          string
+	level         string
 	incoming      chan []byte // Channel storing incoming messages
 	endReadPump   chan struct{}
 	endWritePump  chan struct{}
@@ -29,11 +34,13 @@ type BotClient struct {
 	updatesBuffer map[int]json.RawMessage
 	game          string
 	observer      interfaces.Observer
+	log           *logger.Logger
 }
 
 // NewBot returns a new Bot instance
-func NewBot(ai api.AI, room interfaces.Room, ob interfaces.Observer) interfaces.Client {
+func NewBot(ai api.AI, level string, room interfaces.Room, ob interfaces.Observer, log *logger.Logger) interfaces.Client {
 	return &BotClient{
+		level:         level,
 		incoming:      make(chan []byte, maxMessageSize),
 		endReadPump:   make(chan struct{}),
 		endWritePump:  make(chan struct{}),
@@ -43,6 +50,7 @@ func NewBot(ai api.AI, room interfaces.Room, ob interfaces.Observer) interfaces.
 		expectedSeq:   1,
 		updatesBuffer: map[int]json.RawMessage{},
 		observer:      ob,
+		log:           log,
 	}
 }
 
@@ -52,8 +60,7 @@ func NewBot(ai api.AI, room interfaces.Room, ob interfaces.Observer) interfaces.
 func (c *BotClient) ReadPump(channel chan *interfaces.IncomingMessage, unregister chan interfaces.Client) {
 	defer func() {
 		if rc := recover(); rc != nil {
-			fmt.Printf("Panic in bot '%s': %s\n", c.Name(), rc)
-			debug.PrintStack()
+			c.log.Error("Panic in bot", "client", c.Name(), "error", rc, "stack", string(debug.Stack()))
 			c.observer.Trigger(events.BotPanicked{Client: c})
 		} else {
 			unregister <- c
@@ -66,12 +73,21 @@ func (c *BotClient) ReadPump(channel chan *interfaces.IncomingMessage, unregiste
 			return
 
 		case <-c.botTurn:
+			c.feedLegalActions()
+			start := time.Now()
 			p := c.ai.Play()
+			metrics.BotPlayDuration.Observe(time.Since(start).Seconds(), c.game)
 			msg := &interfaces.IncomingMessage{
 				Author:  c,
 				Type:    p.Type,
 				Content: p.Params,
 			}
+			// An action with no type means the AI has nothing to play,
+			// so the room is told to take the bot out of the game
+			if p.Type == "" {
+				c.log.Warn("Bot has no action to play", "client", c.Name(), "driver", c.game)
+				msg.Type = messages.TypeBotStuck
+			}
 			channel <- msg
 		}
 	}
@@ -88,8 +104,7 @@ func (c *BotClient) WritePump() {
 
 	defer func() {
 		if rc := recover(); rc != nil {
-			fmt.Printf("Panic in bot '%s': %s\n", c.Name(), rc)
-			debug.PrintStack()
+			c.log.Error("Panic in bot", "client", c.Name(), "error", rc, "stack", string(debug.Stack()))
 			c.observer.Trigger(events.BotPanicked{Client: c})
 		}
 	}()
@@ -130,6 +145,20 @@ func (c *BotClient) feedPendingUpdatesInOrder() {
 	}
 }
 
+// feedLegalActions tells the AI the actions it can do, if both the AI and the game driver support it
+func (c *BotClient) feedLegalActions() {
+	feeder, ok := c.ai.(api.LegalActionsFeeder)
+	if !ok {
+		return
+	}
+	actions, err := c.Room().LegalActions(c)
+	if err != nil {
+		c.log.Warn("Couldn't get legal actions", "client", c.Name(), "error", err)
+		return
+	}
+	feeder.FeedLegalActions(actions)
+}
+
 func (c *BotClient) isInTurn() bool {
 	if currentPlayers, err := c.Room().GameCurrentPlayersClients(); err == nil {
 		for _, clientInTurn := range currentPlayers {
@@ -157,6 +186,17 @@ func (c *BotClient) SetName(v string) interfaces.Client {
 	return c
 }
 
+// Level returns the level the bot was created with
+func (c *BotClient) Level() string {
+	return c.level
+}
+
+// SetAI replaces the bot's AI, e.g. when the room starts a new game.
+// It must only be called while the bot is not playing.
+func (c *BotClient) SetAI(ai api.AI) {
+	c.ai = ai
+}
+
 // Close sends a quitting signal that will end the ReadPump() and WritePump()
 // goroutines of this instance
 func (c *BotClient) Close() {
@@ -209,3 +249,45 @@ func (c *BotClient) SetGame(game string) {
 func (c *BotClient) Game() string {
 	return c.game
 }
+
+// SessionID returns an empty string, as bots never reconnect
+func (c *BotClient) SessionID() string {
+	return ""
+}
+
+// SetSessionID is not needed in BotClient
+func (c *BotClient) SetSessionID(id string) {
+}
+
+// UserID returns an empty string, as bots are not authenticated
+func (c *BotClient) UserID() string {
+	return ""
+}
+
+// SetUserID is not needed in BotClient
+func (c *BotClient) SetUserID(id string) {
+}
+
+// Address returns an empty string, as bots run in the server
+func (c *BotClient) Address() string {
+	return ""
+}
+
+// SetAddress is not needed in BotClient
+func (c *BotClient) SetAddress(addr string) {
+}
+
+// ProtocolVersion returns the first protocol version, as bots only
+// read game status updates, which are the same in all of them
+func (c *BotClient) ProtocolVersion() int {
+	return messages.ProtocolV1
+}
+
+// SetProtocolVersion is not needed in BotClient
+func (c *BotClient) SetProtocolVersion(v int) {
+}
+
+// Codec returns the JSON codec, which bots use to read game status updates
+func (c *BotClient) Codec() codec.Codec {
+	return codec.JSON
+}
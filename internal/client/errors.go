@@ -0,0 +1,6 @@
+package client
+
+// Error messages returned from clients
+const (
+	RateLimited = "rate_limited"
+)
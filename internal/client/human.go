@@ -1,15 +1,17 @@
 package client
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/svera/sackson-server/internal/codec"
 	"github.com/svera/sackson-server/internal/config"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
+	"github.com/svera/sackson-server/internal/metrics"
+	"github.com/svera/sackson-server/internal/ratelimit"
 )
 
 var (
@@ -21,20 +23,29 @@ var (
 // several functions to send/receive data to/from a client using a websocket
 // connection
 type Human struct {
-	name     string
-	ws       *websocket.Conn
-	incoming chan []byte // Channel storing incoming messages
-	room     interfaces.Room
-	timer    *time.Timer
-	quit     chan struct{}
-	game     string
+	name      string
+	ws        *websocket.Conn
+	incoming  chan []byte // Channel storing incoming messages
+	room      interfaces.Room
+	timer     *time.Timer
+	quit      chan struct{}
+	closeOnce sync.Once
+	game      string
+	sessionID string
+	userID    string
+	address   string
+	limiter   *ratelimit.Limiter
+	version   int
+	codec     codec.Codec
+	log       *logger.Logger
 }
 
 // NewHuman returns a new Human instance
-func NewHuman(w http.ResponseWriter, r *http.Request, cfg *config.Config) (interfaces.Client, error) {
+func NewHuman(w http.ResponseWriter, r *http.Request, cfg *config.Config, log *logger.Logger) (interfaces.Client, error) {
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  maxMessageSize,
 		WriteBufferSize: maxMessageSize,
+		Subprotocols:    codec.Subprotocols(),
 		CheckOrigin: func(r *http.Request) bool {
 			if r.Header.Get("Origin") != cfg.AllowedOrigin && cfg.AllowedOrigin != "*" {
 				return false
@@ -48,11 +59,18 @@ func NewHuman(w http.ResponseWriter, r *http.Request, cfg *config.Config) (inter
 		return &Human{}, err
 	}
 
-	return &Human{
+	c := &Human{
 		incoming: make(chan []byte, maxMessageSize),
 		ws:       ws,
 		quit:     make(chan struct{}),
-	}, nil
+		log:      log,
+		// Clients which don't ask for a subprotocol get JSON
+		codec: codec.ByName(ws.Subprotocol()),
+	}
+	if rules := cfg.RateLimits(); rules.Enabled() {
+		c.limiter = ratelimit.New(rules, time.Now)
+	}
+	return c, nil
 }
 
 // ReadPump reads input from the user and writes it to the passed channel
@@ -75,13 +93,23 @@ func (c *Human) ReadPump(channel chan *interfaces.IncomingMessage, unregister ch
 			break
 		}
 
-		msg := interfaces.IncomingMessage{}
-		if err := json.Unmarshal(message, &msg); err == nil {
+		if in, err := c.codec.Decode(message); err == nil {
+			msg := interfaces.IncomingMessage{Type: in.Type, RequestID: in.RequestID, Content: in.Content}
+			if c.limiter != nil && !c.limiter.Allow(msg.Type) {
+				metrics.MessagesRateLimited.Inc(msg.Type)
+				if c.limiter.Abusive() {
+					c.log.Warn("Client disconnected for flooding", "client", c.name, "address", c.address)
+					metrics.ClientsRateLimited.Inc()
+					break
+				}
+				// The error is sent through the hub, so it's numbered and kept for resumes like any other
+				msg.Rejected = RateLimited
+			}
 			msg.Author = c
 
 			channel <- &msg
 		} else {
-			log.Println("Error decoding message content")
+			c.log.Warn("Error decoding message content", "client", c.name)
 		}
 	}
 }
@@ -102,7 +130,7 @@ func (c *Human) WritePump() {
 				c.write(websocket.CloseMessage, []byte{})
 				return
 			}
-			if err := c.write(websocket.TextMessage, message); err != nil {
+			if err := c.write(c.frameType(), message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -110,6 +138,23 @@ func (c *Human) WritePump() {
 				return
 			}
 		case <-c.quit:
+			c.flush()
+			c.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
+
+// flush writes the messages still queued for the client, so those sent
+// right before closing the connection (as the reason of it) are not lost
+func (c *Human) flush() {
+	for {
+		select {
+		case message := <-c.incoming:
+			if err := c.write(c.frameType(), message); err != nil {
+				return
+			}
+		default:
 			return
 		}
 	}
@@ -154,10 +199,12 @@ func (c *Human) write(mt int, message []byte) error {
 	return c.ws.WriteMessage(mt, message)
 }
 
-// Close closes connection through the websocket
+// Close closes connection through the websocket, once the messages queued for the client are sent.
+// It is safe to call it more than once
 func (c *Human) Close() {
-	c.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	close(c.quit)
+	c.closeOnce.Do(func() {
+		close(c.quit)
+	})
 }
 
 // IsBot returns false because this client is not managed by a bot
@@ -192,3 +239,61 @@ func (c *Human) SetGame(game string) {
 func (c *Human) Game() string {
 	return c.game
 }
+
+// SessionID returns the client's session identifier, which allows him/her
+// to get back to rooms in asynchronous mode after disconnecting
+func (c *Human) SessionID() string {
+	return c.sessionID
+}
+
+// SetSessionID sets the client's session identifier
+func (c *Human) SetSessionID(id string) {
+	c.sessionID = id
+}
+
+// UserID returns the identifier of the authenticated user using the client,
+// or an empty string if he/she is anonymous
+func (c *Human) UserID() string {
+	return c.userID
+}
+
+// SetUserID sets the identifier of the authenticated user using the client
+func (c *Human) SetUserID(id string) {
+	c.userID = id
+}
+
+// Address returns the network address the client connected from
+func (c *Human) Address() string {
+	return c.address
+}
+
+// SetAddress sets the network address the client connected from
+func (c *Human) SetAddress(addr string) {
+	c.address = addr
+}
+
+// ProtocolVersion returns the version of the protocol used to talk with the client
+func (c *Human) ProtocolVersion() int {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return c.version
+}
+
+// SetProtocolVersion sets the version of the protocol used to talk with the client
+func (c *Human) SetProtocolVersion(v int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	c.version = v
+}
+
+// Codec returns the codec used to encode the messages sent to the client
+func (c *Human) Codec() codec.Codec {
+	return c.codec
+}
+
+func (c *Human) frameType() int {
+	if c.codec.Binary() {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
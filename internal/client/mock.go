@@ -3,6 +3,8 @@ package client
 import (
 	"time"
 
+	"github.com/svera/sackson-server/internal/codec"
+
 	"github.com/svera/sackson-server/internal/interfaces"
 )
 
@@ -24,6 +26,11 @@ type Mock struct {
 	FakeStartTimer func(time.Duration)
 	FakeSetGame    func(string)
 	FakeGame       func() string
+	FakeSessionID  func() string
+	FakeUserID     func() string
+	FakeAddress    func() string
+	FakeVersion    func() int
+	FakeCodec      func() codec.Codec
 }
 
 // NewMock returns a new mock instance ready to use
@@ -38,12 +45,30 @@ func NewMock() *Mock {
 		FakeGame: func() string {
 			return "test"
 		},
+		FakeSessionID: func() string {
+			return ""
+		},
+		FakeUserID: func() string {
+			return ""
+		},
+		FakeAddress: func() string {
+			return ""
+		},
+		FakeVersion: func() int {
+			return 1
+		},
+		FakeCodec: func() codec.Codec {
+			return codec.JSON
+		},
 		FakeClose: func() {
 			// Do nothing
 		},
 		FakeStopTimer: func() {
 			// Do nothing
 		},
+		FakeIsBot: func() bool {
+			return false
+		},
 		FakeRoom: func() interfaces.Room {
 			return nil
 		},
@@ -143,3 +168,56 @@ func (c *Mock) SetGame(game string) {
 func (c *Mock) Game() string {
 	return c.FakeGame()
 }
+
+// SessionID mocks the SessionID method defined in the Client interface
+func (c *Mock) SessionID() string {
+	return c.FakeSessionID()
+}
+
+// SetSessionID mocks the SetSessionID method defined in the Client interface
+func (c *Mock) SetSessionID(id string) {
+	c.FakeSessionID = func() string {
+		return id
+	}
+}
+
+// UserID mocks the UserID method defined in the Client interface
+func (c *Mock) UserID() string {
+	return c.FakeUserID()
+}
+
+// SetUserID mocks the SetUserID method defined in the Client interface
+func (c *Mock) SetUserID(id string) {
+	c.FakeUserID = func() string {
+		return id
+	}
+}
+
+// Address mocks the Address method defined in the Client interface
+func (c *Mock) Address() string {
+	return c.FakeAddress()
+}
+
+// SetAddress mocks the SetAddress method defined in the Client interface
+func (c *Mock) SetAddress(addr string) {
+	c.FakeAddress = func() string {
+		return addr
+	}
+}
+
+// ProtocolVersion mocks the ProtocolVersion method defined in the Client interface
+func (c *Mock) ProtocolVersion() int {
+	return c.FakeVersion()
+}
+
+// SetProtocolVersion mocks the SetProtocolVersion method defined in the Client interface
+func (c *Mock) SetProtocolVersion(v int) {
+	c.FakeVersion = func() int {
+		return v
+	}
+}
+
+// Codec mocks the Codec method defined in the Client interface
+func (c *Mock) Codec() codec.Codec {
+	return c.FakeCodec()
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"time"
+
+	"github.com/svera/sackson-server/internal/codec"
+
+	"github.com/svera/sackson-server/internal/interfaces"
+)
+
+// Offline is a struct that implements the client interface,
+// keeping the seat of a human client who disconnected from a room in
+// asynchronous mode until he/she comes back.
+// Messages sent to an offline client are discarded.
+type Offline struct {
+	name      string
+	sessionID string
+	userID    string
+	address   string
+	version   int
+	game      string
+	room      interfaces.Room
+	timer     *time.Timer
+}
+
+// NewOffline returns a new Offline instance which takes the place of the passed client
+func NewOffline(cl interfaces.Client) *Offline {
+	return &Offline{
+		name:      cl.Name(),
+		sessionID: cl.SessionID(),
+		userID:    cl.UserID(),
+		address:   cl.Address(),
+		version:   cl.ProtocolVersion(),
+		game:      cl.Game(),
+	}
+}
+
+// ReadPump is not needed in Offline, as there is no connection to read from
+func (c *Offline) ReadPump(channel chan *interfaces.IncomingMessage, unregister chan interfaces.Client) {
+}
+
+// WritePump is not needed in Offline, as there is no connection to write to
+func (c *Offline) WritePump() {
+}
+
+// Incoming returns a new channel every time, so sending a message to it never blocks
+// and the message is discarded
+func (c *Offline) Incoming() chan []byte {
+	return make(chan []byte, 1)
+}
+
+// Name returns the client's name
+func (c *Offline) Name() string {
+	return c.name
+}
+
+// SetName sets a name for the client
+func (c *Offline) SetName(v string) interfaces.Client {
+	c.name = v
+	return c
+}
+
+// Close is not needed in Offline, as there is no connection to close
+func (c *Offline) Close() {
+}
+
+// IsBot returns false because this client keeps the seat of a human one
+func (c *Offline) IsBot() bool {
+	return false
+}
+
+// Room returns the room where the client is in
+func (c *Offline) Room() interfaces.Room {
+	return c.room
+}
+
+// SetRoom sets the client's room
+func (c *Offline) SetRoom(r interfaces.Room) {
+	c.room = r
+}
+
+// SetTimer sets client's timer, which manages when to expel a client from a room due to inactivity
+func (c *Offline) SetTimer(t *time.Timer) {
+	c.timer = t
+	c.StopTimer()
+}
+
+// StopTimer stops the client's timer
+func (c *Offline) StopTimer() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// StartTimer starts the client's timer
+func (c *Offline) StartTimer(d time.Duration) {
+	c.timer.Reset(d)
+}
+
+// SetGame specifies the name of the game the client is using
+func (c *Offline) SetGame(game string) {
+	c.game = game
+}
+
+// Game returns the name of the game the client is using
+func (c *Offline) Game() string {
+	return c.game
+}
+
+// SessionID returns the session identifier of the client who disconnected
+func (c *Offline) SessionID() string {
+	return c.sessionID
+}
+
+// SetSessionID sets the session identifier of the client
+func (c *Offline) SetSessionID(id string) {
+	c.sessionID = id
+}
+
+// UserID returns the identifier of the authenticated user who disconnected
+func (c *Offline) UserID() string {
+	return c.userID
+}
+
+// SetUserID sets the identifier of the authenticated user
+func (c *Offline) SetUserID(id string) {
+	c.userID = id
+}
+
+// Address returns the network address the client who disconnected used
+func (c *Offline) Address() string {
+	return c.address
+}
+
+// SetAddress sets the network address of the client
+func (c *Offline) SetAddress(addr string) {
+	c.address = addr
+}
+
+// ProtocolVersion returns the protocol version used by the client who disconnected
+func (c *Offline) ProtocolVersion() int {
+	return c.version
+}
+
+// SetProtocolVersion sets the protocol version used by the client
+func (c *Offline) SetProtocolVersion(v int) {
+	c.version = v
+}
+
+// Codec returns the JSON codec, as messages sent to offline clients are discarded anyway
+func (c *Offline) Codec() codec.Codec {
+	return codec.JSON
+}
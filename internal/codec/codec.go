@@ -0,0 +1,111 @@
+// Package codec implements the wire encodings used to exchange messages with clients.
+// Clients choose the encoding when connecting through the websocket subprotocol header,
+// JSON being used if they don't ask for any.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Error messages returned from codecs
+const (
+	InvalidMessage = "invalid_message"
+)
+
+// MaxRequestIDLength is the maximum length of the request identifiers sent by clients
+const MaxRequestIDLength = 64
+
+// Codec defines the methods a wire encoding must implement
+type Codec interface {
+	// Name returns the websocket subprotocol used to ask for the codec
+	Name() string
+	// Binary returns true if encoded messages must be sent in binary frames, false for text ones
+	Binary() bool
+	// Encode returns the message with the passed type, content and header encoded
+	Encode(typ string, content interface{}, h Header) ([]byte, error)
+	// Decode returns the passed message, with its content encoded in JSON
+	Decode(data []byte) (Incoming, error)
+}
+
+// JSON is the default codec, which encodes messages in JSON
+var JSON Codec = jsonCodec{}
+
+// MsgPack is a codec which encodes messages in MessagePack, using the same keys as JSON
+var MsgPack Codec = msgPackCodec{}
+
+// Available holds all codecs, in order of preference
+var Available = []Codec{MsgPack, JSON}
+
+// Subprotocols returns the websocket subprotocols of all available codecs
+func Subprotocols() []string {
+	names := []string{}
+	for _, c := range Available {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// ByName returns the codec for the passed websocket subprotocol,
+// or JSON if there is none with that name
+func ByName(name string) Codec {
+	for _, c := range Available {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return JSON
+}
+
+// Header holds the data sent along with the content of a message, fields
+// with their zero value are left out
+type Header struct {
+	// Sequence is the room sequence number of game status updates
+	Sequence int
+	// ClientSequence is the number of the message in the client's session
+	ClientSequence int
+	// RequestID is the identifier of the client message this one answers
+	RequestID string
+}
+
+// envelope wraps the messages sent to clients. Content is encoded
+// along with its envelope, so it is marshalled only once.
+type envelope struct {
+	Type           string      `json:"typ"`
+	SequenceNumber int         `json:"seq,omitempty"`
+	ClientSequence int         `json:"csq,omitempty"`
+	RequestID      string      `json:"rid,omitempty"`
+	Content        interface{} `json:"cnt"`
+}
+
+// Incoming is a message sent by a client
+type Incoming struct {
+	Type string `json:"typ"`
+	// RequestID is an optional identifier chosen by the client,
+	// sent back along with the responses to the message
+	RequestID string `json:"rid"`
+	// Content is always encoded in JSON, whatever the codec used
+	Content json.RawMessage `json:"cnt"`
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Binary() bool {
+	return false
+}
+
+func (jsonCodec) Encode(typ string, content interface{}, h Header) ([]byte, error) {
+	return json.Marshal(envelope{Type: typ, SequenceNumber: h.Sequence, ClientSequence: h.ClientSequence, RequestID: h.RequestID, Content: content})
+}
+
+func (jsonCodec) Decode(data []byte) (Incoming, error) {
+	var m Incoming
+	if err := json.Unmarshal(data, &m); err != nil || len(m.RequestID) > MaxRequestIDLength {
+		return Incoming{}, errors.New(InvalidMessage)
+	}
+	return m, nil
+}
@@ -0,0 +1,221 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type corporation struct {
+	Name     string `json:"nam"`
+	Price    int    `json:"prc"`
+	Size     int    `json:"siz"`
+	Defunct  bool   `json:"def,omitempty"`
+	Majority int    `json:"maj"`
+	Minority int    `json:"min"`
+}
+
+type playerInfo struct {
+	Name   string         `json:"nam"`
+	Cash   int            `json:"csh"`
+	Shares map[string]int `json:"sha"`
+}
+
+type base struct {
+	Round int `json:"rnd"`
+}
+
+// boardStatus resembles the status sent by the Acquire driver after every play
+type boardStatus struct {
+	base
+	Board        map[string]string `json:"brd"`
+	State        string            `json:"sta"`
+	Hand         []string          `json:"hnd"`
+	Corporations []corporation     `json:"cor"`
+	Players      []playerInfo      `json:"ply"`
+	LastTurn     bool              `json:"lst"`
+	UpdatedAt    time.Time         `json:"upd"`
+	Extra        json.RawMessage   `json:"ext,omitempty"`
+	Ignored      string            `json:"-"`
+	unexported   int
+}
+
+func newBoardStatus() boardStatus {
+	s := boardStatus{
+		base:      base{Round: 12},
+		Board:     map[string]string{},
+		State:     "PlayTile",
+		Hand:      []string{"1A", "3C", "5F", "7H", "9B", "12I"},
+		UpdatedAt: time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC),
+		Extra:     json.RawMessage(`{"msg": "Sergio merged Tower into Luxor", "pts": [1.5, -2]}`),
+	}
+	names := []string{"Sackson", "Zeta", "Hydra", "Fusion", "America", "Phoenix", "Quantum"}
+	for i, name := range names {
+		s.Corporations = append(s.Corporations, corporation{Name: name, Price: 300 + i*100, Size: i * 3, Majority: 3000 + i*1000, Minority: 1500 + i*500})
+	}
+	for number := 1; number <= 12; number++ {
+		for _, letter := range "ABCDEFGHI" {
+			s.Board[fmt.Sprintf("%d%c", number, letter)] = "empty"
+		}
+	}
+	for i := 0; i < 6; i++ {
+		shares := map[string]int{}
+		for _, name := range names {
+			shares[name] = i
+		}
+		s.Players = append(s.Players, playerInfo{Name: fmt.Sprintf("Player %d", i), Cash: 6000 - i*250, Shares: shares})
+	}
+	return s
+}
+
+// generic returns the value as decoded from its JSON representation
+func generic(t testing.TB, data []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMsgPackRoundTripMatchesJSON(t *testing.T) {
+	status := newBoardStatus()
+
+	encoded, err := MsgPack.Encode("upd", status, Header{Sequence: 7, ClientSequence: 3, RequestID: "r1"})
+	if err != nil {
+		t.Fatalf("Encode must not return an error, got %s", err.Error())
+	}
+	in, err := MsgPack.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode must not return an error, got %s", err.Error())
+	}
+	expected, _ := json.Marshal(status)
+
+	if in.Type != "upd" || in.RequestID != "r1" {
+		t.Errorf("Decoded type and request identifier must be 'upd' and 'r1', got '%s' and '%s'", in.Type, in.RequestID)
+	}
+	if !reflect.DeepEqual(generic(t, in.Content), generic(t, expected)) {
+		t.Errorf("Decoded content must be the same as its JSON version, got %s, expected %s", in.Content, expected)
+	}
+}
+
+func TestMsgPackEncodesNumbers(t *testing.T) {
+	values := []interface{}{
+		0, 127, 128, 255, 256, 65535, 65536, 1 << 40, -1, -32, -33, -128, -129, -32768, -32769, -1 << 40,
+		uint64(1 << 63), 1.5, float32(-0.25), "", "a string longer than thirty one characters", true, nil,
+	}
+	for _, v := range values {
+		encoded, err := MsgPack.Encode("tst", v, Header{})
+		if err != nil {
+			t.Fatalf("Encode must not return an error for %v, got %s", v, err.Error())
+		}
+		in, err := MsgPack.Decode(encoded)
+		if err != nil {
+			t.Fatalf("Decode must not return an error for %v, got %s", v, err.Error())
+		}
+		expected, _ := json.Marshal(v)
+		if !reflect.DeepEqual(generic(t, in.Content), generic(t, expected)) {
+			t.Errorf("Value %v must survive a round trip, got %s", v, in.Content)
+		}
+	}
+}
+
+func TestMsgPackRejectsInvalidData(t *testing.T) {
+	encoded, _ := MsgPack.Encode("upd", newBoardStatus(), Header{})
+	inputs := [][]byte{
+		{},
+		{0x92, 0x01, 0x02},
+		encoded[:len(encoded)-1],
+		append(append([]byte{}, encoded...), 0x01),
+		{0x81, 0xa3, 't', 'y', 'p', 0xdd, 0xff, 0xff, 0xff, 0xff},
+	}
+	for _, in := range inputs {
+		if _, err := MsgPack.Decode(in); err == nil || err.Error() != InvalidMessage {
+			t.Errorf("Decoding %v must return error '%s', got %v", in, InvalidMessage, err)
+		}
+	}
+}
+
+func TestJSONEncodesOnce(t *testing.T) {
+	encoded, _ := JSON.Encode("pls", map[string]string{"nam": "Sergio"}, Header{})
+	if string(encoded) != `{"typ":"pls","cnt":{"nam":"Sergio"}}` {
+		t.Errorf("Unexpected JSON message, got %s", encoded)
+	}
+	in, err := JSON.Decode([]byte(`{"typ": "joi", "rid": "r1", "cnt": {"rom": "VWXYZ"}}`))
+	if err != nil || in.Type != "joi" || in.RequestID != "r1" || string(in.Content) != `{"rom": "VWXYZ"}` {
+		t.Errorf("Unexpected decoded JSON message, got %+v %v", in, err)
+	}
+	if _, err = JSON.Decode([]byte(`{"typ": "joi", "rid": "` + strings.Repeat("r", MaxRequestIDLength+1) + `"}`)); err == nil {
+		t.Errorf("Decoding a message with a too long request identifier must return an error")
+	}
+}
+
+func TestMsgPackIsSmallerThanJSON(t *testing.T) {
+	status := newBoardStatus()
+	j, _ := JSON.Encode("upd", status, Header{Sequence: 1})
+	m, _ := MsgPack.Encode("upd", status, Header{Sequence: 1})
+
+	t.Logf("Board status size: %d bytes in JSON, %d bytes in MessagePack", len(j), len(m))
+	if len(m) >= len(j) {
+		t.Errorf("MessagePack message must be smaller than the JSON one")
+	}
+}
+
+func TestByName(t *testing.T) {
+	if ByName("msgpack") != MsgPack || ByName("") != JSON || ByName("xml") != JSON {
+		t.Errorf("Codecs must be found by their subprotocol name, defaulting to JSON")
+	}
+}
+
+func benchmarkEncode(b *testing.B, c Codec) {
+	status := newBoardStatus()
+	encoded, _ := c.Encode("upd", status, Header{Sequence: 1})
+	b.SetBytes(int64(len(encoded)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Encode("upd", status, Header{Sequence: 1})
+	}
+}
+
+func benchmarkDecode(b *testing.B, c Codec) {
+	encoded, _ := c.Encode("upd", newBoardStatus(), Header{Sequence: 1})
+	b.SetBytes(int64(len(encoded)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Decode(encoded)
+	}
+}
+
+// The previous way of encoding messages, marshalling the content and then its envelope
+func BenchmarkEncodeJSONTwice(b *testing.B) {
+	status := newBoardStatus()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		content, _ := json.Marshal(status)
+		json.Marshal(struct {
+			Type           string          `json:"typ"`
+			SequenceNumber int             `json:"seq,omitempty"`
+			Content        json.RawMessage `json:"cnt"`
+		}{"upd", 1, content})
+	}
+}
+
+func BenchmarkEncodeJSON(b *testing.B) {
+	benchmarkEncode(b, JSON)
+}
+
+func BenchmarkEncodeMsgPack(b *testing.B) {
+	benchmarkEncode(b, MsgPack)
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	benchmarkDecode(b, JSON)
+}
+
+func BenchmarkDecodeMsgPack(b *testing.B) {
+	benchmarkDecode(b, MsgPack)
+}
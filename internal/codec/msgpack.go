@@ -0,0 +1,642 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxDepth is the maximum nesting of arrays and maps allowed in decoded messages
+const maxDepth = 64
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+type msgPackCodec struct{}
+
+func (msgPackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgPackCodec) Binary() bool {
+	return true
+}
+
+// Encode writes the message as a MessagePack map with the same keys as its JSON version.
+// Values are encoded following the rules of the encoding/json package, so structs
+// use the keys of their json tags and types implementing json.Marshaler are encoded
+// from their JSON representation.
+func (msgPackCodec) Encode(typ string, content interface{}, h Header) ([]byte, error) {
+	e := &encoder{buf: make([]byte, 0, 512)}
+	keys := 2
+	if h.Sequence != 0 {
+		keys++
+	}
+	if h.ClientSequence != 0 {
+		keys++
+	}
+	if h.RequestID != "" {
+		keys++
+	}
+	e.writeMapHeader(keys)
+	e.writeString("typ")
+	e.writeString(typ)
+	if h.Sequence != 0 {
+		e.writeString("seq")
+		e.writeInt(int64(h.Sequence))
+	}
+	if h.ClientSequence != 0 {
+		e.writeString("csq")
+		e.writeInt(int64(h.ClientSequence))
+	}
+	if h.RequestID != "" {
+		e.writeString("rid")
+		e.writeString(h.RequestID)
+	}
+	e.writeString("cnt")
+	if err := e.encode(reflect.ValueOf(content)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Decode reads a MessagePack map with "typ", "cnt" and optionally "rid" keys, converting
+// its content to JSON so it can be parsed the same way as messages coming from JSON clients
+func (msgPackCodec) Decode(data []byte) (Incoming, error) {
+	d := &decoder{data: data}
+	v, err := d.decode(0)
+	if err != nil || d.pos != len(data) {
+		return Incoming{}, errors.New(InvalidMessage)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Incoming{}, errors.New(InvalidMessage)
+	}
+	in := Incoming{}
+	in.Type, _ = m["typ"].(string)
+	in.RequestID, _ = m["rid"].(string)
+	if len(in.RequestID) > MaxRequestIDLength {
+		return Incoming{}, errors.New(InvalidMessage)
+	}
+	cnt, ok := m["cnt"]
+	if !ok {
+		return in, nil
+	}
+	if in.Content, err = json.Marshal(cnt); err != nil {
+		return Incoming{}, errors.New(InvalidMessage)
+	}
+	return in, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.writeNil()
+		return nil
+	}
+	if v.Type() == jsonNumberType {
+		return e.encodeNumber(json.Number(v.String()))
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map:
+			if v.IsNil() {
+				e.writeNil()
+				return nil
+			}
+		}
+		return e.encodeMarshaler(v.Interface().(json.Marshaler))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.writeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32:
+		e.writeFloat32(float32(v.Float()))
+	case reflect.Float64:
+		e.writeFloat64(v.Float())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBin(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *encoder) encodeNumber(n json.Number) error {
+	if i, err := n.Int64(); err == nil {
+		e.writeInt(i)
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return err
+	}
+	e.writeFloat64(f)
+	return nil
+}
+
+// encodeMarshaler encodes values which define their own JSON representation
+func (e *encoder) encodeMarshaler(m json.Marshaler) error {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err = dec.Decode(&generic); err != nil {
+		return err
+	}
+	return e.encode(reflect.ValueOf(generic))
+}
+
+func (e *encoder) encodeArray(v reflect.Value) error {
+	e.writeArrayHeader(v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeMap encodes maps sorting their keys, as encoding/json does
+func (e *encoder) encodeMap(v reflect.Value) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		var key string
+		switch k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return fmt.Errorf("msgpack: unsupported map key type %s", k.Type())
+		}
+		entries = append(entries, entry{key: key, value: v.MapIndex(k)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	e.writeMapHeader(len(entries))
+	for _, en := range entries {
+		e.writeString(en.key)
+		if err := e.encode(en.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	fields := cachedFields(v.Type())
+	values := make([]reflect.Value, 0, len(fields))
+	encoded := make([]field, 0, len(fields))
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		values = append(values, fv)
+		encoded = append(encoded, f)
+	}
+
+	e.writeMapHeader(len(encoded))
+	for i, f := range encoded {
+		e.writeString(f.name)
+		if err := e.encode(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field holds how a struct field is encoded, as defined by its json tag
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldsCache sync.Map
+
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldsCache.Load(t); ok {
+		return f.([]field)
+	}
+	f := typeFields(t, nil)
+	fieldsCache.Store(t, f)
+	return f
+}
+
+// typeFields returns the encoded fields of a struct, promoting the ones
+// of its embedded structs without a json tag
+func typeFields(t reflect.Type, index []int) []field {
+	fields := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, typeFields(sf.Type, idx)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		f := field{name: parts[0], index: idx}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (e *encoder) writeNil() {
+	e.buf = append(e.buf, 0xc0)
+}
+
+func (e *encoder) writeBool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+func (e *encoder) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.writeUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.write16(uint16(i))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.write32(uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.write64(uint64(i))
+	}
+}
+
+func (e *encoder) writeUint(u uint64) {
+	switch {
+	case u < 128:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.write16(uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.write32(uint32(u))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.write64(u)
+	}
+}
+
+func (e *encoder) writeFloat32(f float32) {
+	e.buf = append(e.buf, 0xca)
+	e.write32(math.Float32bits(f))
+}
+
+func (e *encoder) writeFloat64(f float64) {
+	e.buf = append(e.buf, 0xcb)
+	e.write64(math.Float64bits(f))
+}
+
+func (e *encoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.write16(uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.write32(uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) writeBin(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.write16(uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.write32(uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) writeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.write16(uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.write32(uint32(n))
+	}
+}
+
+func (e *encoder) writeMapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.write16(uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.write32(uint32(n))
+	}
+}
+
+func (e *encoder) write16(v uint16) {
+	e.buf = append(e.buf, byte(v>>8), byte(v))
+}
+
+func (e *encoder) write32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) write64(v uint64) {
+	e.write32(uint32(v >> 32))
+	e.write32(uint32(v))
+}
+
+var errShortData = errors.New("msgpack: unexpected end of data")
+
+// decoder reads MessagePack data into generic values: nil, bool, int64, uint64,
+// float64, string, []byte, []interface{} and map[string]interface{}
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: too deeply nested data")
+	}
+	b, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return d.string(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return d.array(int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return d.mapOf(int(b&0x0f), depth)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(b - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.bytes(n)
+		return append([]byte{}, raw...), err
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (b - 0xcc))
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(b - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.string(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(b - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(b - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%x", b)
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errShortData
+	}
+	d.pos++
+	return d.data[d.pos-1], nil
+}
+
+func (d *decoder) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errShortData
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos], nil
+}
+
+// uint reads a big endian unsigned integer of the passed size in bytes
+func (d *decoder) uint(size int) (uint64, error) {
+	raw, err := d.bytes(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(raw[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(raw)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(raw)), nil
+	}
+	return binary.BigEndian.Uint64(raw), nil
+}
+
+// length reads a length prefix of 1, 2 or 4 bytes, for sizes 0, 1 and 2 respectively
+func (d *decoder) length(size byte) (int, error) {
+	v, err := d.uint(1 << size)
+	if err != nil {
+		return 0, err
+	}
+	// Every element takes at least one byte, so longer lengths can't be right
+	if v > uint64(len(d.data)-d.pos) {
+		return 0, errShortData
+	}
+	return int(v), nil
+}
+
+func (d *decoder) string(n int) (interface{}, error) {
+	raw, err := d.bytes(n)
+	return string(raw), err
+}
+
+func (d *decoder) array(n int, depth int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errShortData
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (d *decoder) mapOf(n int, depth int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errShortData
+	}
+	values := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch key := k.(type) {
+		case string:
+			values[key] = v
+		case int64, uint64:
+			values[fmt.Sprint(key)] = v
+		default:
+			return nil, errors.New("msgpack: unsupported map key")
+		}
+	}
+	return values, nil
+}
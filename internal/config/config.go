@@ -4,8 +4,11 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"regexp"
 	"time"
 
+	"github.com/svera/sackson-server/internal/logger"
+	"github.com/svera/sackson-server/internal/ratelimit"
 	"gopkg.in/yaml.v2"
 )
 
@@ -18,6 +21,140 @@ type Config struct {
 	Secure             bool
 	SecureCertFileName string `yaml:"secure_cert_file_name"`
 	SecureKeyFileName  string `yaml:"secure_key_file_name"`
+	// HistoryFile is the path of the file where finished matches are stored.
+	// Match history is disabled if empty.
+	HistoryFile string `yaml:"history_file"`
+	// RatingsFile is the path of the file where players' ratings are stored.
+	// Ratings are disabled if empty.
+	RatingsFile string `yaml:"ratings_file"`
+	// RatingIncludeBotGames makes games with bots be rated in their own ladders
+	// instead of being ignored
+	RatingIncludeBotGames bool `yaml:"rating_include_bot_games"`
+	// MatchmakingInterval is the time in seconds between matchmaking rounds.
+	// Matchmaking is disabled if 0.
+	MatchmakingInterval time.Duration `yaml:"matchmaking_interval"`
+	// MatchmakingBotsWait is the time in seconds a player who allows bots has to wait
+	// in the matchmaking queue before empty seats are filled with them (0 to never use bots)
+	MatchmakingBotsWait time.Duration `yaml:"matchmaking_bots_wait"`
+	// MatchmakingMaxPlayers is the maximum number of players players can ask for in matchmaking.
+	// A default of 6 is used if 0.
+	MatchmakingMaxPlayers int `yaml:"matchmaking_max_players"`
+	// MatchmakingBotsLevel is the level of the bots added by matchmaking
+	MatchmakingBotsLevel string `yaml:"matchmaking_bots_level"`
+	// MatchmakingPlayerTimeout is the time in seconds each player has per turn
+	// in games started by matchmaking (0 for no timeout)
+	MatchmakingPlayerTimeout time.Duration `yaml:"matchmaking_player_timeout"`
+	// AsyncTimeout is the time in hours an asynchronous room lives (0 for no timeout)
+	AsyncTimeout time.Duration `yaml:"async_timeout"`
+	// Notifier is the way players are notified that it is their turn in asynchronous rooms,
+	// either "log" or "webhook". Notifications are disabled if empty.
+	Notifier string
+	// NotifierWebhookURL is the URL notifications are posted to by the webhook notifier
+	NotifierWebhookURL string `yaml:"notifier_webhook_url"`
+	// AuthSecret is the secret used to validate the HMAC-SHA256 signed JSON Web Tokens
+	// sent by users when connecting. Authentication is disabled if empty.
+	AuthSecret string `yaml:"auth_secret"`
+	// AuthRequired makes connections without a token be rejected
+	AuthRequired bool `yaml:"auth_required"`
+	// BansFile is the path of the file where the identifiers banned from the server are stored.
+	// Bans are only kept in memory if empty.
+	BansFile string `yaml:"bans_file"`
+	// AdminToken is the bearer token needed to use the admin API.
+	// The admin API is disabled if empty.
+	AdminToken string `yaml:"admin_token"`
+	// Metrics exposes server metrics in the Prometheus text format under /metrics
+	Metrics bool
+	// MaxClientsPerGame is the maximum number of clients connected to each game (0 for no limit)
+	MaxClientsPerGame int `yaml:"max_clients_per_game"`
+	// MaxRooms is the maximum number of rooms in the server (0 for no limit)
+	MaxRooms int `yaml:"max_rooms"`
+	// MaxRoomsPerAddress is the maximum number of rooms owned by clients
+	// connected from the same network address (0 for no limit)
+	MaxRoomsPerAddress int `yaml:"max_rooms_per_address"`
+	// MaxBotsPerRoom is the maximum number of bots that can be added to a room (0 for no limit)
+	MaxBotsPerRoom int `yaml:"max_bots_per_room"`
+	// Maintenance starts the server in maintenance mode, refusing the creation of new rooms.
+	// It can be toggled at runtime through the admin API.
+	Maintenance bool
+	// DeltaUpdates sends clients who acknowledge game statuses the changes from
+	// the last one they acknowledged, instead of whole statuses
+	DeltaUpdates bool `yaml:"delta_updates"`
+	// DeltaKeyframeInterval is the number of consecutive patches after which a whole
+	// status is sent again (0 to only send them when requested)
+	DeltaKeyframeInterval int `yaml:"delta_keyframe_interval"`
+	// ResendBufferSize is the number of messages not acknowledged yet kept for every
+	// session, to be sent again if the client resumes it (0 to disable resuming sessions)
+	ResendBufferSize int `yaml:"resend_buffer_size"`
+	// ResumeWindow is the time in seconds the messages of a session are kept
+	// after its client disconnects
+	ResumeWindow time.Duration `yaml:"resume_window"`
+	// VoteTimeout is the default time in seconds players have to vote
+	// a proposal in their room (0 for no timeout)
+	VoteTimeout time.Duration `yaml:"vote_timeout"`
+	// ShutdownDrainTimeout is the time in seconds running games are given to finish
+	// when the server is shutting down (0 to stop them right away)
+	ShutdownDrainTimeout time.Duration `yaml:"shutdown_drain_timeout"`
+	// SnapshotsDir is the directory where the games still running when the server
+	// shuts down are persisted. Snapshots are disabled if empty.
+	SnapshotsDir string `yaml:"snapshots_dir"`
+	// RateLimit holds the limits applied to the messages sent by each client
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	// Names holds the rules the names chosen by players must follow
+	Names NamesConfig
+	// Log holds the logging settings
+	Log LogConfig
+}
+
+// RateLimitConfig holds the limits applied to the messages sent by each client
+type RateLimitConfig struct {
+	// Rate is the number of messages per second a client can send.
+	// Messages are not limited if 0, except by the type limits.
+	Rate float64
+	// Burst is the number of messages a client can send at once
+	Burst int
+	// Types holds stricter limits for specific message types, e.g. "cre".
+	// A type with a rate of 0 is not limited.
+	Types map[string]RateLimit
+	// MaxViolations is the number of consecutive rejected messages after which
+	// a client is disconnected (0 for never)
+	MaxViolations int `yaml:"max_violations"`
+}
+
+// RateLimit defines the rate at which messages can be sent
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// minNamesMaxLength is the lowest maximum length of names allowed, which leaves room
+// for at least a character and the number appended to names taken by other players
+const minNamesMaxLength = 3
+
+// NamesConfig holds the rules the names chosen by players must follow
+type NamesConfig struct {
+	// MinLength is the minimum number of characters of names, names can't be empty anyway
+	MinLength int `yaml:"min_length"`
+	// MaxLength is the maximum number of characters of names (0 for no limit).
+	// It must be at least 3, so numbers can be appended to names taken by other players.
+	MaxLength int `yaml:"max_length"`
+	// Pattern is a regular expression names must match, any characters are allowed if empty
+	Pattern string
+	// ReservedPrefixes can't be used at the start of names, ignoring case
+	ReservedPrefixes []string `yaml:"reserved_prefixes"`
+	// BlockedWords can't be used anywhere in names, ignoring case
+	BlockedWords []string `yaml:"blocked_words"`
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	// Format of the log entries, either "text" (default) or "json"
+	Format string
+	// Level is the minimum level of the entries written: debug, info, warn or error.
+	// If empty, debug is used if debug is enabled, info otherwise.
+	Level string
+	// Levels overrides Level for the passed subsystems:
+	// hub, room, client, drivers, history, rating, notify and http
+	Levels map[string]string
 }
 
 // Load reads configuration from config.yml and parses it
@@ -42,5 +179,92 @@ func (c *Config) validate() error {
 	if c.AllowedOrigin == "" {
 		return errors.New("Sackson-server configuration: Invalid origin")
 	}
+	if c.Notifier != "" && c.Notifier != "log" && c.Notifier != "webhook" {
+		return errors.New("Sackson-server configuration: Invalid notifier")
+	}
+	if c.Notifier == "webhook" && c.NotifierWebhookURL == "" {
+		return errors.New("Sackson-server configuration: Invalid notifier webhook URL")
+	}
+	if c.Log.Format != "" && c.Log.Format != "text" && c.Log.Format != "json" {
+		return errors.New("Sackson-server configuration: Invalid log format")
+	}
+	if _, err := c.LogOptions(); err != nil {
+		return errors.New("Sackson-server configuration: " + err.Error())
+	}
+	if c.MaxClientsPerGame < 0 || c.MaxRooms < 0 || c.MaxRoomsPerAddress < 0 || c.MaxBotsPerRoom < 0 {
+		return errors.New("Sackson-server configuration: Invalid capacity limits")
+	}
+	if c.ResendBufferSize < 0 || c.ResumeWindow < 0 {
+		return errors.New("Sackson-server configuration: Invalid resend buffer")
+	}
+	if c.MatchmakingMaxPlayers != 0 && c.MatchmakingMaxPlayers < 2 {
+		return errors.New("Sackson-server configuration: Invalid matchmaking maximum players")
+	}
+	if c.VoteTimeout < 0 {
+		return errors.New("Sackson-server configuration: Invalid vote timeout")
+	}
+	if c.Names.MinLength < 0 || c.Names.MaxLength < 0 || (c.Names.MaxLength > 0 && (c.Names.MaxLength < minNamesMaxLength || c.Names.MinLength > c.Names.MaxLength)) {
+		return errors.New("Sackson-server configuration: Invalid name lengths")
+	}
+	if _, err := regexp.Compile(c.Names.Pattern); err != nil {
+		return errors.New("Sackson-server configuration: Invalid name pattern")
+	}
+	if !c.RateLimit.valid() {
+		return errors.New("Sackson-server configuration: Invalid rate limits")
+	}
+	if c.AuthRequired && c.AuthSecret == "" {
+		return errors.New("Sackson-server configuration: Authentication required without a secret")
+	}
 	return nil
 }
+
+// LogOptions returns the options used to create the server logger
+func (c *Config) LogOptions() (logger.Options, error) {
+	var err error
+	opts := logger.Options{
+		JSON:   c.Log.Format == "json",
+		Level:  logger.InfoLevel,
+		Levels: map[string]logger.Level{},
+	}
+	if c.Debug {
+		opts.Level = logger.DebugLevel
+	}
+	if c.Log.Level != "" {
+		if opts.Level, err = logger.ParseLevel(c.Log.Level); err != nil {
+			return opts, err
+		}
+	}
+	for subsystem, name := range c.Log.Levels {
+		if opts.Levels[subsystem], err = logger.ParseLevel(name); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// valid returns true if all limits with a rate allow at least one message at once
+func (r RateLimitConfig) valid() bool {
+	limits := []RateLimit{{Rate: r.Rate, Burst: r.Burst}}
+	for _, limit := range r.Types {
+		limits = append(limits, limit)
+	}
+	for _, limit := range limits {
+		if limit.Rate < 0 || (limit.Rate > 0 && limit.Burst < 1) {
+			return false
+		}
+	}
+	return r.MaxViolations >= 0
+}
+
+// RateLimits returns the rules used to throttle the messages sent by each client
+func (c *Config) RateLimits() ratelimit.Rules {
+	rules := ratelimit.Rules{
+		Default:       ratelimit.Rule{Rate: c.RateLimit.Rate, Burst: c.RateLimit.Burst},
+		Types:         map[string]ratelimit.Rule{},
+		MaxViolations: c.RateLimit.MaxViolations,
+	}
+	for typ, limit := range c.RateLimit.Types {
+		rules.Types[typ] = ratelimit.Rule{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	return rules
+}
@@ -46,3 +46,57 @@ func TestLoadNoAllowedOrigin(t *testing.T) {
 		t.Errorf("Load must return an error if loaded config file has no allowed origin information")
 	}
 }
+
+func TestLoadWebhookNotifierWithoutURL(t *testing.T) {
+	testData := Config{
+		Port:          ":8000",
+		AllowedOrigin: "*",
+		Notifier:      "webhook",
+	}
+	ymlString, _ := yaml.Marshal(testData)
+	_, err := Load(bytes.NewReader(ymlString))
+	if err == nil {
+		t.Errorf("Load must return an error if the webhook notifier is used without an URL")
+	}
+}
+
+func TestLoadInvalidLogLevel(t *testing.T) {
+	testData := Config{
+		Port:          ":8000",
+		AllowedOrigin: "*",
+		Log:           LogConfig{Levels: map[string]string{"room": "verbose"}},
+	}
+	ymlString, _ := yaml.Marshal(testData)
+	_, err := Load(bytes.NewReader(ymlString))
+	if err == nil {
+		t.Errorf("Load must return an error if a log level is invalid")
+	}
+}
+
+func TestLoadInvalidRateLimits(t *testing.T) {
+	testData := Config{
+		Port:          ":8000",
+		AllowedOrigin: "*",
+		RateLimit: RateLimitConfig{
+			Types: map[string]RateLimit{"cre": {Rate: 1}},
+		},
+	}
+	ymlString, _ := yaml.Marshal(testData)
+	_, err := Load(bytes.NewReader(ymlString))
+	if err == nil {
+		t.Errorf("Load must return an error if a rate limit allows no messages at once")
+	}
+}
+
+func TestLoadNamesMaxLengthWithoutRoomForSuffix(t *testing.T) {
+	testData := Config{
+		Port:          ":8000",
+		AllowedOrigin: "*",
+		Names:         NamesConfig{MaxLength: 2},
+	}
+	ymlString, _ := yaml.Marshal(testData)
+	_, err := Load(bytes.NewReader(ymlString))
+	if err == nil {
+		t.Errorf("Load must return an error if the maximum length of names leaves no room for a number")
+	}
+}
@@ -3,12 +3,12 @@ package drivers
 import (
 	"errors"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"plugin"
 
 	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/logger"
 )
 
 var drivers map[string]plugin.Symbol
@@ -49,30 +49,30 @@ func Exist(name string) bool {
 
 // Load reads all libraries from the game drivers directory and stores them in the drivers map
 // if they implement a method called "New"
-func Load() {
+func Load(log *logger.Logger) {
 	dir := "/usr/lib/sackson-server"
 	files, _ := ioutil.ReadDir(dir)
 	if len(files) == 0 {
-		log.Printf("No files found in %s\n", dir)
+		log.Warn("No driver files found", "dir", dir)
 		return
 	}
 
 	for _, f := range files {
 		plug, err := plugin.Open(dir + "/" + f.Name())
 		if err != nil {
-			log.Println(err)
+			log.Error("Couldn't open driver", "file", f.Name(), "error", err)
 			os.Exit(1)
 		}
 
 		name := driverName(f)
 		driver, err := plug.Lookup("New")
 		if err != nil {
-			log.Println(err)
+			log.Error("Driver doesn't implement New", "driver", name, "error", err)
 			os.Exit(1)
 		} else {
 			drivers[name] = driver
 		}
-		log.Printf("Loaded driver \"%s\"\n", name)
+		log.Info("Loaded driver", "driver", name)
 	}
 }
 
@@ -14,6 +14,8 @@ type Mock struct {
 	FakeGameStarted           bool
 	FakeIsGameOver            bool
 	FakeExecute               func(action api.Action) error
+	FakeStandings             []api.Standing
+	FakeActionTypes           []string
 	Calls                     map[string]int
 }
 
@@ -33,6 +35,11 @@ func (b *Mock) Execute(action api.Action) error {
 	return nil
 }
 
+// ActionTypes mocks the ActionTypes method defined in the ActionTyper interface
+func (b *Mock) ActionTypes() []string {
+	return b.FakeActionTypes
+}
+
 // ParseMessage mocks the ParseMessage method defined in the Driver interface
 func (b *Mock) ParseMessage(t string, content json.RawMessage) error {
 	return nil
@@ -60,6 +67,7 @@ func (b *Mock) DeactivatePlayer(number int) error {
 
 // CreateAI mocks the CreateAI method defined in the Driver interface
 func (b *Mock) CreateAI(params interface{}) (api.AI, error) {
+	b.Calls["CreateAI"]++
 	return b.FakeAI, nil
 }
 
@@ -83,3 +91,30 @@ func (b *Mock) IsGameOver() bool {
 func (b *Mock) Name() string {
 	return "mock"
 }
+
+// FinalStandings mocks the FinalStandings method defined in the ResultsReporter interface
+func (b *Mock) FinalStandings() ([]api.Standing, error) {
+	return b.FakeStandings, nil
+}
+
+// UndoerMock is a Mock which also implements the Undoer interface
+type UndoerMock struct {
+	*Mock
+	FakeUndo func() error
+}
+
+// NewUndoerMock returns a new UndoerMock instance ready to use
+func NewUndoerMock() api.Driver {
+	return &UndoerMock{
+		Mock: NewMock().(*Mock),
+	}
+}
+
+// Undo mocks the Undo method defined in the Undoer interface
+func (b *UndoerMock) Undo() error {
+	b.Calls["Undo"]++
+	if b.FakeUndo != nil {
+		return b.FakeUndo()
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package events
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/jsonpatch"
 	"github.com/svera/sackson-server/internal/messages"
 )
 
@@ -28,6 +30,8 @@ type ClientOut struct {
 	Client interfaces.Client
 	Reason string
 	Room   interfaces.Room
+	// RequestID identifies the client's message which led to this event, if any
+	RequestID string
 }
 
 // ClientJoined is an event triggered when a client joins a room
@@ -35,6 +39,8 @@ type ClientJoined struct {
 	Client       interfaces.Client
 	ClientNumber int
 	Owner        bool
+	// RequestID identifies the client's message which led to this event, if any
+	RequestID string
 }
 
 // ClientsUpdated is an event triggered when a client joins/lefts a room
@@ -48,6 +54,66 @@ type GameStatusUpdated struct {
 	Client         interfaces.Client
 	Message        interface{}
 	SequenceNumber int
+	// Patch, if not nil, holds the changes from the status with the Base sequence number,
+	// to be sent instead of the whole status
+	Patch jsonpatch.Patch
+	Base  int
+	// RequestID identifies the client's message which led to this event, if any
+	RequestID string
+}
+
+// LegalActionsUpdated is an event triggered when the actions a player
+// in turn can do are updated
+type LegalActionsUpdated struct {
+	Client         interfaces.Client
+	Actions        []messages.LegalAction
+	SequenceNumber int
+}
+
+// UndoRequested is an event triggered when a player asks to undo his/her last action
+type UndoRequested struct {
+	Client       interfaces.Client
+	PlayerNumber int
+	// Name is the name of the player in the room
+	Name string
+	// Voters are the players who have to accept the request
+	Voters []interfaces.Client
+}
+
+// UndoResolved is an event triggered when an undo request is accepted or rejected
+type UndoResolved struct {
+	Clients  []interfaces.Client
+	Accepted bool
+}
+
+// VoteUpdated is an event triggered when a proposal is made, voted or resolved
+type VoteUpdated struct {
+	Clients []interfaces.Client
+	Status  messages.VoteStatus
+}
+
+// GameAborted is an event triggered when the players of a room vote to abort its game
+type GameAborted struct {
+	Room interfaces.Room
+}
+
+// GameEnded is an event triggered when a room's game is over
+type GameEnded struct {
+	Room      interfaces.Room
+	Results   []messages.PlayerResult
+	StartedAt time.Time
+	// Actions is the number of actions successfully executed during the game
+	Actions int
+}
+
+// TurnStarted is an event triggered when it becomes a player's turn
+// in an asynchronous room
+type TurnStarted struct {
+	Room   interfaces.Room
+	Client interfaces.Client
+	// Deadline is the time when the player will be expelled if he/she doesn't play,
+	// zero if there's no deadline
+	Deadline time.Time
 }
 
 // RoomCreated is an event triggered when a room is created
@@ -55,8 +121,15 @@ type RoomCreated struct {
 	Room interfaces.Room
 }
 
+// RoomReset is an event triggered when a room goes back to its lobby state
+// after a game is over
+type RoomReset struct {
+	Room interfaces.Room
+}
+
 // RoomDestroyed is an event triggered when a room is destroyed
 type RoomDestroyed struct {
+	RoomID   string
 	GameName string
 }
 
@@ -69,4 +142,29 @@ type BotPanicked struct {
 type Error struct {
 	Client    interfaces.Client
 	ErrorText string
+	// MessageType and RequestID identify the message which caused the error, if any
+	MessageType string
+	RequestID   string
+	// Details holds more information about the error, if any
+	Details interface{}
+}
+
+// MessageError returns an Error event caused by the passed message
+func MessageError(m *interfaces.IncomingMessage, errorText string) Error {
+	return Error{Client: m.Author, ErrorText: errorText, MessageType: m.Type, RequestID: m.RequestID}
+}
+
+// detailedError is implemented by errors which carry more information than their code
+type detailedError interface {
+	Details() interface{}
+}
+
+// MessageFailed returns an Error event for the passed error caused by the passed message,
+// along with its details if it has any
+func MessageFailed(m *interfaces.IncomingMessage, err error) Error {
+	ev := MessageError(m, err.Error())
+	if d, ok := err.(detailedError); ok {
+		ev.Details = d.Details()
+	}
+	return ev
 }
@@ -0,0 +1,130 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+)
+
+// FileStore is a Store implementation that keeps matches in memory,
+// appending every new one to a file with one JSON encoded match per line
+// so they survive server restarts.
+type FileStore struct {
+	mutex   sync.RWMutex
+	file    *os.File
+	matches []Match
+}
+
+// NewFileStore returns a new FileStore instance, loading the matches
+// previously stored in the file at path. The file is created if it doesn't exist.
+func NewFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{file: f}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var m Match
+		if err = json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.matches = append(s.matches, m)
+	}
+	if err = scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save appends the passed match to the store's file
+func (s *FileStore) Save(m Match) error {
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err = s.file.Write(append(encoded, '\n')); err != nil {
+		return err
+	}
+	s.matches = append(s.matches, m)
+	return nil
+}
+
+// PlayerMatches returns up to limit matches played by the authenticated user with the passed ID,
+// most recent first
+func (s *FileStore) PlayerMatches(userID string, limit int) ([]Match, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	matches := []Match{}
+	for i := len(s.matches) - 1; i >= 0 && len(matches) < limit; i-- {
+		if _, ok := s.matches[i].seatOf(userID); ok {
+			matches = append(matches, s.matches[i])
+		}
+	}
+	return matches, nil
+}
+
+// PlayerStats returns the statistics of the authenticated user with the passed ID,
+// one entry for each game driver he/she has played, sorted by driver name
+func (s *FileStore) PlayerStats(userID string) ([]Stats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	byDriver := map[string]*Stats{}
+	rankedGames := map[string]int{}
+	ranksSum := map[string]int{}
+	scoresSum := map[string]int{}
+	for _, m := range s.matches {
+		seat, ok := m.seatOf(userID)
+		if !ok {
+			continue
+		}
+		st, ok := byDriver[m.Driver]
+		if !ok {
+			st = &Stats{Driver: m.Driver, BestScore: seat.Score}
+			byDriver[m.Driver] = st
+		}
+		st.Played++
+		scoresSum[m.Driver] += seat.Score
+		if seat.Score > st.BestScore {
+			st.BestScore = seat.Score
+		}
+		if seat.Rank > 0 {
+			rankedGames[m.Driver]++
+			ranksSum[m.Driver] += seat.Rank
+		}
+		if seat.Rank == 1 {
+			st.Won++
+		}
+	}
+
+	stats := make([]Stats, 0, len(byDriver))
+	for driver, st := range byDriver {
+		st.AverageScore = float64(scoresSum[driver]) / float64(st.Played)
+		if rankedGames[driver] > 0 {
+			st.AverageRank = float64(ranksSum[driver]) / float64(rankedGames[driver])
+		}
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Driver < stats[j].Driver
+	})
+	return stats, nil
+}
+
+// Close closes the store's file
+func (s *FileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,113 @@
+// Package history keeps a record of finished games, so players' recent matches
+// and statistics can be queried once the rooms where they were played are gone.
+package history
+
+import (
+	"time"
+
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
+)
+
+// Match holds the record of a finished game
+type Match struct {
+	RoomID    string    `json:"room_id"`
+	Driver    string    `json:"driver"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	// Duration of the game in seconds
+	Duration int    `json:"duration"`
+	Actions  int    `json:"actions"`
+	Seats    []Seat `json:"seats"`
+}
+
+// Seat holds the data and final result of a player in a match.
+// UserID is empty for bots and anonymous players, whose matches can't be queried.
+type Seat struct {
+	Number   int    `json:"number"`
+	Name     string `json:"name"`
+	UserID   string `json:"user_id,omitempty"`
+	Bot      bool   `json:"bot"`
+	BotLevel string `json:"bot_level,omitempty"`
+	// Rank is 0 if the game driver does not report final standings
+	Rank  int `json:"rank"`
+	Score int `json:"score"`
+}
+
+// Stats holds the aggregated statistics of a player for a specific game driver
+type Stats struct {
+	Driver       string  `json:"driver"`
+	Played       int     `json:"played"`
+	Won          int     `json:"won"`
+	AverageRank  float64 `json:"average_rank"`
+	AverageScore float64 `json:"average_score"`
+	BestScore    int     `json:"best_score"`
+}
+
+// Store is an interface that defines the minimum set of functions needed
+// to implement a match history storage
+type Store interface {
+	// Save stores a finished match
+	Save(m Match) error
+
+	// PlayerMatches returns up to limit matches played by the authenticated user with the passed ID,
+	// most recent first
+	PlayerMatches(userID string, limit int) ([]Match, error)
+
+	// PlayerStats returns the statistics of the authenticated user with the passed ID,
+	// one entry for each game driver he/she has played
+	PlayerStats(userID string) ([]Stats, error)
+}
+
+// RegisterEvents makes the passed store record every game that ends
+func RegisterEvents(obs interfaces.Observer, s Store, log *logger.Logger) {
+	obs.On(events.GameEnded{}, func(ev interface{}) {
+		if event, ok := ev.(events.GameEnded); ok {
+			m := NewMatch(event, time.Now())
+			go func() {
+				if err := s.Save(m); err != nil {
+					log.Error("Couldn't save match", "room", m.RoomID, "driver", m.Driver, "error", err)
+				}
+			}()
+		}
+	})
+}
+
+// NewMatch returns a match record built from the passed game ended event
+func NewMatch(event events.GameEnded, endedAt time.Time) Match {
+	m := Match{
+		RoomID:    event.Room.ID(),
+		Driver:    event.Room.GameDriverName(),
+		StartedAt: event.StartedAt,
+		EndedAt:   endedAt,
+		Duration:  int(endedAt.Sub(event.StartedAt).Seconds()),
+		Actions:   event.Actions,
+		Seats:     make([]Seat, 0, len(event.Results)),
+	}
+	for _, r := range event.Results {
+		m.Seats = append(m.Seats, Seat{
+			Number:   r.Number,
+			Name:     r.Name,
+			UserID:   r.UserID,
+			Bot:      r.Bot,
+			BotLevel: r.BotLevel,
+			Rank:     r.Rank,
+			Score:    r.Score,
+		})
+	}
+	return m
+}
+
+// seatOf returns the seat of the authenticated user with the passed ID in the match, if any
+func (m Match) seatOf(userID string) (Seat, bool) {
+	if userID == "" {
+		return Seat{}, false
+	}
+	for _, s := range m.Seats {
+		if !s.Bot && s.UserID == userID {
+			return s, true
+		}
+	}
+	return Seat{}, false
+}
@@ -0,0 +1,123 @@
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func setup(t *testing.T) (path string, s *FileStore) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "history.jsonl")
+	if s, err = NewFileStore(path); err != nil {
+		t.Fatal(err)
+	}
+	return path, s
+}
+
+func match(roomID string, driver string, seats ...Seat) Match {
+	return Match{
+		RoomID:    roomID,
+		Driver:    driver,
+		StartedAt: time.Now(),
+		EndedAt:   time.Now(),
+		Seats:     seats,
+	}
+}
+
+func TestSavedMatchesSurviveReopening(t *testing.T) {
+	path, s := setup(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	s.Save(match("AAAAA", "acquire", Seat{Name: "Sergio", UserID: "u1", Rank: 1}))
+	s.Save(match("BBBBB", "acquire", Seat{Name: "Miguel", UserID: "u2", Rank: 1}))
+	s.Close()
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if len(reopened.matches) != 2 {
+		t.Errorf("Store must have 2 matches after reopening it, got %d", len(reopened.matches))
+	}
+}
+
+func TestPlayerMatches(t *testing.T) {
+	path, s := setup(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+
+	s.Save(match("AAAAA", "acquire", Seat{Name: "Sergio", UserID: "u1"}))
+	s.Save(match("BBBBB", "acquire", Seat{Name: "Miguel", UserID: "u2"}))
+	s.Save(match("CCCCC", "acquire", Seat{Name: "Sergio", UserID: "u1"}, Seat{Name: "Miguel", UserID: "u2"}))
+	s.Save(match("DDDDD", "acquire", Seat{Name: "Sergio", UserID: "u1", Bot: true}))
+
+	s.Save(match("EEEEE", "acquire", Seat{Name: "Sergio"}))
+
+	matches, _ := s.PlayerMatches("u1", 10)
+	if len(matches) != 2 {
+		t.Fatalf("Player must have played 2 matches, got %d", len(matches))
+	}
+	if matches[0].RoomID != "CCCCC" {
+		t.Errorf("Most recent match must go first, got %s", matches[0].RoomID)
+	}
+
+	if matches, _ = s.PlayerMatches("u1", 1); len(matches) != 1 {
+		t.Errorf("Number of returned matches must be limited, got %d", len(matches))
+	}
+}
+
+func TestPlayerStats(t *testing.T) {
+	path, s := setup(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+
+	s.Save(match("AAAAA", "acquire", Seat{Name: "Sergio", UserID: "u1", Rank: 1, Score: 100}))
+	s.Save(match("BBBBB", "acquire", Seat{Name: "Sergio", UserID: "u1", Rank: 3, Score: 50}))
+	s.Save(match("CCCCC", "other", Seat{Name: "Sergio", UserID: "u1", Rank: 2, Score: 10}))
+
+	stats, _ := s.PlayerStats("u1")
+	expected := []Stats{
+		{Driver: "acquire", Played: 2, Won: 1, AverageRank: 2, AverageScore: 75, BestScore: 100},
+		{Driver: "other", Played: 1, Won: 0, AverageRank: 2, AverageScore: 10, BestScore: 10},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d stats entries, got %d", len(expected), len(stats))
+	}
+	for i := range expected {
+		if stats[i] != expected[i] {
+			t.Errorf("Expected stats %v, got %v", expected[i], stats[i])
+		}
+	}
+}
+
+func TestPlayerMatchesEndpoint(t *testing.T) {
+	path, s := setup(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+	s.Save(match("AAAAA", "acquire", Seat{Name: "Sergio", UserID: "u1"}))
+
+	r := mux.NewRouter()
+	RegisterRoutes(r, s)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/players/u1/matches?limit=5", nil))
+
+	var matches []Match
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &matches); err != nil || len(matches) != 1 {
+		t.Errorf("Endpoint must return 1 match, got %s", w.Body.String())
+	}
+}
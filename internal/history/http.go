@@ -0,0 +1,57 @@
+package history
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/svera/sackson-server/internal/httpjson"
+)
+
+const (
+	defaultMatchesLimit = 20
+	maxMatchesLimit     = 100
+)
+
+// RegisterRoutes adds the history query endpoints to the passed router,
+// where players are identified by their authenticated user ID:
+//   GET /players/{user}/matches?limit=20 returns the player's most recent matches
+//   GET /players/{user}/stats returns the player's statistics for each game driver
+func RegisterRoutes(r *mux.Router, s Store) {
+	r.HandleFunc("/players/{user}/matches", playerMatchesHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/players/{user}/stats", playerStatsHandler(s)).Methods(http.MethodGet)
+}
+
+func playerMatchesHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultMatchesLimit
+		if value := r.FormValue("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			if limit > maxMatchesLimit {
+				limit = maxMatchesLimit
+			}
+		}
+
+		matches, err := s.PlayerMatches(mux.Vars(r)["user"], limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		httpjson.Write(w, matches)
+	}
+}
+
+func playerStatsHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := s.PlayerStats(mux.Vars(r)["user"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		httpjson.Write(w, stats)
+	}
+}
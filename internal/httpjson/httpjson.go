@@ -0,0 +1,19 @@
+// Package httpjson writes the JSON responses of the server's HTTP APIs
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Write sends the passed value encoded as JSON in the response,
+// or an internal server error if it can't be encoded
+func Write(w http.ResponseWriter, v interface{}) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(encoded, '\n'))
+}
@@ -0,0 +1,21 @@
+package httpjson
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, map[string]int{"a": 1})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != "{\"a\":1}\n" {
+		t.Errorf("Value must be written as JSON, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	Write(w, func() {})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Values which can't be encoded must return an internal server error, got %d", w.Code)
+	}
+}
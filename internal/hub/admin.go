@@ -0,0 +1,269 @@
+package hub
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/svera/sackson-server/internal/ban"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// RoomInfo holds the state of a room, as shown to server operators
+type RoomInfo struct {
+	ID      string   `json:"id"`
+	Driver  string   `json:"driver"`
+	Owner   string   `json:"owner"`
+	Players []string `json:"players"`
+	Started bool     `json:"started"`
+	Over    bool     `json:"over"`
+	Async   bool     `json:"async"`
+	Age     int      `json:"age"` // In seconds
+}
+
+// ClientInfo holds the data of a connected client, as shown to server operators
+type ClientInfo struct {
+	// SessionID identifies the client in admin operations
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	UserID    string `json:"user_id,omitempty"`
+	Room      string `json:"room,omitempty"`
+}
+
+// The following methods can be called from any goroutine, as they are run
+// from the hub's goroutine, waiting for them to finish.
+
+// RoomsInfo returns the state of all the rooms in the hub, ordered by ID
+func (h *Hub) RoomsInfo() []RoomInfo {
+	info := []RoomInfo{}
+	h.do(func() {
+		now := time.Now()
+		for _, r := range h.rooms {
+			ri := RoomInfo{
+				ID:      r.ID(),
+				Driver:  r.GameDriverName(),
+				Players: []string{},
+				Started: r.GameStarted(),
+				Over:    r.IsGameOver(),
+				Async:   r.IsAsync(),
+				Age:     int(now.Sub(r.CreatedAt()).Seconds()),
+			}
+			if r.Owner() != nil {
+				ri.Owner = r.Owner().Name()
+			}
+			numbers := []int{}
+			clients := r.Clients()
+			for n := range clients {
+				numbers = append(numbers, n)
+			}
+			sort.Ints(numbers)
+			for _, n := range numbers {
+				ri.Players = append(ri.Players, clients[n].Name())
+			}
+			info = append(info, ri)
+		}
+	})
+	sort.Slice(info, func(i, j int) bool {
+		return info[i].ID < info[j].ID
+	})
+	return info
+}
+
+// ClientsInfo returns the connected clients, indexed by the name of the game they use
+func (h *Hub) ClientsInfo() map[string][]ClientInfo {
+	info := map[string][]ClientInfo{}
+	h.do(func() {
+		for game, clients := range h.clients {
+			info[game] = []ClientInfo{}
+			for _, cl := range clients {
+				ci := ClientInfo{SessionID: cl.SessionID(), Name: cl.Name(), UserID: cl.UserID()}
+				if cl.Room() != nil {
+					ci.Room = cl.Room().ID()
+				}
+				info[game] = append(info[game], ci)
+			}
+		}
+	})
+	return info
+}
+
+// DestroyRoom expels all clients from the room with the passed ID, with the passed reason code, and destroys it
+func (h *Hub) DestroyRoom(id string, reason string) error {
+	var err error
+	if reason == "" {
+		reason = messages.ReasonRoomDestroyedByAdmin
+	}
+	h.do(func() {
+		if _, ok := h.rooms[id]; !ok {
+			err = errors.New(InexistentRoom)
+			return
+		}
+		h.log.Info("Room destroyed by an administrator", "room", id, "reason", reason)
+		h.destroyRoom(id, reason)
+	})
+	return err
+}
+
+// KickClient expels the client with the passed session identifier from his/her room,
+// if he/she is in one, and disconnects him/her from the server
+func (h *Hub) KickClient(sessionID string) error {
+	var err error
+	h.do(func() {
+		cl := h.clientBySession(sessionID)
+		if cl == nil {
+			err = errors.New(InexistentClient)
+			return
+		}
+		h.log.Info("Client kicked by an administrator", "client", cl.Name())
+		h.expelClient(cl, messages.ReasonPlayerKicked)
+	})
+	return err
+}
+
+// BanEntries returns the identifiers banned from the server
+func (h *Hub) BanEntries() []ban.Entry {
+	return h.BanList.Entries()
+}
+
+// Ban bans the identifier of the passed entry from the server,
+// disconnecting the connected clients who have it
+func (h *Hub) Ban(e ban.Entry) error {
+	if err := h.BanList.Add(e); err != nil {
+		return err
+	}
+	h.log.Info("Identifier banned by an administrator", "kind", e.Kind, "value", e.Value)
+	h.do(h.expelBannedClients)
+	return nil
+}
+
+// Unban lifts the server ban on the passed identifier
+func (h *Hub) Unban(kind string, value string) error {
+	if err := h.BanList.Remove(kind, value); err != nil {
+		return err
+	}
+	h.log.Info("Identifier unbanned by an administrator", "kind", kind, "value", value)
+	return nil
+}
+
+// BanClient bans the user, session and address of the client with the passed
+// session identifier from the server, and disconnects him/her
+func (h *Hub) BanClient(sessionID string, reason string) error {
+	var err error
+	h.do(func() {
+		cl := h.clientBySession(sessionID)
+		if cl == nil {
+			err = errors.New(InexistentClient)
+			return
+		}
+		if err = h.BanList.BanClient(cl.UserID(), cl.SessionID(), cl.Address(), reason); err != nil {
+			return
+		}
+		h.log.Info("Client banned by an administrator", "client", cl.Name())
+		h.expelBannedClients()
+	})
+	return err
+}
+
+// expelBannedClients disconnects the connected clients banned from the server,
+// removing them from their rooms first
+func (h *Hub) expelBannedClients() {
+	banned := []interfaces.Client{}
+	for _, clients := range h.clients {
+		for _, cl := range clients {
+			if h.BanList.Banned(cl.UserID(), cl.SessionID(), cl.Address()) {
+				banned = append(banned, cl)
+			}
+		}
+	}
+	for _, cl := range banned {
+		h.observer.Trigger(events.Error{Client: cl, ErrorText: Banned})
+		h.expelClient(cl, messages.ReasonPlayerBanned)
+	}
+}
+
+// expelClient removes the client from his/her room, if he/she is in one,
+// with the passed reason code, and disconnects him/her from the server
+func (h *Hub) expelClient(cl interfaces.Client, reason string) {
+	if r := cl.Room(); r != nil {
+		r.RemoveClient(cl)
+		h.observer.Trigger(events.ClientOut{Client: cl, Reason: reason, Room: r})
+	}
+	wg.Wait()
+	h.removeClient(cl)
+}
+
+// MessageClient sends a notice to the client with the passed session identifier
+func (h *Hub) MessageClient(sessionID string, text string) error {
+	var err error
+	h.do(func() {
+		cl := h.clientBySession(sessionID)
+		if cl == nil {
+			err = errors.New(InexistentClient)
+			return
+		}
+		wg.Add(1)
+		go h.sendMessage(cl, messages.Notice{Message: text}, messages.TypeNotice)
+	})
+	return err
+}
+
+// Broadcast sends a notice to all connected clients
+func (h *Hub) Broadcast(text string) {
+	h.do(func() {
+		for _, clients := range h.clients {
+			wg.Add(len(clients))
+			for _, cl := range clients {
+				go h.sendMessage(cl, messages.Notice{Message: text}, messages.TypeNotice)
+			}
+		}
+	})
+}
+
+// Maintenance returns true if the hub is in maintenance mode
+func (h *Hub) Maintenance() bool {
+	var enabled bool
+	h.do(func() {
+		enabled = h.maintenance
+	})
+	return enabled
+}
+
+// SetMaintenance enables or disables maintenance mode, in which no new rooms
+// can be created but existing games can be played until they finish
+func (h *Hub) SetMaintenance(enabled bool) {
+	h.do(func() {
+		if h.maintenance != enabled {
+			h.log.Info("Maintenance mode changed", "enabled", enabled)
+		}
+		h.maintenance = enabled
+		if !enabled {
+			h.startWaitingTournamentTables()
+		}
+	})
+}
+
+func (h *Hub) clientBySession(sessionID string) interfaces.Client {
+	if sessionID == "" {
+		return nil
+	}
+	for _, clients := range h.clients {
+		for _, cl := range clients {
+			if cl.SessionID() == sessionID {
+				return cl
+			}
+		}
+	}
+	return nil
+}
+
+// do runs the passed function from the hub's goroutine, waiting until it finishes
+func (h *Hub) do(task func()) {
+	done := make(chan struct{})
+	h.tasks <- func() {
+		defer close(done)
+		task()
+	}
+	<-done
+}
@@ -1,24 +1,35 @@
 package hub
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
 	"time"
 
-	"strings"
-
 	"github.com/svera/sackson-server/api"
 	"github.com/svera/sackson-server/internal/config"
 	"github.com/svera/sackson-server/internal/drivers"
 	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
 	"github.com/svera/sackson-server/internal/messages"
 	"github.com/svera/sackson-server/internal/room"
 )
 
 // NewRoom holds a factory function that can be replaced in tests, so it returns a mocked Room instead
-var NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer) interfaces.Room {
-	return room.New(ID, b, owner, messages, unregister, cfg, ob)
+var NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+	return room.New(ID, b, owner, messages, unregister, cfg, ob, log)
+}
+
+// GenerateSessionID returns a random hexadecimal session identifier,
+// hard enough to guess to be used to get back to asynchronous rooms
+var GenerateSessionID = func() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
 }
 
 // GenerateID returns a random string locator
@@ -42,18 +53,61 @@ func (h *Hub) createRoomAction(m *interfaces.IncomingMessage) error {
 	if err = json.Unmarshal(m.Content, &parsed); err != nil {
 		return err
 	}
+	if err = h.checkRoomsLimits(m.Author); err != nil {
+		return err
+	}
 	if driver, err = drivers.Create(parsed.DriverName); err != nil {
 		return err
 	}
 
-	if strings.TrimSpace(parsed.ClientName) != "" {
-		m.Author.SetName(parsed.ClientName)
+	if err = h.setClientName(m.Author, parsed.ClientName); err != nil {
+		return err
+	}
+	ID := h.createRoom(driver, m.Author, m.RequestID)
+	if parsed.Async {
+		h.rooms[ID].SetAsync(true)
+		h.setRoomTimer(ID, time.Hour*h.configuration.AsyncTimeout)
+	}
+	return nil
+}
+
+// checkNewRoom returns an error if the server is not accepting new rooms
+// owned by the passed client, which is the case while shutting down or in maintenance mode,
+// or if the client has reached the rooms limits
+func (h *Hub) checkNewRoom(cl interfaces.Client) error {
+	if h.shuttingDown {
+		return errors.New(ServerShuttingDown)
+	}
+	if h.maintenance {
+		return errors.New(UnderMaintenance)
+	}
+	return h.checkRoomsLimits(cl)
+}
+
+// checkRoomsLimits returns an error if the client can't create a room
+// because of the maximum number of rooms allowed
+func (h *Hub) checkRoomsLimits(cl interfaces.Client) error {
+	if max := h.configuration.MaxRooms; max > 0 && len(h.rooms) >= max {
+		return errors.New(RoomsLimitReached)
+	}
+	max := h.configuration.MaxRoomsPerAddress
+	if max <= 0 {
+		return nil
+	}
+	owned := 0
+	for _, r := range h.rooms {
+		// Clients with no known address are limited by themselves
+		if owner := r.Owner(); owner == cl || (owner != nil && cl.Address() != "" && owner.Address() == cl.Address()) {
+			owned++
+		}
+	}
+	if owned >= max {
+		return errors.New(AddressRoomsLimitReached)
 	}
-	h.createRoom(driver, m.Author)
 	return nil
 }
 
-func (h *Hub) createRoom(b api.Driver, owner interfaces.Client) string {
+func (h *Hub) createRoom(b api.Driver, owner interfaces.Client, requestID string) string {
 	exists := true
 	var ID string
 	for exists {
@@ -61,22 +115,32 @@ func (h *Hub) createRoom(b api.Driver, owner interfaces.Client) string {
 		_, exists = h.rooms[ID]
 	}
 
-	h.rooms[ID] = NewRoom(ID, b, owner, h.Messages, h.Unregister, h.configuration, h.observer)
+	h.rooms[ID] = NewRoom(ID, b, owner, h.Messages, h.Unregister, h.configuration, h.observer, h.roomsLog)
+	h.rooms[ID].SetNamePolicy(h.Names)
 
-	timer := time.AfterFunc(time.Second*h.configuration.Timeout, func() {
-		if h.configuration.Debug {
-			log.Printf("Destroying room %s due to timeout\n", ID)
-		}
-		h.destroyRoom(ID, messages.ReasonRoomDestroyedTimeout)
-	})
-	h.rooms[ID].SetTimer(timer)
+	h.setRoomTimer(ID, time.Second*h.configuration.Timeout)
 
 	h.observer.Trigger(events.RoomCreated{Room: h.rooms[ID]})
 
-	if h.configuration.Debug {
-		log.Printf("Room %s created\n", ID)
-	}
-	h.rooms[ID].AddHuman(owner)
+	h.log.Debug("Room created", "room", ID, "driver", b.Name(), "client", owner.Name(), "request", requestID)
+	h.rooms[ID].AddHuman(owner, requestID)
 
 	return ID
 }
+
+// setRoomTimer sets the time after which the room will be destroyed,
+// replacing its previous timer. Asynchronous rooms with no lifetime are never destroyed by timeout.
+func (h *Hub) setRoomTimer(ID string, d time.Duration) {
+	r := h.rooms[ID]
+	if r.Timer() != nil {
+		r.Timer().Stop()
+	}
+	if r.IsAsync() && d <= 0 {
+		r.SetTimer(nil)
+		return
+	}
+	r.SetTimer(time.AfterFunc(d, func() {
+		h.log.Debug("Destroying room due to timeout", "room", ID)
+		h.destroyRoom(ID, messages.ReasonRoomDestroyedTimeout)
+	}))
+}
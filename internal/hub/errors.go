@@ -8,4 +8,25 @@ const (
 	InexistentRoom    = "inexistent_room"
 	InexistentDriver  = "inexistent_driver"
 	NotInARoom        = "not_in_a_room"
+	RatingsDisabled   = "ratings_disabled"
+
+	MatchmakingDisabled  = "matchmaking_disabled"
+	AlreadyInARoom       = "already_in_a_room"
+	AlreadyInMatchmaking = "already_in_matchmaking"
+	NotInMatchmaking     = "not_in_matchmaking"
+	InvalidPlayersNumber = "invalid_players_number"
+	BotsNotAdded         = "bots_not_added"
+	ServerShuttingDown   = "server_shutting_down"
+
+	ServerFull               = "server_full"
+	UnderMaintenance         = "under_maintenance"
+	RoomsLimitReached        = "rooms_limit_reached"
+	AddressRoomsLimitReached = "address_rooms_limit_reached"
+
+	UnsupportedProtocolVersion = "unsupported_protocol_version"
+	ResumeDisabled             = "resume_disabled"
+	InexistentMessage          = "inexistent_message"
+	MessagesLost               = "messages_lost"
+
+	Banned = "banned"
 )
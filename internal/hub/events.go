@@ -36,8 +36,92 @@ func (h *Hub) registerEvents() {
 
 	h.observer.On(events.GameStatusUpdated{}, func(ev interface{}) {
 		if event, ok := ev.(events.GameStatusUpdated); ok {
+			out := &outgoing{typeName: messages.TypeUpdateGameStatus, message: event.Message, seq: event.SequenceNumber, requestID: event.RequestID}
+			if event.Patch != nil {
+				out.typeName, out.message = messages.TypeStatusPatch, messages.StatusPatch{Base: event.Base, Operations: event.Patch}
+			}
+			wg.Add(1)
+			go h.send(event.Client, out)
+		}
+	})
+
+	h.observer.On(events.LegalActionsUpdated{}, func(ev interface{}) {
+		if event, ok := ev.(events.LegalActionsUpdated); ok {
+			message := messages.LegalActions{
+				SequenceNumber: event.SequenceNumber,
+				Actions:        event.Actions,
+			}
+
 			wg.Add(1)
-			go h.sendMessage(event.Client, event.Message, messages.TypeUpdateGameStatus, event.SequenceNumber)
+			go h.sendMessage(event.Client, message, messages.TypeLegalActions)
+		}
+	})
+
+	h.observer.On(events.UndoRequested{}, func(ev interface{}) {
+		if event, ok := ev.(events.UndoRequested); ok {
+			message := messages.UndoRequested{
+				Number: event.PlayerNumber,
+				Name:   event.Name,
+			}
+
+			wg.Add(len(event.Voters))
+			for _, cl := range event.Voters {
+				go h.sendMessage(cl, message, messages.TypeUndoRequested)
+			}
+		}
+	})
+
+	h.observer.On(events.UndoResolved{}, func(ev interface{}) {
+		if event, ok := ev.(events.UndoResolved); ok {
+			message := messages.UndoResult{
+				Accepted: event.Accepted,
+			}
+
+			wg.Add(len(event.Clients))
+			for _, cl := range event.Clients {
+				go h.sendMessage(cl, message, messages.TypeUndoResult)
+			}
+		}
+	})
+
+	h.observer.On(events.VoteUpdated{}, func(ev interface{}) {
+		if event, ok := ev.(events.VoteUpdated); ok {
+			wg.Add(len(event.Clients))
+			for _, cl := range event.Clients {
+				go h.sendMessage(cl, event.Status, messages.TypeVoteStatus)
+			}
+		}
+	})
+
+	h.observer.On(events.GameAborted{}, func(ev interface{}) {
+		if event, ok := ev.(events.GameAborted); ok {
+			id := event.Room.ID()
+			h.log.Debug("Game aborted by its players", "room", id)
+			// The room is destroyed once it finishes handling the vote
+			h.runLater(func() {
+				h.destroyRoom(id, messages.ReasonRoomDestroyedGameAborted)
+			})
+		}
+	})
+
+	h.observer.On(events.GameEnded{}, func(ev interface{}) {
+		if event, ok := ev.(events.GameEnded); ok {
+			message := messages.GameOver{
+				Results: event.Results,
+			}
+
+			h.log.Debug("Game ended", "room", event.Room.ID(), "driver", event.Room.GameDriverName())
+			humans := event.Room.HumanClients()
+			wg.Add(len(humans))
+			for _, cl := range humans {
+				go h.sendMessage(cl, message, messages.TypeGameOver)
+			}
+		}
+	})
+
+	h.observer.On(events.TurnStarted{}, func(ev interface{}) {
+		if event, ok := ev.(events.TurnStarted); ok {
+			h.log.Debug("Turn started in asynchronous room", "room", event.Room.ID(), "driver", event.Room.GameDriverName(), "client", event.Client.Name())
 		}
 	})
 
@@ -51,6 +135,16 @@ func (h *Hub) registerEvents() {
 		}
 	})
 
+	h.observer.On(events.RoomReset{}, func(ev interface{}) {
+		if event, ok := ev.(events.RoomReset); ok {
+			gameClients := h.clients[event.Room.GameDriverName()]
+			wg.Add(len(gameClients))
+			for _, cl := range gameClients {
+				go h.sendMessage(cl, h.createUpdatedRoomListMessage(), messages.TypeRoomsList)
+			}
+		}
+	})
+
 	h.observer.On(events.RoomDestroyed{}, func(ev interface{}) {
 		if event, ok := ev.(events.RoomDestroyed); ok {
 			gameClients := h.clients[event.GameName]
@@ -63,7 +157,9 @@ func (h *Hub) registerEvents() {
 
 	h.observer.On(events.ClientRegistered{}, func(ev interface{}) {
 		if event, ok := ev.(events.ClientRegistered); ok {
-			wg.Add(1)
+			wg.Add(3)
+			go h.sendMessage(event.Client, h.protocolMessage(event.Client), messages.TypeProtocol)
+			go h.sendMessage(event.Client, messages.Session{ID: event.Client.SessionID()}, messages.TypeSession)
 			go h.sendMessage(event.Client, h.createUpdatedRoomListMessage(), messages.TypeRoomsList)
 		}
 	})
@@ -75,6 +171,10 @@ func (h *Hub) registerEvents() {
 				return
 			}
 
+			if room.IsAsync() {
+				room.DetachClient(event.Client)
+				return
+			}
 			room.RemoveClient(event.Client)
 			if len(room.HumanClients()) == 0 && !room.IsToBeDestroyed() {
 				h.destroyRoom(room.ID(), messages.ReasonRoomDestroyedNoClients)
@@ -92,7 +192,7 @@ func (h *Hub) registerEvents() {
 				h.destroyRoom(event.Room.ID(), messages.ReasonRoomDestroyedNoClients)
 			}
 			wg.Add(1)
-			go h.sendMessage(event.Client, message, messages.TypeClientOut)
+			go h.send(event.Client, &outgoing{typeName: messages.TypeClientOut, message: message, requestID: event.RequestID})
 		}
 	})
 
@@ -105,7 +205,7 @@ func (h *Hub) registerEvents() {
 			}
 
 			wg.Add(1)
-			go h.sendMessage(event.Client, message, messages.TypeJoinedRoom)
+			go h.send(event.Client, &outgoing{typeName: messages.TypeJoinedRoom, message: message, requestID: event.RequestID})
 		}
 	})
 
@@ -132,10 +232,18 @@ func (h *Hub) registerEvents() {
 		if event, ok := ev.(events.Error); ok {
 			message := messages.Error{
 				Description: event.ErrorText,
+				Type:        event.MessageType,
+				RequestID:   event.RequestID,
+				Details:     event.Details,
 			}
 
+			if event.MessageType != "" {
+				h.log.Debug("Message rejected", "client", event.Client.Name(), "type", event.MessageType, "request", event.RequestID, "error", event.ErrorText)
+			}
 			wg.Add(1)
-			go h.sendMessage(event.Client, message, messages.TypeError)
+			go h.send(event.Client, &outgoing{typeName: messages.TypeError, message: message, requestID: event.RequestID})
 		}
 	})
+
+	h.registerTournamentEvents()
 }
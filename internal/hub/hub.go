@@ -1,18 +1,24 @@
 package hub
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"math/rand"
 	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/svera/sackson-server/internal/ban"
+	"github.com/svera/sackson-server/internal/codec"
 	"github.com/svera/sackson-server/internal/config"
 	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
 	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/metrics"
+	"github.com/svera/sackson-server/internal/names"
+	"github.com/svera/sackson-server/internal/rating"
+	"github.com/svera/sackson-server/internal/snapshot"
+	"github.com/svera/sackson-server/internal/tournament"
 )
 
 var (
@@ -44,6 +50,48 @@ type Hub struct {
 	configuration *config.Config
 
 	observer interfaces.Observer
+
+	matchmaker *matchmaker
+
+	// Tournaments holds all tournaments created in the hub
+	Tournaments *tournament.Manager
+
+	// Clients of tournament participants, indexed by tournament ID and participant ID
+	tournamentClients map[string]map[string]interfaces.Client
+
+	// Tournament tables waiting for the server to accept new rooms, indexed by tournament ID and table number
+	waitingTables map[string]map[int]bool
+
+	// Functions to be run from the hub's goroutine
+	tasks chan func()
+
+	// Ratings store used to answer leaderboard requests, leave nil if ratings are disabled
+	Ratings rating.Store
+
+	log *logger.Logger
+
+	// Logger passed to the rooms created by the hub
+	roomsLog *logger.Logger
+
+	// Snapshots store where running games are persisted on shutdown, leave nil to disable it
+	Snapshots snapshot.Store
+
+	// BanList holds the users, sessions and addresses banned from the whole server
+	BanList *ban.List
+
+	// Names is the policy the names chosen by players must follow, shared by all rooms
+	Names *names.Policy
+
+	// shuttingDown is set to true once the server starts shutting down,
+	// refusing from then on the creation of new games
+	shuttingDown bool
+
+	// maintenance mode refuses the creation of new rooms, letting existing games finish
+	maintenance bool
+
+	// Messages sent to every session, indexed by session identifier
+	outboxes      map[string]*outbox
+	outboxesMutex sync.Mutex
 }
 
 func init() {
@@ -52,7 +100,7 @@ func init() {
 }
 
 // New returns a new Hub instance
-func New(cfg *config.Config, obs interfaces.Observer) *Hub {
+func New(cfg *config.Config, obs interfaces.Observer, log *logger.Logger) *Hub {
 	h := &Hub{
 		Messages:      make(chan *interfaces.IncomingMessage),
 		Register:      make(chan interfaces.Client),
@@ -61,6 +109,19 @@ func New(cfg *config.Config, obs interfaces.Observer) *Hub {
 		rooms:         make(map[string]interfaces.Room),
 		configuration: cfg,
 		observer:      obs,
+		matchmaker:    &matchmaker{},
+		Tournaments: tournament.NewManager(func() string {
+			return GenerateID()
+		}),
+		tournamentClients: map[string]map[string]interfaces.Client{},
+		waitingTables:     map[string]map[int]bool{},
+		tasks:             make(chan func()),
+		log:               log.Subsystem("hub"),
+		roomsLog:          log.Subsystem("room"),
+		maintenance:       cfg.Maintenance,
+		outboxes:          map[string]*outbox{},
+		BanList:           ban.NewList(),
+		Names:             names.NewPolicy(cfg.Names),
 	}
 
 	h.registerEvents()
@@ -70,19 +131,40 @@ func New(cfg *config.Config, obs interfaces.Observer) *Hub {
 
 // Run listens for messages coming from several channels and acts accordingly
 func (h *Hub) Run() {
+	// A nil channel blocks forever, so no matchmaking rounds are run if it's disabled
+	var matchmakingRound <-chan time.Time
+	if h.configuration.MatchmakingInterval > 0 {
+		ticker := time.NewTicker(time.Second * h.configuration.MatchmakingInterval)
+		defer ticker.Stop()
+		matchmakingRound = ticker.C
+	}
+
 	for {
 		select {
 
 		case cl := <-h.Register:
+			if max := h.configuration.MaxClientsPerGame; max > 0 && h.NumberClients(cl.Game()) >= max {
+				h.rejectClient(cl, ServerFull)
+				break
+			}
 			mutex.Lock()
 			h.clients[cl.Game()] = append(h.clients[cl.Game()], cl)
 			mutex.Unlock()
-			cl.SetName(fmt.Sprintf("Player %d", h.NumberClients(cl.Game())))
+			// Authenticated clients already have a name
+			if cl.Name() == "" {
+				cl.SetName(fmt.Sprintf("Player %d", h.NumberClients(cl.Game())))
+			}
+			if cl.SessionID() == "" {
+				cl.SetSessionID(GenerateSessionID())
+			}
+			if cl.ProtocolVersion() == 0 {
+				cl.SetProtocolVersion(messages.ProtocolV1)
+			}
+			h.attachOutbox(cl)
 			h.observer.Trigger(events.ClientRegistered{Client: cl})
+			h.reattach(cl)
 
-			if h.configuration.Debug {
-				log.Printf("Client added to hub using game '%s', number of connected clients: %d\n", cl.Game(), len(h.clients))
-			}
+			h.log.Debug("Client added to hub", "client", cl.Name(), "driver", cl.Game(), "clients", len(h.clients[cl.Game()]))
 
 		case cl := <-h.Unregister:
 			for _, val := range h.clients[cl.Game()] {
@@ -96,6 +178,12 @@ func (h *Hub) Run() {
 		case m := <-h.Messages:
 			h.parseMessage(m)
 
+		case now := <-matchmakingRound:
+			h.match(now)
+
+		case task := <-h.tasks:
+			task()
+
 		}
 	}
 }
@@ -104,6 +192,12 @@ func (h *Hub) Run() {
 // related to a particular game, but to the server) or a room one (specific to
 // a particular room)
 func (h *Hub) parseMessage(m *interfaces.IncomingMessage) {
+	if m.Rejected != "" {
+		h.observer.Trigger(events.MessageError(m, m.Rejected))
+		return
+	}
+	metrics.MessagesReceived.Inc(m.Type)
+	h.log.Debug("Message received", "client", m.Author.Name(), "type", m.Type, "request", m.RequestID)
 	if h.isControlMessage(m) {
 		h.parseControlMessage(m)
 	} else {
@@ -114,9 +208,19 @@ func (h *Hub) parseMessage(m *interfaces.IncomingMessage) {
 func (h *Hub) isControlMessage(m *interfaces.IncomingMessage) bool {
 	switch m.Type {
 	case
+		messages.TypeHello,
+		messages.TypeAck,
+		messages.TypeResume,
 		messages.TypeCreateRoom,
 		messages.TypeJoinRoom,
-		messages.TypeTerminateRoom:
+		messages.TypeTerminateRoom,
+		messages.TypeRequestLeaderboard,
+		messages.TypeJoinMatchmaking,
+		messages.TypeLeaveMatchmaking,
+		messages.TypeCreateTournament,
+		messages.TypeJoinTournament,
+		messages.TypeStartTournament,
+		messages.TypeTournamentInfo:
 		return true
 	}
 	return false
@@ -124,8 +228,26 @@ func (h *Hub) isControlMessage(m *interfaces.IncomingMessage) bool {
 
 func (h *Hub) parseControlMessage(m *interfaces.IncomingMessage) {
 	var err error
+	if h.shuttingDown && startsGame(m) {
+		h.observer.Trigger(events.MessageError(m, ServerShuttingDown))
+		return
+	}
+	if h.maintenance && createsRoom(m) {
+		h.observer.Trigger(events.MessageError(m, UnderMaintenance))
+		return
+	}
+
 	switch m.Type {
 
+	case messages.TypeHello:
+		err = h.helloAction(m)
+
+	case messages.TypeAck:
+		err = h.ackAction(m)
+
+	case messages.TypeResume:
+		err = h.resumeAction(m)
+
 	case messages.TypeCreateRoom:
 		err = h.createRoomAction(m)
 
@@ -134,27 +256,82 @@ func (h *Hub) parseControlMessage(m *interfaces.IncomingMessage) {
 
 	case messages.TypeTerminateRoom:
 		err = h.terminateRoomAction(m)
+
+	case messages.TypeRequestLeaderboard:
+		err = h.leaderboardAction(m)
+
+	case messages.TypeJoinMatchmaking:
+		err = h.joinMatchmakingAction(m)
+
+	case messages.TypeLeaveMatchmaking:
+		err = h.leaveMatchmakingAction(m)
+
+	case messages.TypeCreateTournament:
+		err = h.createTournamentAction(m)
+
+	case messages.TypeJoinTournament:
+		err = h.joinTournamentAction(m)
+
+	case messages.TypeStartTournament:
+		err = h.startTournamentAction(m)
+
+	case messages.TypeTournamentInfo:
+		err = h.tournamentInfoAction(m)
 	}
 
 	if err != nil {
-		h.observer.Trigger(events.Error{Client: m.Author, ErrorText: err.Error()})
+		h.observer.Trigger(events.MessageFailed(m, err))
 	}
 }
 
+// startsGame returns true if the message can lead to a new game being played,
+// which is not allowed while the server is shutting down
+func startsGame(m *interfaces.IncomingMessage) bool {
+	switch m.Type {
+	case
+		messages.TypeCreateRoom,
+		messages.TypeJoinRoom,
+		messages.TypeJoinMatchmaking,
+		messages.TypeCreateTournament,
+		messages.TypeStartTournament:
+		return true
+	}
+	return false
+}
+
+// createsRoom returns true if the message can lead to new rooms being created,
+// which is not allowed in maintenance mode
+func createsRoom(m *interfaces.IncomingMessage) bool {
+	switch m.Type {
+	case
+		messages.TypeCreateRoom,
+		messages.TypeJoinMatchmaking,
+		messages.TypeCreateTournament,
+		messages.TypeStartTournament:
+		return true
+	}
+	return false
+}
+
 func (h *Hub) passMessageToRoom(m *interfaces.IncomingMessage) {
 	if m.Author.Room() == nil {
-		h.observer.Trigger(events.Error{Client: m.Author, ErrorText: NotInARoom})
+		h.observer.Trigger(events.MessageError(m, NotInARoom))
 		return
 	}
 
+	h.parseInRoom(m.Author.Room(), m)
+}
+
+// parseInRoom passes the message to the room, destroying it if it panics
+func (h *Hub) parseInRoom(r interfaces.Room, m *interfaces.IncomingMessage) {
 	defer func() {
 		if rc := recover(); rc != nil {
-			fmt.Printf("Panic in room '%s': %s\n", m.Author.Room().ID(), rc)
-			debug.PrintStack()
-			go h.destroyRoom(m.Author.Room().ID(), messages.ReasonRoomDestroyedGamePanicked)
+			h.log.Error("Panic in room", "room", r.ID(), "driver", r.GameDriverName(), "type", m.Type, "request", m.RequestID, "error", rc, "stack", string(debug.Stack()))
+			metrics.RoomPanics.Inc(r.GameDriverName())
+			go h.destroyRoom(r.ID(), messages.ReasonRoomDestroyedGamePanicked)
 		}
 	}()
-	m.Author.Room().Parse(m)
+	r.Parse(m)
 }
 
 // Removes a client from the hub and also from a room if it's in one
@@ -164,16 +341,42 @@ func (h *Hub) removeClient(cl interfaces.Client) {
 			mutex.Lock()
 			h.clients[cl.Game()] = append(h.clients[cl.Game()][:i], h.clients[cl.Game()][i+1:]...)
 			mutex.Unlock()
+			h.matchmaker.remove(cl)
+			h.detachOutbox(cl)
 			h.observer.Trigger(events.ClientUnregistered{Client: cl})
-			if h.configuration.Debug {
-				log.Printf("Client removed from hub, number of clients left: %d\n", len(h.clients[cl.Game()]))
-			}
+			h.log.Debug("Client removed from hub", "client", cl.Name(), "driver", cl.Game(), "clients", len(h.clients[cl.Game()]))
 			cl.Close()
 			return
 		}
 	}
 }
 
+// rejectClient sends the passed error to a client who couldn't be registered,
+// closing its connection afterwards
+func (h *Hub) rejectClient(cl interfaces.Client, errorText string) {
+	h.log.Info("Client rejected", "driver", cl.Game(), "address", cl.Address(), "error", errorText)
+	h.observer.Trigger(events.Error{Client: cl, ErrorText: errorText})
+	wg.Wait()
+	cl.Close()
+}
+
+// reattach seats the client back in the asynchronous room where he/she has a seat
+// kept from a previous connection, if any
+func (h *Hub) reattach(cl interfaces.Client) {
+	for _, r := range h.rooms {
+		if r.IsAsync() && r.ReattachClient(cl) {
+			return
+		}
+	}
+}
+
+// runLater schedules the passed function to be run from the hub's goroutine
+func (h *Hub) runLater(task func()) {
+	go func() {
+		h.tasks <- task
+	}()
+}
+
 // NumberClients returns the number of connected clients
 func (h *Hub) NumberClients(game string) int {
 	return len(h.clients[game])
@@ -196,39 +399,68 @@ func (h *Hub) getWaitingRoomsIds() []string {
 	return ids
 }
 
+// sendMessage sends the message to the client, numbering it if the client has a session.
+// If the client can't be reached, it is removed from the hub.
 func (h *Hub) sendMessage(c interfaces.Client, message interface{}, typeName string, optArgs ...interface{}) {
-	defer wg.Done()
+	out := &outgoing{typeName: typeName, message: message}
+	if len(optArgs) > 0 {
+		out.seq = optArgs[0].(int)
+	}
+	h.send(c, out)
+}
+
+// sendResponse sends the message to the author of the passed one, along with its request identifier
+func (h *Hub) sendResponse(m *interfaces.IncomingMessage, message interface{}, typeName string) {
+	h.send(m.Author, &outgoing{typeName: typeName, message: message, requestID: m.RequestID})
+}
 
-	encoded := encodeMessage(message, typeName, optArgs)
+func (h *Hub) send(c interfaces.Client, out *outgoing) {
+	defer wg.Done()
 
-	if h.configuration.Debug {
-		log.Printf("Sending message %s to client '%s'\n", string(encoded[:]), c.Name())
+	deliver := func(out *outgoing) bool {
+		return h.deliver(c, out)
 	}
 
-	select {
-	case c.Incoming() <- encoded:
-		return
+	var delivered bool
+	if ob := h.outboxOf(c); ob != nil {
+		delivered = ob.send(out, h.configuration.ResendBufferSize, deliver)
+	} else {
+		delivered = deliver(out)
+	}
 
 	// We can't reach the client
-	default:
+	if !delivered {
+		metrics.ClientsDropped.Inc()
 		wg.Wait()
 		h.removeClient(c)
-		return
 	}
 }
 
-func encodeMessage(message interface{}, typeName string, optArgs []interface{}) []byte {
-	encodedContent, _ := json.Marshal(message)
+// deliver encodes the message for the client and queues it to be written,
+// returning false if the client's queue is full
+func (h *Hub) deliver(c interfaces.Client, out *outgoing) bool {
+	header := codec.Header{Sequence: out.seq, ClientSequence: out.csq, RequestID: out.requestID}
+	encoded, err := encodeMessage(c.Codec(), out.message, out.typeName, c.ProtocolVersion(), header)
+	if err != nil {
+		h.log.Error("Error encoding message", "client", c.Name(), "type", out.typeName, "error", err)
+		return true
+	}
 
-	wrappedMessage := interfaces.OutgoingMessage{
-		Type:    typeName,
-		Content: encodedContent,
+	if h.log.Enabled(logger.DebugLevel) {
+		h.log.Debug("Sending message", "client", c.Name(), "message", string(encoded))
 	}
 
-	if len(optArgs) > 0 {
-		wrappedMessage.SequenceNumber = optArgs[0].(int)
+	select {
+	case c.Incoming() <- encoded:
+		metrics.MessagesSent.Inc(out.typeName)
+		return true
+	default:
+		return false
 	}
+}
 
-	encoded, _ := json.Marshal(wrappedMessage)
-	return encoded
+// encodeMessage adapts the message to the passed protocol version and encodes it with its type
+// and header using the passed codec
+func encodeMessage(c codec.Codec, message interface{}, typeName string, version int, header codec.Header) ([]byte, error) {
+	return c.Encode(typeName, messages.Adapt(message, typeName, version), header)
 }
@@ -1,17 +1,26 @@
 package hub
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/ban"
 	"github.com/svera/sackson-server/internal/client"
+	"github.com/svera/sackson-server/internal/codec"
 	"github.com/svera/sackson-server/internal/config"
 	"github.com/svera/sackson-server/internal/drivers"
+	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
 	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/names"
 	"github.com/svera/sackson-server/internal/room"
+	"github.com/svera/sackson-server/internal/tournament"
 	"github.com/svera/sackson-server/observer"
 )
 
@@ -22,7 +31,7 @@ func init() {
 }
 
 func setup() (h *Hub, c *client.Mock) {
-	h = New(&config.Config{Timeout: 5, Debug: true}, observer.New())
+	h = New(&config.Config{Timeout: 5, Debug: true}, observer.New(), logger.Discard())
 	c = client.NewMock()
 	return h, c
 }
@@ -55,7 +64,7 @@ func TestUnregister(t *testing.T) {
 
 func TestCreateRoom(t *testing.T) {
 	h, c := setup()
-	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer) interfaces.Room {
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
 		return room.NewMock()
 	}
 	go h.Run()
@@ -86,7 +95,7 @@ func TestDestroyRoom(t *testing.T) {
 		return "testRoom"
 	}
 
-	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer) interfaces.Room {
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
 		return testRoom
 	}
 
@@ -103,7 +112,7 @@ func TestDestroyRoom(t *testing.T) {
 	go c.WritePump()
 	h.Register <- c
 	time.Sleep(time.Millisecond * 100)
-	h.createRoom(b, c)
+	h.createRoom(b, c, "")
 	time.Sleep(time.Millisecond * 100)
 	m := &interfaces.IncomingMessage{
 		Author:  c,
@@ -126,7 +135,7 @@ func TestDestroyRoomAfterXSeconds(t *testing.T) {
 		return "testRoom"
 	}
 
-	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer) interfaces.Room {
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
 		return testRoom
 	}
 
@@ -136,7 +145,7 @@ func TestDestroyRoomAfterXSeconds(t *testing.T) {
 	go c.WritePump()
 	h.Register <- c
 
-	h.createRoom(b, c)
+	h.createRoom(b, c, "")
 	time.Sleep(time.Millisecond * 1100)
 
 	if len(h.rooms) != 0 {
@@ -152,7 +161,7 @@ func TestDestroyRoomWhenNoHumanClients(t *testing.T) {
 		return "testRoom"
 	}
 
-	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer) interfaces.Room {
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
 		return testRoom
 	}
 
@@ -167,7 +176,7 @@ func TestDestroyRoomWhenNoHumanClients(t *testing.T) {
 	go c.WritePump()
 	h.Register <- c
 	time.Sleep(time.Millisecond * 100)
-	h.createRoom(b, c)
+	h.createRoom(b, c, "")
 	time.Sleep(time.Millisecond * 100)
 	h.Unregister <- c
 	time.Sleep(time.Millisecond * 100)
@@ -181,7 +190,7 @@ func TestJoinRoom(t *testing.T) {
 	h, c := setup()
 	testRoom := room.NewMock()
 
-	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer) interfaces.Room {
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
 		return testRoom
 	}
 
@@ -194,7 +203,7 @@ func TestJoinRoom(t *testing.T) {
 	h.Register <- c
 	h.Register <- c2
 
-	id := h.createRoom(b, c)
+	id := h.createRoom(b, c, "")
 	time.Sleep(time.Millisecond * 100)
 
 	data := []byte(`{"rom": "` + id + `"}`)
@@ -211,8 +220,10 @@ func TestJoinRoom(t *testing.T) {
 	}
 }
 
-func ExampleHubRecoversFromRoomPanic() {
+func TestHubRecoversFromRoomPanic(t *testing.T) {
+	var out bytes.Buffer
 	h, c := setup()
+	h.log = logger.New(&out, logger.Options{JSON: true, Level: logger.ErrorLevel})
 	const roomID = "test"
 
 	room := room.NewMock()
@@ -240,8 +251,674 @@ func ExampleHubRecoversFromRoomPanic() {
 	h.Register <- c
 
 	h.Messages <- m
-	time.Sleep(time.Millisecond * 100)
+	// Waits for the hub to process the message
+	h.do(func() {})
+
+	var entry map[string]interface{}
+	if err := json.NewDecoder(&out).Decode(&entry); err != nil {
+		t.Fatalf("Hub must log the panic, got '%s'", out.String())
+	}
+	if entry["msg"] != "Panic in room" || entry["room"] != roomID || entry["error"] != "A panic" {
+		t.Errorf("Panic entry must contain the room and the error, got %v", entry)
+	}
+}
+
+func TestMatchmakingGroupsCompatiblePlayers(t *testing.T) {
+	mm := &matchmaker{}
+	now := time.Now()
+	c1, c2, c3, c4 := client.NewMock(), client.NewMock(), client.NewMock(), client.NewMock()
+	mm.add(&ticket{client: c1, driver: "test", players: 2, rating: 1500, maxRating: 1600, queuedAt: now})
+	mm.add(&ticket{client: c2, driver: "test", players: 2, rating: 1700, queuedAt: now})
+	mm.add(&ticket{client: c3, driver: "test", players: 3, rating: 1500, queuedAt: now})
+	mm.add(&ticket{client: c4, driver: "test", players: 2, rating: 1550, queuedAt: now})
+
+	groups := mm.groups(now, 0)
+
+	if len(groups) != 1 || groups[0][0].client != c1 || groups[0][1].client != c4 {
+		t.Fatalf("Matchmaker must group players with compatible preferences")
+	}
+	if len(mm.tickets) != 2 {
+		t.Errorf("Matched players must be removed from the queue, got %d tickets left", len(mm.tickets))
+	}
+}
+
+func TestMatchmakingFillsWithBotsAfterWaiting(t *testing.T) {
+	mm := &matchmaker{}
+	now := time.Now()
+	mm.add(&ticket{client: client.NewMock(), driver: "test", players: 3, allowBots: true, queuedAt: now.Add(-time.Minute)})
+	mm.add(&ticket{client: client.NewMock(), driver: "test", players: 3, allowBots: false, queuedAt: now.Add(-time.Minute)})
+
+	groups := mm.groups(now, time.Second*30)
+
+	if len(groups) != 1 || len(groups[0]) != 1 || !groups[0][0].allowBots {
+		t.Errorf("Matchmaker must only match incomplete groups if all their players allow bots")
+	}
+}
+
+func TestMatchmakingStartsGame(t *testing.T) {
+	h, c := setup()
+	testRoom := room.NewMock()
+	parsed := []string{}
+	seated := map[int]interfaces.Client{0: c, 1: client.NewMock()}
+	testRoom.FakeClients = func() map[int]interfaces.Client {
+		return seated
+	}
+	testRoom.FakeParse = func(m *interfaces.IncomingMessage) {
+		parsed = append(parsed, m.Type)
+		if m.Type == messages.TypeAddBot {
+			seated[len(seated)] = client.NewMock()
+		}
+	}
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+		return testRoom
+	}
+	h.matchmaker.add(&ticket{client: c, driver: "test", players: 3, allowBots: true, queuedAt: time.Now()})
+	h.matchmaker.add(&ticket{client: client.NewMock(), driver: "test", players: 3, allowBots: true, queuedAt: time.Now()})
+	h.configuration.MatchmakingBotsWait = 1
+
+	h.match(time.Now().Add(time.Second))
+
+	if testRoom.Calls["AddHuman"] != 2 {
+		t.Errorf("Room must have 2 humans added, got %d", testRoom.Calls["AddHuman"])
+	}
+	if len(parsed) != 2 || parsed[0] != messages.TypeAddBot || parsed[1] != messages.TypeStartGame {
+		t.Errorf("Matchmaking must add a bot and start the game, got %v", parsed)
+	}
+}
+
+func TestMatchmakingStopsIfBotsCantBeAdded(t *testing.T) {
+	h, c := setup()
+	testRoom := room.NewMock()
+	parsed := []string{}
+	testRoom.FakeParse = func(m *interfaces.IncomingMessage) {
+		parsed = append(parsed, m.Type)
+	}
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+		return testRoom
+	}
+	h.matchmaker.add(&ticket{client: c, driver: "test", players: 6, allowBots: true, queuedAt: time.Now()})
+	h.configuration.MatchmakingBotsWait = 1
+
+	h.match(time.Now().Add(time.Second))
+
+	if len(parsed) != 1 || len(h.rooms) != 0 {
+		t.Errorf("Matchmaking must stop and destroy the room when a bot can't be added, got %v", parsed)
+	}
+}
+
+func TestMatchmakingRequeuesGroupIfPlayerCantBeSeated(t *testing.T) {
+	h, c := setup()
+	unseatable, other := client.NewMock(), client.NewMock()
+	testRoom := room.NewMock()
+	testRoom.FakeAddHuman = func(cl interfaces.Client, requestID string) error {
+		if cl == unseatable {
+			return errors.New(room.UserAlreadySeated)
+		}
+		return nil
+	}
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+		return testRoom
+	}
+	h.matchmaker.add(&ticket{client: c, driver: "test", players: 3, queuedAt: time.Now()})
+	h.matchmaker.add(&ticket{client: unseatable, driver: "test", players: 3, queuedAt: time.Now()})
+	h.matchmaker.add(&ticket{client: other, driver: "test", players: 3, queuedAt: time.Now()})
+
+	h.match(time.Now())
+
+	if len(h.rooms) != 0 {
+		t.Errorf("Room must be destroyed if a player can't be seated, got %d rooms", len(h.rooms))
+	}
+	if !h.matchmaker.queued(c) || !h.matchmaker.queued(other) || h.matchmaker.queued(unseatable) {
+		t.Errorf("The rest of the group must be queued again without the player who couldn't be seated")
+	}
+}
+
+func TestMatchmakingKeepsTicketsWhileRoomsAreRefused(t *testing.T) {
+	h, c := setup()
+	h.rooms["VWXYZ"] = room.NewMock()
+	h.configuration.MaxRooms = 1
+	other := client.NewMock()
+	h.matchmaker.add(&ticket{client: c, driver: "test", players: 2, queuedAt: time.Now()})
+	h.matchmaker.add(&ticket{client: other, driver: "test", players: 2, queuedAt: time.Now()})
+
+	h.match(time.Now())
+
+	if len(h.rooms) != 1 {
+		t.Errorf("Matchmaking must not create rooms over the rooms limit, got %d rooms", len(h.rooms))
+	}
+	if !h.matchmaker.queued(c) || !h.matchmaker.queued(other) {
+		t.Errorf("Tickets must stay queued while rooms can't be created")
+	}
+}
+
+func TestJoinMatchmakingRejectsTooManyPlayers(t *testing.T) {
+	h, c := setup()
+	h.configuration.MatchmakingInterval = 1
+	var failed string
+	h.observer.On(events.Error{}, func(ev interface{}) {
+		failed = ev.(events.Error).ErrorText
+	})
+
+	h.parseControlMessage(&interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeJoinMatchmaking,
+		Content: json.RawMessage(`{"drv": "test", "ply": 2000000000, "bot": true}`),
+	})
+
+	if failed != InvalidPlayersNumber || h.matchmaker.queued(c) {
+		t.Errorf("Asking for more players than allowed must return error '%s', got '%s'", InvalidPlayersNumber, failed)
+	}
+}
+
+func TestRejectedMessagesAreAnsweredWithTheirError(t *testing.T) {
+	h, c := setup()
+	var failed events.Error
+	h.observer.On(events.Error{}, func(ev interface{}) {
+		failed = ev.(events.Error)
+	})
+
+	h.parseMessage(&interfaces.IncomingMessage{Author: c, Type: messages.TypeCreateRoom, RequestID: "r1", Rejected: "rate_limited"})
+
+	if failed.ErrorText != "rate_limited" || failed.RequestID != "r1" || len(h.rooms) != 0 {
+		t.Errorf("Rejected messages must only be answered with their error and request ID, got %v", failed)
+	}
+}
+
+func TestTournamentTablesRecordedOnce(t *testing.T) {
+	h, _ := setup()
+	defer func(f func() string) { GenerateID = f }(GenerateID)
+	rooms := 0
+	GenerateID = func() string {
+		rooms++
+		return "T" + strconv.Itoa(rooms)
+	}
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+		r := room.NewMock()
+		r.FakeID = func() string { return ID }
+		r.FakeParse = func(m *interfaces.IncomingMessage) {}
+		return r
+	}
+	players := map[string]*client.Mock{}
+	for _, name := range []string{"A", "B", "C", "D"} {
+		session := "session-" + name
+		cl := client.NewMock()
+		cl.FakeName = func() string { return "Player" }
+		cl.FakeSessionID = func() string { return session }
+		players[name] = cl
+		h.clients["test"] = append(h.clients["test"], cl)
+	}
+	tr, _ := h.Tournaments.Create(participantID(players["A"]), "A", tournament.Settings{Driver: "test", Format: tournament.Swiss, TableSize: 2, Rounds: 2})
+	for name, cl := range players {
+		h.Tournaments.Join(tr.ID, participantID(cl), name)
+		h.bindTournamentClient(tr.ID, cl)
+	}
+	tr, _ = h.Tournaments.Start(tr.ID, participantID(players["A"]))
+	tr = h.startTournamentRound(tr)
+
+	for _, table := range tr.CurrentRound().Tables {
+		results := []messages.PlayerResult{}
+		for i, name := range table.Players {
+			results = append(results, messages.PlayerResult{Number: i, Name: "Player", SessionID: players[name].SessionID(), Rank: i + 1})
+		}
+		h.observer.Trigger(events.GameEnded{Room: h.rooms[table.RoomID], Results: results})
+	}
+	for {
+		select {
+		case task := <-h.tasks:
+			task()
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+
+	tr, _ = h.Tournaments.Get(tr.ID)
+	if len(tr.Rounds) != 2 {
+		t.Fatalf("Second round must start once all tables of the first one are recorded, got %d rounds", len(tr.Rounds))
+	}
+	for _, table := range tr.CurrentRound().Tables {
+		if table.Done {
+			t.Errorf("Tables of the second round must not be recorded before being played, got %v", table)
+		}
+	}
+	if winner := tr.Rounds[0].Tables[0].Players[0]; tr.Player(winner).Points != 1 {
+		t.Errorf("Results must be recorded for the players of the table, got %v", tr.Players)
+	}
+}
 
-	// Output:
-	// Panic in room 'test': A panic
+func TestTournamentTablesWaitWhileRoomsAreRefused(t *testing.T) {
+	h, _ := setup()
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+		r := room.NewMock()
+		r.FakeID = func() string { return ID }
+		r.FakeParse = func(m *interfaces.IncomingMessage) {}
+		return r
+	}
+	players := map[string]*client.Mock{}
+	for _, name := range []string{"A", "B"} {
+		session := "session-" + name
+		cl := client.NewMock()
+		cl.FakeSessionID = func() string { return session }
+		players[name] = cl
+		h.clients["test"] = append(h.clients["test"], cl)
+	}
+	tr, _ := h.Tournaments.Create(participantID(players["A"]), "A", tournament.Settings{Driver: "test", Format: tournament.Swiss, TableSize: 2, Rounds: 1})
+	for name, cl := range players {
+		h.Tournaments.Join(tr.ID, participantID(cl), name)
+		h.bindTournamentClient(tr.ID, cl)
+	}
+	tr, _ = h.Tournaments.Start(tr.ID, participantID(players["A"]))
+	h.maintenance = true
+
+	tr = h.startTournamentRound(tr)
+
+	if len(h.rooms) != 0 {
+		t.Errorf("Tournament tables must not create rooms in maintenance mode, got %d rooms", len(h.rooms))
+	}
+	if table := tr.CurrentRound().Tables[0]; table.Done {
+		t.Errorf("Tournament tables must not be forfeited while waiting for a room, got %v", table)
+	}
+
+	h.maintenance = false
+	h.startWaitingTournamentTables()
+
+	tr, _ = h.Tournaments.Get(tr.ID)
+	if table := tr.CurrentRound().Tables[0]; table.RoomID == "" || len(h.rooms) != 1 {
+		t.Errorf("Waiting tournament tables must be started once rooms can be created, got %v", table)
+	}
+}
+
+func TestTournamentTableRoomDestroyedIfPlayerCantBeSeated(t *testing.T) {
+	h, _ := setup()
+	NewRoom = func(ID string, b api.Driver, owner interfaces.Client, messages chan *interfaces.IncomingMessage, unregister chan interfaces.Client, cfg *config.Config, ob interfaces.Observer, log *logger.Logger) interfaces.Room {
+		r := room.NewMock()
+		r.FakeID = func() string { return ID }
+		r.FakeAddHuman = func(c interfaces.Client, requestID string) error {
+			return errors.New(room.UserAlreadySeated)
+		}
+		return r
+	}
+	players := map[string]*client.Mock{}
+	for _, name := range []string{"A", "B"} {
+		session := "session-" + name
+		cl := client.NewMock()
+		cl.FakeSessionID = func() string { return session }
+		players[name] = cl
+		h.clients["test"] = append(h.clients["test"], cl)
+	}
+	tr, _ := h.Tournaments.Create(participantID(players["A"]), "A", tournament.Settings{Driver: "test", Format: tournament.Swiss, TableSize: 2, Rounds: 2})
+	for name, cl := range players {
+		h.Tournaments.Join(tr.ID, participantID(cl), name)
+		h.bindTournamentClient(tr.ID, cl)
+	}
+	tr, _ = h.Tournaments.Start(tr.ID, participantID(players["A"]))
+
+	h.startTournamentRound(tr)
+	for {
+		select {
+		case task := <-h.tasks:
+			task()
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+
+	if len(h.rooms) != 0 {
+		t.Errorf("Room of a table whose players can't be seated must be destroyed, got %d rooms", len(h.rooms))
+	}
+	if tr, _ = h.Tournaments.Get(tr.ID); len(tr.Rounds) != 2 {
+		t.Errorf("Table must be recorded as a forfeit, got %d rounds", len(tr.Rounds))
+	}
+}
+
+func TestAdminDestroyRoom(t *testing.T) {
+	h, _ := setup()
+	h.rooms["VWXYZ"] = room.NewMock()
+	go h.Run()
+
+	if info := h.RoomsInfo(); len(info) != 1 {
+		t.Fatalf("Hub must return info of 1 room, got %d", len(info))
+	}
+	if err := h.DestroyRoom("VWXYZ", ""); err != nil {
+		t.Errorf("Destroying an existing room must not return an error, got %s", err.Error())
+	}
+	if len(h.RoomsInfo()) != 0 {
+		t.Errorf("Hub must have no rooms after destroying it")
+	}
+	if err := h.DestroyRoom("VWXYZ", ""); err == nil || err.Error() != InexistentRoom {
+		t.Errorf("Destroying an inexistent room must return error '%s', got %v", InexistentRoom, err)
+	}
+}
+
+func TestShutdownExpelsAllClients(t *testing.T) {
+	h, c := setup()
+	roomless := client.NewMock()
+	incoming := map[*client.Mock]chan []byte{c: make(chan []byte, 10), roomless: make(chan []byte, 10)}
+	closed := 0
+	for cl, ch := range incoming {
+		ch := ch
+		cl.FakeIncoming = func() chan []byte {
+			return ch
+		}
+		cl.FakeClose = func() {
+			closed++
+		}
+	}
+
+	testRoom := room.NewMock()
+	toBeDestroyed := false
+	testRoom.FakeToBeDestroyed = func(v bool) {
+		toBeDestroyed = v
+	}
+	testRoom.FakeIsToBeDestroyed = func() bool {
+		return toBeDestroyed
+	}
+	testRoom.FakeClients = func() map[int]interfaces.Client {
+		return map[int]interfaces.Client{0: c}
+	}
+	c.FakeRoom = func() interfaces.Room {
+		return testRoom
+	}
+	h.rooms["VWXYZ"] = testRoom
+	h.clients["test"] = []interfaces.Client{c, roomless}
+	go h.Run()
+
+	h.Shutdown(0)
+
+	if !h.shuttingDown {
+		t.Errorf("Hub must be flagged as shutting down")
+	}
+	if len(h.rooms) != 0 {
+		t.Errorf("Hub must have no rooms after shutting down, got %d", len(h.rooms))
+	}
+	if len(h.clients["test"]) != 0 || closed != 2 {
+		t.Errorf("Hub must disconnect all clients after shutting down, got %d connected and %d closed", len(h.clients["test"]), closed)
+	}
+	for cl, ch := range incoming {
+		found := false
+		for len(ch) > 0 {
+			var msg struct {
+				Type    string             `json:"typ"`
+				Content messages.ClientOut `json:"cnt"`
+			}
+			json.Unmarshal(<-ch, &msg)
+			if msg.Type == messages.TypeClientOut && msg.Content.Reason == messages.ReasonServerShutdown {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Client %p must be notified of the server shutdown", cl)
+		}
+	}
+}
+
+func TestNoRoomsCreatedWhileShuttingDown(t *testing.T) {
+	h, c := setup()
+	h.shuttingDown = true
+	errs := []string{}
+	h.observer.On(events.Error{}, func(ev interface{}) {
+		errs = append(errs, ev.(events.Error).ErrorText)
+	})
+
+	h.parseMessage(&interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeCreateRoom,
+		Content: json.RawMessage(`{"drv": "test"}`),
+	})
+
+	if len(h.rooms) != 0 {
+		t.Errorf("Hub must not create rooms while shutting down, got %d", len(h.rooms))
+	}
+	if len(errs) != 1 || errs[0] != ServerShuttingDown {
+		t.Errorf("Hub must return error '%s', got %v", ServerShuttingDown, errs)
+	}
+}
+
+func TestRegisterRejectsClientsOverLimit(t *testing.T) {
+	h, c := setup()
+	h.configuration.MaxClientsPerGame = 1
+	h.clients["test"] = []interfaces.Client{client.NewMock()}
+	closed := false
+	c.FakeClose = func() {
+		closed = true
+	}
+	go h.Run()
+
+	h.Register <- c
+	h.do(func() {})
+
+	if len(h.clients["test"]) != 1 || !closed {
+		t.Errorf("Hub must reject and disconnect clients over the limit, got %d clients", len(h.clients["test"]))
+	}
+}
+
+func TestCreateRoomLimits(t *testing.T) {
+	h, c := setup()
+	h.configuration.MaxRoomsPerAddress = 1
+	c.SetAddress("10.0.0.1")
+	owned := room.NewMock()
+	owned.FakeOwner = func() interfaces.Client {
+		sameAddress := client.NewMock()
+		sameAddress.SetAddress("10.0.0.1")
+		return sameAddress
+	}
+	h.rooms["VWXYZ"] = owned
+
+	if err := h.checkRoomsLimits(c); err == nil || err.Error() != AddressRoomsLimitReached {
+		t.Errorf("Clients must not own more rooms than allowed per address, got %v", err)
+	}
+	c.SetAddress("10.0.0.2")
+	if err := h.checkRoomsLimits(c); err != nil {
+		t.Errorf("Clients from other addresses must be able to create rooms, got %s", err.Error())
+	}
+	h.configuration.MaxRooms = 1
+	if err := h.checkRoomsLimits(c); err == nil || err.Error() != RoomsLimitReached {
+		t.Errorf("Rooms must not be created over the server limit, got %v", err)
+	}
+}
+
+func TestNoRoomsCreatedInMaintenanceMode(t *testing.T) {
+	h, c := setup()
+	errs := []string{}
+	h.observer.On(events.Error{}, func(ev interface{}) {
+		errs = append(errs, ev.(events.Error).ErrorText)
+	})
+	go h.Run()
+	h.SetMaintenance(true)
+
+	h.Messages <- &interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeCreateRoom,
+		Content: json.RawMessage(`{"drv": "test"}`),
+	}
+	h.do(func() {})
+
+	if len(h.rooms) != 0 || len(errs) != 1 || errs[0] != UnderMaintenance {
+		t.Errorf("Hub must refuse new rooms in maintenance mode with error '%s', got %v", UnderMaintenance, errs)
+	}
+}
+
+func TestHelloSetsProtocolVersion(t *testing.T) {
+	h, c := setup()
+	incoming := make(chan []byte, 10)
+	c.FakeIncoming = func() chan []byte {
+		return incoming
+	}
+
+	h.parseMessage(&interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeHello,
+		Content: json.RawMessage(`{"ver": 2}`),
+	})
+	wg.Wait()
+
+	var msg struct {
+		Type    string            `json:"typ"`
+		Content messages.Protocol `json:"cnt"`
+	}
+	json.Unmarshal(<-incoming, &msg)
+	if c.ProtocolVersion() != messages.ProtocolV2 || msg.Type != messages.TypeProtocol || msg.Content.Version != messages.ProtocolV2 {
+		t.Errorf("Client must be answered with the protocol version he declares, got %+v", msg)
+	}
+
+	h.parseMessage(&interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeHello,
+		Content: json.RawMessage(`{"ver": 99}`),
+	})
+	wg.Wait()
+	if c.ProtocolVersion() != messages.ProtocolV2 {
+		t.Errorf("Unsupported protocol versions must be ignored, got %d", c.ProtocolVersion())
+	}
+}
+
+func TestEncodeMessageAdaptsToProtocolVersion(t *testing.T) {
+	message := messages.CurrentPlayers{Values: map[string]messages.PlayerData{
+		"1": {Name: "Sergio"},
+		"0": {Name: "Miguel"},
+	}}
+
+	v1, _ := encodeMessage(codec.JSON, message, messages.TypeCurrentPlayers, messages.ProtocolV1, codec.Header{})
+	if string(v1) != `{"typ":"pls","cnt":{"val":{"0":{"nam":"Miguel"},"1":{"nam":"Sergio"}}}}` {
+		t.Errorf("Version 1 message must not be adapted, got %s", v1)
+	}
+	v2, _ := encodeMessage(codec.JSON, message, messages.TypeCurrentPlayers, messages.ProtocolV2, codec.Header{})
+	if string(v2) != `{"typ":"pls","cnt":{"val":[{"num":0,"nam":"Miguel"},{"num":1,"nam":"Sergio"}]}}` {
+		t.Errorf("Version 2 message must list players in order, got %s", v2)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	h, c := setup()
+	h.configuration.ResendBufferSize = 10
+	h.configuration.ResumeWindow = 60
+	incoming := make(chan []byte, 10)
+	c.FakeSessionID = func() string { return "abc" }
+	c.FakeIncoming = func() chan []byte { return incoming }
+	h.attachOutbox(c)
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		h.sendMessage(c, messages.Notice{Message: "Hello"}, messages.TypeNotice)
+	}
+	h.parseMessage(&interfaces.IncomingMessage{Author: c, Type: messages.TypeAck, Content: json.RawMessage(`{"csq": 1}`)})
+	h.detachOutbox(c)
+
+	reconnected := client.NewMock()
+	reconnectedIncoming := make(chan []byte, 10)
+	reconnected.FakeSessionID = func() string { return "abc" }
+	reconnected.FakeIncoming = func() chan []byte { return reconnectedIncoming }
+	h.attachOutbox(reconnected)
+	wg.Add(1)
+	h.sendMessage(reconnected, messages.Notice{Message: "Welcome back"}, messages.TypeNotice)
+	h.parseMessage(&interfaces.IncomingMessage{Author: reconnected, Type: messages.TypeResume, Content: json.RawMessage(`{"lst": 1}`)})
+	wg.Wait()
+
+	var msg struct {
+		Type           string          `json:"typ"`
+		ClientSequence int             `json:"csq"`
+		Content        json.RawMessage `json:"cnt"`
+	}
+	for _, expected := range []int{4, 2, 3} {
+		json.Unmarshal(<-reconnectedIncoming, &msg)
+		if msg.ClientSequence != expected {
+			t.Errorf("Message with client sequence number %d expected, got %d", expected, msg.ClientSequence)
+		}
+	}
+
+	h.parseMessage(&interfaces.IncomingMessage{Author: reconnected, Type: messages.TypeResume, Content: json.RawMessage(`{"lst": 0}`)})
+	wg.Wait()
+	json.Unmarshal(<-reconnectedIncoming, &msg)
+	if msg.Type != messages.TypeError || !bytes.Contains(msg.Content, []byte(MessagesLost)) || msg.ClientSequence != 5 {
+		t.Errorf("Resuming from an acknowledged message must return error '%s', got %s %s", MessagesLost, msg.Type, msg.Content)
+	}
+}
+
+func TestResponsesCarryRequestID(t *testing.T) {
+	h, c := setup()
+	incoming := make(chan []byte, 10)
+	c.FakeIncoming = func() chan []byte {
+		return incoming
+	}
+
+	var msg struct {
+		Type      string          `json:"typ"`
+		RequestID string          `json:"rid"`
+		Content   json.RawMessage `json:"cnt"`
+	}
+	h.parseMessage(&interfaces.IncomingMessage{Author: c, Type: messages.TypeHello, RequestID: "r1", Content: json.RawMessage(`{"ver": 1}`)})
+	wg.Wait()
+	json.Unmarshal(<-incoming, &msg)
+	if msg.Type != messages.TypeProtocol || msg.RequestID != "r1" {
+		t.Errorf("Protocol message must carry the request identifier of the hello message, got %s with '%s'", msg.Type, msg.RequestID)
+	}
+
+	h.parseMessage(&interfaces.IncomingMessage{Author: c, Type: messages.TypeJoinRoom, RequestID: "r2", Content: json.RawMessage(`{"rom": "VWXYZ"}`)})
+	wg.Wait()
+	json.Unmarshal(<-incoming, &msg)
+	var description messages.Error
+	json.Unmarshal(msg.Content, &description)
+	if msg.Type != messages.TypeError || msg.RequestID != "r2" || description.RequestID != "r2" || description.Type != messages.TypeJoinRoom {
+		t.Errorf("Error must carry the type and request identifier of the message which caused it, got %s with '%s' and %s", msg.Type, msg.RequestID, msg.Content)
+	}
+}
+
+func TestBannedClientsExpelled(t *testing.T) {
+	h, c := setup()
+	banned := client.NewMock()
+	banned.FakeAddress = func() string { return "10.0.0.1" }
+	closed := false
+	banned.FakeClose = func() {
+		closed = true
+	}
+	banned.FakeIncoming = func() chan []byte {
+		return make(chan []byte, 10)
+	}
+	h.clients["test"] = []interfaces.Client{c, banned}
+	h.rooms["VWXYZ"] = room.NewMock()
+	go h.Run()
+
+	if err := h.Ban(ban.Entry{Kind: ban.Address, Value: "10.0.0.1"}); err != nil {
+		t.Fatalf("Banning an address must not return an error, got %s", err.Error())
+	}
+	if len(h.clients["test"]) != 1 || !closed {
+		t.Fatalf("Banned clients must be disconnected, got %d clients", len(h.clients["test"]))
+	}
+	if err := h.BanClient("xyz", ""); err == nil || err.Error() != InexistentClient {
+		t.Errorf("Banning an inexistent client must return error '%s', got %v", InexistentClient, err)
+	}
+
+	var errs []string
+	h.observer.On(events.Error{}, func(ev interface{}) {
+		errs = append(errs, ev.(events.Error).ErrorText)
+	})
+	h.do(func() {
+		h.parseMessage(&interfaces.IncomingMessage{Author: banned, Type: messages.TypeJoinRoom, Content: json.RawMessage(`{"rom": "VWXYZ"}`)})
+	})
+	if len(errs) != 1 || errs[0] != Banned {
+		t.Errorf("Banned clients must not join rooms, got %v", errs)
+	}
+}
+
+func TestJoinRoomRejectsInvalidNames(t *testing.T) {
+	h, c := setup()
+	h.Names = names.NewPolicy(config.NamesConfig{ReservedPrefixes: []string{"Bot"}})
+	h.rooms["VWXYZ"] = room.NewMock()
+	var errs []events.Error
+	h.observer.On(events.Error{}, func(ev interface{}) {
+		errs = append(errs, ev.(events.Error))
+	})
+
+	h.parseMessage(&interfaces.IncomingMessage{Author: c, Type: messages.TypeJoinRoom, Content: json.RawMessage(`{"rom": "VWXYZ", "nam": "Bot 3"}`)})
+
+	if h.rooms["VWXYZ"].(*room.Mock).Calls["AddHuman"] != 0 {
+		t.Errorf("Clients with invalid names must not join rooms")
+	}
+	if len(errs) != 1 || errs[0].ErrorText != names.InvalidName {
+		t.Fatalf("Hub must return error '%s', got %v", names.InvalidName, errs)
+	}
+	if e, ok := errs[0].Details.(*names.Error); !ok || e.Rule != names.RuleReserved || e.Prefix != "Bot" {
+		t.Errorf("Error must hold the reserved prefix used, got %v", errs[0].Details)
+	}
 }
@@ -21,10 +21,26 @@ func (h *Hub) joinRoomAction(m *interfaces.IncomingMessage) error {
 	if room, ok = h.rooms[parsed.Room]; !ok {
 		return errors.New(InexistentRoom)
 	}
+	if h.BanList.Banned(m.Author.UserID(), m.Author.SessionID(), m.Author.Address()) {
+		return errors.New(Banned)
+	}
 
-	if strings.TrimSpace(parsed.ClientName) != "" {
-		m.Author.SetName(parsed.ClientName)
+	if err = h.setClientName(m.Author, parsed.ClientName); err != nil {
+		return err
+	}
+	return room.AddHuman(m.Author, m.RequestID)
+}
+
+// setClientName sets the name the client chose when creating or joining a room,
+// if he/she chose one. Authenticated users keep the name given by the authenticator.
+func (h *Hub) setClientName(cl interfaces.Client, name string) error {
+	if strings.TrimSpace(name) == "" || cl.UserID() != "" {
+		return nil
+	}
+	name, err := h.Names.Check(name)
+	if err != nil {
+		return err
 	}
-	room.AddHuman(m.Author)
+	cl.SetName(name)
 	return nil
 }
@@ -0,0 +1,51 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/rating"
+)
+
+func (h *Hub) leaderboardAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.RequestLeaderboard
+	var err error
+
+	if h.Ratings == nil {
+		return errors.New(RatingsDisabled)
+	}
+	if len(m.Content) > 0 {
+		if err = json.Unmarshal(m.Content, &parsed); err != nil {
+			return err
+		}
+	}
+	if parsed.DriverName == "" {
+		parsed.DriverName = m.Author.Game()
+	}
+
+	ladder := rating.Ladder{Driver: parsed.DriverName, Bots: parsed.Bots}
+	leaderboard, err := h.Ratings.Leaderboard(ladder, rating.ClampLimit(parsed.Limit))
+	if err != nil {
+		return err
+	}
+
+	message := messages.Leaderboard{
+		DriverName: parsed.DriverName,
+		Bots:       parsed.Bots,
+		Values:     make([]messages.LeaderboardEntry, 0, len(leaderboard)),
+	}
+	for _, rt := range leaderboard {
+		message.Values = append(message.Values, messages.LeaderboardEntry{
+			Name:   rt.Name,
+			Rating: int(math.Floor(rt.Value + 0.5)),
+			Games:  rt.Games,
+		})
+	}
+
+	wg.Add(1)
+	go h.sendResponse(m, message, messages.TypeLeaderboard)
+	return nil
+}
@@ -0,0 +1,297 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/drivers"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/rating"
+)
+
+// defaultMatchmakingMaxPlayers is the maximum number of players of games started by matchmaking
+// if none is configured
+const defaultMatchmakingMaxPlayers = 6
+
+// ticket holds the preferences of a client waiting in the matchmaking queue
+type ticket struct {
+	client    interfaces.Client
+	driver    string
+	players   int
+	allowBots bool
+	minRating int
+	maxRating int
+	rating    float64
+	queuedAt  time.Time
+}
+
+// accepts returns true if the passed ticket's rating is inside this ticket's rating range
+func (t *ticket) accepts(other *ticket) bool {
+	if t.minRating > 0 && other.rating < float64(t.minRating) {
+		return false
+	}
+	if t.maxRating > 0 && other.rating > float64(t.maxRating) {
+		return false
+	}
+	return true
+}
+
+// matchmaker holds the clients waiting to be matched, in the order they joined the queue
+type matchmaker struct {
+	mutex   sync.Mutex
+	tickets []*ticket
+}
+
+func (mm *matchmaker) add(t *ticket) {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+	mm.tickets = append(mm.tickets, t)
+}
+
+// requeue puts the tickets of a group which couldn't be started back in the queue,
+// keeping the order in which they joined it
+func (mm *matchmaker) requeue(group []*ticket) {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+	mm.tickets = append(mm.tickets, group...)
+	sort.SliceStable(mm.tickets, func(i, j int) bool {
+		return mm.tickets[i].queuedAt.Before(mm.tickets[j].queuedAt)
+	})
+}
+
+// remove takes the passed client out of the queue, returning false if it wasn't in it
+func (mm *matchmaker) remove(cl interfaces.Client) bool {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+	for i, t := range mm.tickets {
+		if t.client == cl {
+			mm.tickets = append(mm.tickets[:i], mm.tickets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (mm *matchmaker) queued(cl interfaces.Client) bool {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+	for _, t := range mm.tickets {
+		if t.client == cl {
+			return true
+		}
+	}
+	return false
+}
+
+// groups forms groups of compatible tickets, taking them out of the queue.
+// Tickets are matched in the order they joined the queue, each group being built around
+// its oldest ticket. If a group cannot be completed, but its oldest ticket allows bots
+// and has waited at least botsWait, it is formed only with tickets which allow bots,
+// so their empty seats can be filled with them.
+// Tickets of clients who are already in a room are discarded.
+func (mm *matchmaker) groups(now time.Time, botsWait time.Duration) [][]*ticket {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	groups := [][]*ticket{}
+	matched := map[*ticket]bool{}
+	available := func(t *ticket) bool {
+		return !matched[t] && t.client.Room() == nil
+	}
+	for i, oldest := range mm.tickets {
+		if !available(oldest) {
+			continue
+		}
+		group := gather(oldest, mm.tickets[i+1:], available)
+		if len(group) < oldest.players {
+			if !oldest.allowBots || botsWait <= 0 || now.Sub(oldest.queuedAt) < botsWait {
+				continue
+			}
+			group = gather(oldest, mm.tickets[i+1:], func(t *ticket) bool {
+				return available(t) && t.allowBots
+			})
+		}
+		for _, t := range group {
+			matched[t] = true
+		}
+		groups = append(groups, group)
+	}
+
+	pending := []*ticket{}
+	for _, t := range mm.tickets {
+		if available(t) {
+			pending = append(pending, t)
+		}
+	}
+	mm.tickets = pending
+	return groups
+}
+
+// gather returns a group with the oldest ticket and as many compatible candidates
+// accepted by filter as needed to fill it
+func gather(oldest *ticket, candidates []*ticket, filter func(*ticket) bool) []*ticket {
+	group := []*ticket{oldest}
+	for _, candidate := range candidates {
+		if len(group) == oldest.players {
+			break
+		}
+		if filter(candidate) && compatible(group, candidate) {
+			group = append(group, candidate)
+		}
+	}
+	return group
+}
+
+// compatible returns true if candidate wants to play the same game as the group members,
+// and all of them accept each other's rating
+func compatible(group []*ticket, candidate *ticket) bool {
+	for _, t := range group {
+		if t.driver != candidate.driver || t.players != candidate.players {
+			return false
+		}
+		if !t.accepts(candidate) || !candidate.accepts(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Hub) joinMatchmakingAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.JoinMatchmaking
+	var err error
+
+	if h.configuration.MatchmakingInterval <= 0 {
+		return errors.New(MatchmakingDisabled)
+	}
+	if m.Author.Room() != nil {
+		return errors.New(AlreadyInARoom)
+	}
+	if h.matchmaker.queued(m.Author) {
+		return errors.New(AlreadyInMatchmaking)
+	}
+	if err = json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if parsed.DriverName == "" {
+		parsed.DriverName = m.Author.Game()
+	}
+	if parsed.Players < 2 || parsed.Players > h.matchmakingMaxPlayers() {
+		return errors.New(InvalidPlayersNumber)
+	}
+	if !drivers.Exist(parsed.DriverName) {
+		return errors.New(InexistentDriver)
+	}
+
+	t := &ticket{
+		client:    m.Author,
+		driver:    parsed.DriverName,
+		players:   parsed.Players,
+		allowBots: parsed.AllowBots,
+		rating:    rating.InitialRating,
+		queuedAt:  time.Now(),
+	}
+	if h.Ratings != nil {
+		t.minRating = parsed.MinRating
+		t.maxRating = parsed.MaxRating
+		// Anonymous players are not rated, so they are matched with the initial rating
+		if userID := m.Author.UserID(); userID != "" {
+			rt, err := h.Ratings.Rating(rating.Ladder{Driver: parsed.DriverName}, userID)
+			if err != nil {
+				return err
+			}
+			t.rating = rt.Value
+		}
+	}
+	h.matchmaker.add(t)
+
+	wg.Add(1)
+	go h.sendResponse(m, messages.MatchmakingStatus{Queued: true, DriverName: t.driver, Players: t.players}, messages.TypeMatchmakingStatus)
+	return nil
+}
+
+// matchmakingMaxPlayers returns the maximum number of players clients can ask for in matchmaking
+func (h *Hub) matchmakingMaxPlayers() int {
+	if h.configuration.MatchmakingMaxPlayers > 0 {
+		return h.configuration.MatchmakingMaxPlayers
+	}
+	return defaultMatchmakingMaxPlayers
+}
+
+func (h *Hub) leaveMatchmakingAction(m *interfaces.IncomingMessage) error {
+	if !h.matchmaker.remove(m.Author) {
+		return errors.New(NotInMatchmaking)
+	}
+
+	wg.Add(1)
+	go h.sendResponse(m, messages.MatchmakingStatus{Queued: false}, messages.TypeMatchmakingStatus)
+	return nil
+}
+
+// match creates a room for every group of players formed by the matchmaker,
+// seating them and filling empty seats with bots if needed, before starting the game.
+// Groups are not formed while the server is not accepting new rooms, and groups
+// whose rooms couldn't be created are put back in the queue, to be tried again in the next round.
+func (h *Hub) match(now time.Time) {
+	if h.shuttingDown || h.maintenance {
+		return
+	}
+	for _, group := range h.matchmaker.groups(now, time.Second*h.configuration.MatchmakingBotsWait) {
+		if err := h.checkNewRoom(group[0].client); err != nil {
+			h.log.Debug("Matchmaking game waiting for a room", "driver", group[0].driver, "error", err)
+			h.matchmaker.requeue(group)
+			continue
+		}
+		if err := h.startMatch(group); err != nil {
+			h.log.Warn("Couldn't start matchmaking game", "driver", group[0].driver, "error", err)
+		}
+	}
+}
+
+func (h *Hub) startMatch(group []*ticket) error {
+	var driver api.Driver
+	var err error
+
+	if driver, err = drivers.Create(group[0].driver); err != nil {
+		return err
+	}
+	owner := group[0].client
+	r := h.rooms[h.createRoom(driver, owner, "")]
+	for i, t := range group[1:] {
+		if err = r.AddHuman(t.client, ""); err != nil {
+			// The rest of the group is matched again without the player who couldn't be seated
+			h.destroyRoom(r.ID(), messages.ReasonRoomDestroyedTerminated)
+			h.matchmaker.requeue(append(group[:i+1:i+1], group[i+2:]...))
+			wg.Add(1)
+			go h.sendMessage(t.client, messages.MatchmakingStatus{Queued: false}, messages.TypeMatchmakingStatus)
+			return err
+		}
+	}
+
+	for i := len(group); i < group[0].players; i++ {
+		seated := len(r.Clients())
+		h.parseInRoom(r, &interfaces.IncomingMessage{
+			Author:  owner,
+			Type:    messages.TypeAddBot,
+			Content: json.RawMessage(fmt.Sprintf(`{"lvl": %q}`, h.configuration.MatchmakingBotsLevel)),
+		})
+		// The room has already told the owner why the bot couldn't be added
+		if len(r.Clients()) == seated {
+			h.destroyRoom(r.ID(), messages.ReasonRoomDestroyedTerminated)
+			return errors.New(BotsNotAdded)
+		}
+	}
+	h.parseInRoom(r, &interfaces.IncomingMessage{
+		Author:  owner,
+		Type:    messages.TypeStartGame,
+		Content: json.RawMessage(fmt.Sprintf(`{"pto": %d}`, int64(h.configuration.MatchmakingPlayerTimeout))),
+	})
+
+	h.log.Debug("Matchmaking started a game", "room", r.ID(), "driver", group[0].driver, "players", group[0].players)
+	return nil
+}
@@ -0,0 +1,56 @@
+package hub
+
+import (
+	"github.com/svera/sackson-server/internal/metrics"
+)
+
+// Room states used in metrics
+const (
+	roomWaiting = "waiting"
+	roomPlaying = "playing"
+	roomOver    = "over"
+)
+
+// RegisterMetrics adds to the passed registry the gauges of connected clients
+// and rooms, which are collected from the hub's goroutine every time they are written
+func (h *Hub) RegisterMetrics(reg *metrics.Registry) {
+	reg.NewGaugeFunc(
+		"sackson_clients_connected",
+		"Number of connected clients, by game.",
+		"game",
+		h.clientsByGame,
+	)
+	reg.NewGaugeFunc(
+		"sackson_rooms",
+		"Number of rooms, by state.",
+		"state",
+		h.roomsByState,
+	)
+}
+
+func (h *Hub) clientsByGame() map[string]float64 {
+	values := map[string]float64{}
+	h.do(func() {
+		for game, clients := range h.clients {
+			values[game] = float64(len(clients))
+		}
+	})
+	return values
+}
+
+func (h *Hub) roomsByState() map[string]float64 {
+	values := map[string]float64{roomWaiting: 0, roomPlaying: 0, roomOver: 0}
+	h.do(func() {
+		for _, r := range h.rooms {
+			switch {
+			case r.IsGameOver():
+				values[roomOver]++
+			case r.GameStarted():
+				values[roomPlaying]++
+			default:
+				values[roomWaiting]++
+			}
+		}
+	})
+	return values
+}
@@ -0,0 +1,187 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// outbox numbers the messages sent to a session and keeps the ones not acknowledged yet,
+// so they can be sent again if the client resumes the session after reconnecting
+type outbox struct {
+	mutex sync.Mutex
+	// last is the client sequence number of the last message sent
+	last int
+	// pending holds the messages not acknowledged yet, oldest first
+	pending []*outgoing
+	// client is the one using the session, nil while disconnected
+	client interfaces.Client
+	// resumable is the number of the last message sent before the current client attached,
+	// the ones after it are already delivered to it
+	resumable  int
+	detachedAt time.Time
+}
+
+// outgoing is a message sent to a client, kept so it can be encoded again
+type outgoing struct {
+	typeName string
+	message  interface{}
+	// seq is the room sequence number, 0 if it has none
+	seq int
+	// csq is the client sequence number, 0 if it has none
+	csq int
+	// requestID identifies the client message this one answers, if any
+	requestID string
+}
+
+// send numbers the message and delivers it, keeping it if it is needed to resume the session.
+// Both things happen holding the outbox lock, so messages are delivered in order.
+func (o *outbox) send(out *outgoing, size int, deliver func(*outgoing) bool) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.last++
+	out.csq = o.last
+	if size > 0 {
+		if len(o.pending) == size {
+			o.pending = append(o.pending[:0], o.pending[1:]...)
+		}
+		o.pending = append(o.pending, out)
+	}
+	return deliver(out)
+}
+
+// acknowledge drops the pending messages up to the passed number
+func (o *outbox) acknowledge(csq int) {
+	i := 0
+	for i < len(o.pending) && o.pending[i].csq <= csq {
+		i++
+	}
+	o.pending = append(o.pending[:0], o.pending[i:]...)
+}
+
+// outboxOf returns the outbox of the client's session, or nil if it has none
+func (h *Hub) outboxOf(cl interfaces.Client) *outbox {
+	if cl.IsBot() || cl.SessionID() == "" {
+		return nil
+	}
+	h.outboxesMutex.Lock()
+	defer h.outboxesMutex.Unlock()
+	return h.outboxes[cl.SessionID()]
+}
+
+// attachOutbox makes the client use the outbox of its session, creating it if needed,
+// so the numbering of its messages continues from the previous connection
+func (h *Hub) attachOutbox(cl interfaces.Client) {
+	if cl.IsBot() || cl.SessionID() == "" {
+		return
+	}
+	h.outboxesMutex.Lock()
+	defer h.outboxesMutex.Unlock()
+	ob, ok := h.outboxes[cl.SessionID()]
+	if !ok {
+		ob = &outbox{}
+		h.outboxes[cl.SessionID()] = ob
+	}
+	ob.mutex.Lock()
+	ob.client = cl
+	ob.resumable = ob.last
+	ob.mutex.Unlock()
+}
+
+// detachOutbox marks the outbox of the client's session as disconnected,
+// dropping it once the resume window passes unless the session is resumed
+func (h *Hub) detachOutbox(cl interfaces.Client) {
+	ob := h.outboxOf(cl)
+	if ob == nil {
+		return
+	}
+	ob.mutex.Lock()
+	if ob.client != cl {
+		ob.mutex.Unlock()
+		return
+	}
+	ob.client = nil
+	ob.detachedAt = time.Now()
+	detachedAt := ob.detachedAt
+	ob.mutex.Unlock()
+
+	sessionID := cl.SessionID()
+	window := h.configuration.ResumeWindow * time.Second
+	if h.configuration.ResendBufferSize == 0 || window == 0 {
+		h.dropOutbox(sessionID, ob, detachedAt)
+		return
+	}
+	time.AfterFunc(window, func() {
+		h.dropOutbox(sessionID, ob, detachedAt)
+	})
+}
+
+// dropOutbox removes the outbox of the session if no client used it since it was detached
+func (h *Hub) dropOutbox(sessionID string, ob *outbox, detachedAt time.Time) {
+	h.outboxesMutex.Lock()
+	defer h.outboxesMutex.Unlock()
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+	if h.outboxes[sessionID] == ob && ob.client == nil && ob.detachedAt.Equal(detachedAt) {
+		delete(h.outboxes, sessionID)
+	}
+}
+
+func (h *Hub) ackAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.Ack
+
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	ob := h.outboxOf(m.Author)
+	if ob == nil || h.configuration.ResendBufferSize == 0 {
+		return errors.New(ResumeDisabled)
+	}
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+	if parsed.ClientSequence < 0 || parsed.ClientSequence > ob.last {
+		return errors.New(InexistentMessage)
+	}
+	ob.acknowledge(parsed.ClientSequence)
+	return nil
+}
+
+// resumeAction sends again the messages sent to the previous connection of the session
+// after the passed one, so the client gets the ones it missed while disconnected
+func (h *Hub) resumeAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.Resume
+
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	ob := h.outboxOf(m.Author)
+	if ob == nil || h.configuration.ResendBufferSize == 0 {
+		return errors.New(ResumeDisabled)
+	}
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+	if parsed.Last < 0 || parsed.Last > ob.resumable {
+		return errors.New(InexistentMessage)
+	}
+	if parsed.Last == ob.resumable {
+		return nil
+	}
+	ob.acknowledge(parsed.Last)
+	if len(ob.pending) == 0 || ob.pending[0].csq != parsed.Last+1 {
+		return errors.New(MessagesLost)
+	}
+	for _, out := range ob.pending {
+		if out.csq > ob.resumable {
+			break
+		}
+		if !h.deliver(m.Author, out) {
+			break
+		}
+	}
+	h.log.Debug("Session resumed", "client", m.Author.Name(), "from", parsed.Last, "to", ob.resumable)
+	return nil
+}
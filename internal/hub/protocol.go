@@ -0,0 +1,54 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+func (h *Hub) helloAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.Hello
+
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if !messages.IsSupportedProtocolVersion(parsed.Version) {
+		return errors.New(UnsupportedProtocolVersion)
+	}
+	m.Author.SetProtocolVersion(parsed.Version)
+
+	wg.Add(1)
+	go h.sendResponse(m, h.protocolMessage(m.Author), messages.TypeProtocol)
+	return nil
+}
+
+func (h *Hub) protocolMessage(cl interfaces.Client) messages.Protocol {
+	return messages.Protocol{
+		Version:      cl.ProtocolVersion(),
+		Supported:    messages.SupportedProtocolVersions,
+		Capabilities: h.capabilities(),
+	}
+}
+
+// capabilities returns the optional features enabled in the server
+func (h *Hub) capabilities() []string {
+	capabilities := []string{"async", "tournaments"}
+	if h.configuration.AuthSecret != "" {
+		capabilities = append(capabilities, "auth")
+	}
+	if h.configuration.MatchmakingInterval > 0 {
+		capabilities = append(capabilities, "matchmaking")
+	}
+	if h.configuration.DeltaUpdates {
+		capabilities = append(capabilities, "deltas")
+	}
+	if h.configuration.ResendBufferSize > 0 {
+		capabilities = append(capabilities, "resume")
+	}
+	if h.Ratings != nil {
+		capabilities = append(capabilities, "ratings")
+	}
+	return capabilities
+}
@@ -0,0 +1,125 @@
+package hub
+
+import (
+	"sort"
+	"time"
+
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/snapshot"
+)
+
+// drainCheckInterval is how often running games are checked while draining
+var drainCheckInterval = time.Second
+
+// Shutdown stops the creation of new games and waits up to the passed drain time
+// for the running ones to finish. Then persists the games still running if a snapshots
+// store is available, expels all clients from their rooms and disconnects them,
+// notifying them the server is shutting down.
+// Asynchronous games are not waited for, as they can last for days.
+func (h *Hub) Shutdown(drain time.Duration) {
+	h.do(func() {
+		h.shuttingDown = true
+		h.log.Info("Shutting down", "running_games", h.runningGames())
+	})
+
+	deadline := time.Now().Add(drain)
+	for time.Now().Before(deadline) {
+		var running int
+		h.do(func() {
+			running = h.runningGames()
+		})
+		if running == 0 {
+			break
+		}
+		time.Sleep(drainCheckInterval)
+	}
+
+	h.do(func() {
+		if h.Snapshots != nil {
+			h.saveSnapshots()
+		}
+
+		roomless := []interfaces.Client{}
+		all := []interfaces.Client{}
+		for _, clients := range h.clients {
+			for _, cl := range clients {
+				all = append(all, cl)
+				if cl.Room() == nil {
+					roomless = append(roomless, cl)
+				}
+			}
+		}
+
+		for id := range h.rooms {
+			h.destroyRoom(id, messages.ReasonServerShutdown)
+		}
+		wg.Add(len(roomless))
+		for _, cl := range roomless {
+			go h.sendMessage(cl, messages.ClientOut{Reason: messages.ReasonServerShutdown}, messages.TypeClientOut)
+		}
+		wg.Wait()
+
+		for _, cl := range all {
+			h.removeClient(cl)
+		}
+		h.log.Info("Shut down", "clients", len(all))
+	})
+}
+
+// runningGames returns the number of started, unfinished games in synchronous rooms
+func (h *Hub) runningGames() int {
+	running := 0
+	for _, r := range h.rooms {
+		if !r.IsAsync() && r.GameStarted() && !r.IsGameOver() {
+			running++
+		}
+	}
+	return running
+}
+
+// saveSnapshots persists the state of all started, unfinished games
+// whose drivers are able to serialize it
+func (h *Hub) saveSnapshots() {
+	now := time.Now()
+	for id, r := range h.rooms {
+		if !r.GameStarted() || r.IsGameOver() {
+			continue
+		}
+		state, err := r.Snapshot()
+		if err != nil {
+			h.log.Error("Error taking snapshot", "room", id, "error", err)
+			continue
+		}
+		if state == nil {
+			h.log.Debug("Game driver does not support snapshots", "room", id, "driver", r.GameDriverName())
+			continue
+		}
+
+		s := snapshot.Snapshot{
+			RoomID:  id,
+			Driver:  r.GameDriverName(),
+			Async:   r.IsAsync(),
+			TakenAt: now,
+			Players: []snapshot.Player{},
+			State:   state,
+		}
+		for n, cl := range r.Clients() {
+			s.Players = append(s.Players, snapshot.Player{
+				Number:    n,
+				Name:      cl.Name(),
+				Bot:       cl.IsBot(),
+				UserID:    cl.UserID(),
+				SessionID: cl.SessionID(),
+			})
+		}
+		sort.Slice(s.Players, func(i, j int) bool {
+			return s.Players[i].Number < s.Players[j].Number
+		})
+		if err = h.Snapshots.Save(s); err != nil {
+			h.log.Error("Error saving snapshot", "room", id, "error", err)
+			continue
+		}
+		h.log.Info("Snapshot saved", "room", id, "driver", s.Driver)
+	}
+}
@@ -2,11 +2,11 @@ package hub
 
 import (
 	"errors"
-	"log"
 
 	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
 	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/metrics"
 )
 
 func (h *Hub) terminateRoomAction(m *interfaces.IncomingMessage) error {
@@ -23,24 +23,21 @@ func (h *Hub) terminateRoomAction(m *interfaces.IncomingMessage) error {
 func (h *Hub) destroyRoom(roomID string, reasonCode string) {
 	mutex.Lock()
 	defer mutex.Unlock()
-	if h.configuration.Debug {
-		log.Printf("Destroying room %s...", roomID)
-	}
+	h.log.Debug("Destroying room", "room", roomID, "reason", reasonCode)
 	if r, ok := h.rooms[roomID]; ok {
 		r.ToBeDestroyed(true)
+		metrics.RoomsDestroyed.Inc(reasonCode)
 		if r.Timer() != nil {
 			r.Timer().Stop()
 		}
 		h.expelClientsFromRoom(r, reasonCode)
 		gameName := h.rooms[roomID].GameDriverName()
 		delete(h.rooms, roomID)
-		h.observer.Trigger(events.RoomDestroyed{GameName: gameName})
+		h.observer.Trigger(events.RoomDestroyed{RoomID: roomID, GameName: gameName})
 
-		if h.configuration.Debug {
-			log.Printf("Room %s destroyed\n", roomID)
-		}
+		h.log.Debug("Room destroyed", "room", roomID, "driver", gameName)
 	} else {
-		log.Printf("No existe %s", roomID)
+		h.log.Warn("Tried to destroy an inexistent room", "room", roomID)
 	}
 }
 
@@ -48,15 +45,11 @@ func (h *Hub) expelClientsFromRoom(r interfaces.Room, reasonCode string) {
 	for _, cl := range r.Clients() {
 		if cl.IsBot() {
 			cl.Close()
-			if h.configuration.Debug {
-				log.Printf("Bot %s destroyed", cl.Name())
-			}
+			h.log.Debug("Bot destroyed", "room", r.ID(), "client", cl.Name())
 		} else {
 			r.RemoveClient(cl)
 			h.observer.Trigger(events.ClientOut{Client: cl, Reason: reasonCode, Room: r})
-			if h.configuration.Debug {
-				log.Printf("Client expelled from room %s\n", r.ID())
-			}
+			h.log.Debug("Client expelled from room", "room", r.ID(), "client", cl.Name(), "reason", reasonCode)
 		}
 	}
 }
@@ -0,0 +1,358 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/svera/sackson-server/internal/drivers"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/tournament"
+)
+
+func (h *Hub) createTournamentAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.CreateTournament
+	var err error
+	var t *tournament.Tournament
+
+	if err = json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if parsed.DriverName == "" {
+		parsed.DriverName = m.Author.Game()
+	}
+	if !drivers.Exist(parsed.DriverName) {
+		return errors.New(InexistentDriver)
+	}
+
+	settings := tournament.Settings{
+		Name:          parsed.Name,
+		Driver:        parsed.DriverName,
+		Format:        parsed.Format,
+		TableSize:     parsed.TableSize,
+		Rounds:        parsed.Rounds,
+		Advance:       parsed.Advance,
+		PlayerTimeout: parsed.PlayerTimeout,
+	}
+	if t, err = h.Tournaments.Create(participantID(m.Author), m.Author.Name(), settings); err != nil {
+		return err
+	}
+	h.bindTournamentClient(t.ID, m.Author)
+
+	h.log.Debug("Tournament created", "tournament", t.ID, "driver", t.Settings.Driver, "client", m.Author.Name())
+	h.sendTournament(t)
+	return nil
+}
+
+func (h *Hub) joinTournamentAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.TournamentRequest
+	var err error
+	var t *tournament.Tournament
+
+	if err = json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if t, err = h.Tournaments.Join(parsed.ID, participantID(m.Author), m.Author.Name()); err != nil {
+		return err
+	}
+	h.bindTournamentClient(t.ID, m.Author)
+	h.sendTournament(t)
+	return nil
+}
+
+func (h *Hub) startTournamentAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.TournamentRequest
+	var err error
+	var t *tournament.Tournament
+
+	if err = json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if t, err = h.Tournaments.Start(parsed.ID, participantID(m.Author)); err != nil {
+		return err
+	}
+	if t.State == tournament.Running {
+		t = h.startTournamentRound(t)
+	}
+	h.sendTournament(t)
+	return nil
+}
+
+func (h *Hub) tournamentInfoAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.TournamentRequest
+	var err error
+	var t *tournament.Tournament
+
+	if err = json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if t, err = h.Tournaments.Get(parsed.ID); err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go h.sendResponse(m, tournamentMessage(t), messages.TypeTournament)
+	return nil
+}
+
+// participantID returns the identifier of the passed client in tournaments:
+// its user ID if authenticated, or its session ID otherwise.
+// Names are not used, as any client can choose the name of another one.
+func participantID(cl interfaces.Client) string {
+	if cl.UserID() != "" {
+		return "user:" + cl.UserID()
+	}
+	return "session:" + cl.SessionID()
+}
+
+// resultParticipantID returns the identifier in tournaments of the player of the passed result
+func resultParticipantID(r messages.PlayerResult) string {
+	if r.UserID != "" {
+		return "user:" + r.UserID
+	}
+	return "session:" + r.SessionID
+}
+
+// bindTournamentClient stores the client used by a tournament participant,
+// so he/she can be seated in the tables where he/she has to play
+func (h *Hub) bindTournamentClient(id string, cl interfaces.Client) {
+	if _, ok := h.tournamentClients[id]; !ok {
+		h.tournamentClients[id] = map[string]interfaces.Client{}
+	}
+	h.tournamentClients[id][participantID(cl)] = cl
+}
+
+// tournamentClient returns the client of the tournament participant with the passed ID,
+// or nil if he/she is not connected anymore
+func (h *Hub) tournamentClient(id string, playerID string) interfaces.Client {
+	cl, ok := h.tournamentClients[id][playerID]
+	if !ok {
+		return nil
+	}
+	for _, connected := range h.clients[cl.Game()] {
+		if connected == cl {
+			return cl
+		}
+	}
+	return nil
+}
+
+// startTournamentRound creates a room for every table of the current round of the tournament,
+// seating its players and starting the game
+func (h *Hub) startTournamentRound(t *tournament.Tournament) *tournament.Tournament {
+	for _, table := range t.CurrentRound().Tables {
+		h.startTournamentTable(t, table)
+	}
+
+	updated, _ := h.Tournaments.Get(t.ID)
+	return updated
+}
+
+// startTournamentTable creates a room for a table of the current round of the tournament,
+// seating its players and starting the game. Players who are not connected, or are
+// playing in another room, lose the table by forfeit. If the server is not accepting
+// new rooms, the table waits until it does.
+func (h *Hub) startTournamentTable(t *tournament.Tournament, table *tournament.Table) {
+	round := t.CurrentRound().Number
+	seated := []interfaces.Client{}
+	present := []string{}
+	for _, name := range table.Players {
+		if cl := h.tournamentClient(t.ID, t.Player(name).ID); cl != nil && cl.Room() == nil {
+			seated = append(seated, cl)
+			present = append(present, name)
+		}
+	}
+
+	if len(seated) > 1 {
+		if err := h.checkNewRoom(seated[0]); err != nil {
+			h.log.Debug("Tournament table waiting for a room", "tournament", t.ID, "table", table.Number, "error", err)
+			if _, ok := h.waitingTables[t.ID]; !ok {
+				h.waitingTables[t.ID] = map[int]bool{}
+			}
+			h.waitingTables[t.ID][table.Number] = true
+			return
+		}
+	}
+
+	if err := h.playTournamentTable(t, table.Number, seated); err != nil {
+		h.log.Debug("Couldn't start tournament table", "tournament", t.ID, "table", table.Number, "error", err)
+		id, number, results := t.ID, table.Number, []tournament.Result{}
+		for _, name := range present {
+			results = append(results, tournament.Result{Player: name, Rank: 1})
+		}
+		h.runLater(func() {
+			h.recordTournamentForfeit(id, round, number, results)
+		})
+	}
+}
+
+// startWaitingTournamentTables tries again to start the tournament tables which were
+// waiting for the server to accept new rooms
+func (h *Hub) startWaitingTournamentTables() {
+	waiting := h.waitingTables
+	h.waitingTables = map[string]map[int]bool{}
+	for id, numbers := range waiting {
+		t, err := h.Tournaments.Get(id)
+		if err != nil || t.State != tournament.Running {
+			continue
+		}
+		for _, table := range t.CurrentRound().Tables {
+			if numbers[table.Number] && !table.Done && table.RoomID == "" {
+				h.startTournamentTable(t, table)
+			}
+		}
+		if t, err = h.Tournaments.Get(id); err == nil {
+			h.sendTournament(t)
+		}
+	}
+}
+
+func (h *Hub) playTournamentTable(t *tournament.Tournament, tableNumber int, seated []interfaces.Client) error {
+	if len(seated) < 2 {
+		return errors.New(tournament.NotEnoughPlayers)
+	}
+	driver, err := drivers.Create(t.Settings.Driver)
+	if err != nil {
+		return err
+	}
+
+	r := h.rooms[h.createRoom(driver, seated[0], "")]
+	for _, cl := range seated[1:] {
+		if err = r.AddHuman(cl, ""); err != nil {
+			// Otherwise the seated players would be taken as playing in another room in later rounds
+			h.destroyRoom(r.ID(), messages.ReasonRoomDestroyedTerminated)
+			return err
+		}
+	}
+	if err = h.Tournaments.SetTableRoom(t.ID, tableNumber, r.ID()); err != nil {
+		h.destroyRoom(r.ID(), messages.ReasonRoomDestroyedTerminated)
+		return err
+	}
+	h.parseInRoom(r, &interfaces.IncomingMessage{
+		Author:  seated[0],
+		Type:    messages.TypeStartGame,
+		Content: json.RawMessage(fmt.Sprintf(`{"pto": %d}`, int64(t.Settings.PlayerTimeout))),
+	})
+	return nil
+}
+
+// tournamentTableEnded is called when the game of a tournament table ends or
+// its room is destroyed before that, whatever happens first, as destroying the room
+// of a finished game doesn't end its table again. Results are recorded from the hub's goroutine,
+// after destroying the room so its players can be seated in the next round.
+func (h *Hub) tournamentTableEnded(roomID string, results []messages.PlayerResult) {
+	id, ok := h.Tournaments.EndTable(roomID)
+	if !ok {
+		return
+	}
+	t, err := h.Tournaments.Get(id)
+	if err != nil {
+		return
+	}
+
+	tournamentResults := make([]tournament.Result, 0, len(results))
+	for _, r := range results {
+		if p := t.PlayerByID(resultParticipantID(r)); p != nil && !r.Bot {
+			tournamentResults = append(tournamentResults, tournament.Result{Player: p.Name, Rank: r.Rank, Score: r.Score})
+		}
+	}
+	h.runLater(func() {
+		if _, exists := h.rooms[roomID]; exists {
+			h.destroyRoom(roomID, messages.ReasonTournamentTableFinished)
+		}
+		h.recordTournamentTable(roomID, tournamentResults)
+	})
+}
+
+func (h *Hub) recordTournamentTable(roomID string, results []tournament.Result) {
+	t, roundFinished, err := h.Tournaments.Record(roomID, results)
+	if err != nil {
+		h.log.Warn("Couldn't record tournament table results", "room", roomID, "error", err)
+		return
+	}
+	h.tournamentTableRecorded(t, roundFinished)
+}
+
+func (h *Hub) recordTournamentForfeit(id string, round int, tableNumber int, results []tournament.Result) {
+	t, roundFinished, err := h.Tournaments.RecordForfeit(id, round, tableNumber, results)
+	if err != nil {
+		h.log.Warn("Couldn't record tournament table results", "tournament", id, "table", tableNumber, "error", err)
+		return
+	}
+	h.tournamentTableRecorded(t, roundFinished)
+}
+
+// tournamentTableRecorded starts the next round of the tournament if the recorded table
+// finished the current one, and sends its new state to its participants
+func (h *Hub) tournamentTableRecorded(t *tournament.Tournament, roundFinished bool) {
+	if roundFinished && t.State == tournament.Running {
+		t = h.startTournamentRound(t)
+	}
+	if t.State == tournament.Finished {
+		h.log.Debug("Tournament finished", "tournament", t.ID, "winner", t.Winner)
+	}
+	h.sendTournament(t)
+}
+
+// sendTournament sends the tournament state to all its connected participants and its organizer
+func (h *Hub) sendTournament(t *tournament.Tournament) {
+	message := tournamentMessage(t)
+	for playerID := range h.tournamentClients[t.ID] {
+		if cl := h.tournamentClient(t.ID, playerID); cl != nil {
+			wg.Add(1)
+			go h.sendMessage(cl, message, messages.TypeTournament)
+		}
+	}
+}
+
+func (h *Hub) registerTournamentEvents() {
+	h.observer.On(events.GameEnded{}, func(ev interface{}) {
+		if event, ok := ev.(events.GameEnded); ok {
+			h.tournamentTableEnded(event.Room.ID(), event.Results)
+		}
+	})
+
+	h.observer.On(events.RoomDestroyed{}, func(ev interface{}) {
+		if event, ok := ev.(events.RoomDestroyed); ok {
+			h.tournamentTableEnded(event.RoomID, nil)
+			// The destroyed room may have freed room for the tables waiting for one
+			h.runLater(h.startWaitingTournamentTables)
+		}
+	})
+}
+
+func tournamentMessage(t *tournament.Tournament) messages.Tournament {
+	message := messages.Tournament{
+		ID:         t.ID,
+		Name:       t.Settings.Name,
+		DriverName: t.Settings.Driver,
+		Format:     t.Settings.Format,
+		State:      t.State,
+		Standings:  []messages.TournamentStanding{},
+		Tables:     []messages.TournamentTable{},
+		Winner:     t.Winner,
+	}
+	for _, p := range t.Standings() {
+		message.Standings = append(message.Standings, messages.TournamentStanding{
+			Name:       p.Name,
+			Points:     p.Points,
+			Score:      p.Score,
+			Games:      p.Games,
+			Eliminated: p.Eliminated,
+		})
+	}
+	if round := t.CurrentRound(); round != nil {
+		message.Round = round.Number
+		for _, table := range round.Tables {
+			message.Tables = append(message.Tables, messages.TournamentTable{
+				Number:  table.Number,
+				Players: table.Players,
+				Room:    table.RoomID,
+				Done:    table.Done,
+			})
+		}
+	}
+	return message
+}
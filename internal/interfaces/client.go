@@ -1,6 +1,10 @@
 package interfaces
 
-import "time"
+import (
+	"time"
+
+	"github.com/svera/sackson-server/internal/codec"
+)
 
 // Client is an interface that defines the minimum set of functions needed
 // to implement a client which can be used within a hub instance
@@ -19,4 +23,13 @@ type Client interface {
 	StartTimer(d time.Duration)
 	SetGame(game string)
 	Game() string
+	SessionID() string
+	SetSessionID(id string)
+	UserID() string
+	SetUserID(id string)
+	Address() string
+	SetAddress(addr string)
+	ProtocolVersion() int
+	SetProtocolVersion(v int)
+	Codec() codec.Codec
 }
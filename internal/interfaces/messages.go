@@ -8,9 +8,15 @@ import "encoding/json"
 // field is set by the client itself.
 type IncomingMessage struct {
 	// Author is fulfilled automatically by the system whenever a message is received
-	Author  Client
-	Type    string          `json:"typ"`
-	Content json.RawMessage `json:"cnt"`
+	Author Client
+	Type   string `json:"typ"`
+	// RequestID is an optional identifier set by the client, so it can tell
+	// which of its messages each response answers
+	RequestID string          `json:"rid,omitempty"`
+	Content   json.RawMessage `json:"cnt"`
+	// Rejected holds the error of a message refused by its client before reaching the hub,
+	// as happens to rate limited ones, so the hub only has to answer it with that error
+	Rejected string `json:"-"`
 }
 
 // OutgoingMessage is a wrapper used by
@@ -2,6 +2,9 @@ package interfaces
 
 import (
 	"time"
+
+	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/names"
 )
 
 // Room is an interface that defines the minimum set of functions a room
@@ -15,7 +18,9 @@ type Room interface {
 	Owner() Client
 	Clients() map[int]Client
 	HumanClients() []Client
-	AddHuman(c Client) error
+	// AddHuman seats the client in the room, requestID being the identifier
+	// of the client's message which asked for it, if any
+	AddHuman(c Client, requestID string) error
 	SetTimer(t *time.Timer)
 	Timer() *time.Timer
 	GameCurrentPlayersClients() ([]Client, error)
@@ -23,4 +28,13 @@ type Room interface {
 	ToBeDestroyed(bool)
 	GameDriverName() string
 	PlayerTimeOut() time.Duration
+	IsAsync() bool
+	SetAsync(bool)
+	SetNamePolicy(p *names.Policy)
+	DetachClient(c Client)
+	ReattachClient(c Client) bool
+	CreatedAt() time.Time
+	Snapshot() ([]byte, error)
+	// LegalActions returns the actions the client can currently do in the game
+	LegalActions(c Client) ([]api.Action, error)
 }
@@ -0,0 +1,231 @@
+// Package jsonpatch creates and applies JSON Patch (RFC 6902) documents,
+// used to send clients the changes between game statuses instead of whole statuses.
+// Only the add, remove and replace operations are supported.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operations supported
+const (
+	Add     = "add"
+	Remove  = "remove"
+	Replace = "replace"
+)
+
+// Errors returned when applying a patch
+var (
+	ErrInvalidPath          = errors.New("jsonpatch: invalid path")
+	ErrUnsupportedOperation = errors.New("jsonpatch: unsupported operation")
+)
+
+// Operation is a single change of a JSON document
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a list of operations, applied in order
+type Patch []Operation
+
+// Diff returns the patch that transforms the JSON document a into b.
+// Arrays whose length changed are replaced as a whole.
+func Diff(a, b []byte) (Patch, error) {
+	var va, vb interface{}
+	var err error
+	if va, err = decode(a); err != nil {
+		return nil, err
+	}
+	if vb, err = decode(b); err != nil {
+		return nil, err
+	}
+	p := Patch{}
+	err = diff("", va, vb, &p)
+	return p, err
+}
+
+func decode(data []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Numbers are kept as they are, so they are compared and copied exactly
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func diff(path string, a, b interface{}, p *Patch) error {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		if vb, ok := b.(map[string]interface{}); ok {
+			return diffObjects(path, va, vb, p)
+		}
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok && len(va) == len(vb) {
+			for i := range va {
+				if err := diff(path+"/"+strconv.Itoa(i), va[i], vb[i], p); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return p.add(Replace, path, b)
+}
+
+func diffObjects(path string, a, b map[string]interface{}, p *Patch) error {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		va, inA := a[k]
+		vb, inB := b[k]
+		childPath := path + "/" + escape(k)
+		var err error
+		switch {
+		case !inB:
+			*p = append(*p, Operation{Op: Remove, Path: childPath})
+		case !inA:
+			err = p.add(Add, childPath, vb)
+		default:
+			err = diff(childPath, va, vb, p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Patch) add(op string, path string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, Operation{Op: op, Path: path, Value: encoded})
+	return nil
+}
+
+// Apply returns the result of applying the patch to the JSON document
+func Apply(doc []byte, p Patch) ([]byte, error) {
+	v, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range p {
+		var value interface{}
+		if op.Op != Remove {
+			if value, err = decode(op.Value); err != nil {
+				return nil, err
+			}
+		}
+		if op.Path == "" {
+			if op.Op == Remove {
+				return nil, ErrInvalidPath
+			}
+			v = value
+			continue
+		}
+		if !strings.HasPrefix(op.Path, "/") {
+			return nil, ErrInvalidPath
+		}
+		if v, err = apply(v, strings.Split(op.Path[1:], "/"), op.Op, value); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(v)
+}
+
+// apply changes the value at the path formed by the passed tokens inside doc,
+// returning doc, or a new slice if doc is an array which changed its length
+func apply(doc interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	token := unescape(tokens[0])
+	last := len(tokens) == 1
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		child, exists := d[token]
+		if !last {
+			if !exists {
+				return nil, ErrInvalidPath
+			}
+			updated, err := apply(child, tokens[1:], op, value)
+			d[token] = updated
+			return d, err
+		}
+		switch op {
+		case Add:
+			d[token] = value
+		case Replace, Remove:
+			if !exists {
+				return nil, ErrInvalidPath
+			}
+			if op == Replace {
+				d[token] = value
+			} else {
+				delete(d, token)
+			}
+		default:
+			return nil, ErrUnsupportedOperation
+		}
+		return d, nil
+
+	case []interface{}:
+		if last && op == Add && token == "-" {
+			return append(d, value), nil
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(d) || (i == len(d) && !(last && op == Add)) {
+			return nil, ErrInvalidPath
+		}
+		if !last {
+			d[i], err = apply(d[i], tokens[1:], op, value)
+			return d, err
+		}
+		switch op {
+		case Add:
+			d = append(d, nil)
+			copy(d[i+1:], d[i:])
+			d[i] = value
+		case Replace:
+			d[i] = value
+		case Remove:
+			d = append(d[:i], d[i+1:]...)
+		default:
+			return nil, ErrUnsupportedOperation
+		}
+		return d, nil
+	}
+	return nil, ErrInvalidPath
+}
+
+var (
+	escaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	unescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func escape(token string) string {
+	return escaper.Replace(token)
+}
+
+func unescape(token string) string {
+	return unescaper.Replace(token)
+}
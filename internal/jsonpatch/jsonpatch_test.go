@@ -0,0 +1,91 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func equalJSON(t *testing.T, a, b []byte) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestDiffAndApply(t *testing.T) {
+	cases := []struct {
+		a, b string
+	}{
+		{`{"brd": {"1A": "empty", "2A": "empty"}, "csh": 6000}`, `{"brd": {"1A": "Luxor", "2A": "empty"}, "csh": 5700}`},
+		{`{"hnd": ["1A", "2B", "3C"]}`, `{"hnd": ["1A", "4D", "3C"]}`},
+		{`{"hnd": ["1A", "2B", "3C"]}`, `{"hnd": ["1A", "3C"]}`},
+		{`{"a": 1, "b": {"c": null}}`, `{"b": {"c": [1, 2]}, "d": true}`},
+		{`{"a/b": 1, "m~n": 2}`, `{"a/b": 3, "m~n": 4}`},
+		{`{"a": 1}`, `[1, 2]`},
+		{`{"big": 12345678901234567890}`, `{"big": 12345678901234567891}`},
+	}
+	for _, c := range cases {
+		p, err := Diff([]byte(c.a), []byte(c.b))
+		if err != nil {
+			t.Fatalf("Diff must not return an error, got %s", err.Error())
+		}
+		result, err := Apply([]byte(c.a), p)
+		if err != nil {
+			t.Fatalf("Apply must not return an error, got %s", err.Error())
+		}
+		if !equalJSON(t, result, []byte(c.b)) {
+			encoded, _ := json.Marshal(p)
+			t.Errorf("Applying the diff of %s must give %s, got %s with patch %s", c.a, c.b, result, encoded)
+		}
+	}
+}
+
+func TestDiffOnlyContainsChanges(t *testing.T) {
+	p, _ := Diff([]byte(`{"brd": {"1A": "empty", "2A": "empty"}, "sta": "PlayTile"}`), []byte(`{"brd": {"1A": "Luxor", "2A": "empty"}, "sta": "PlayTile"}`))
+
+	expected := Patch{{Op: Replace, Path: "/brd/1A", Value: json.RawMessage(`"Luxor"`)}}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Patch must only replace the changed value, got %+v", p)
+	}
+	if p, _ = Diff([]byte(`{"a": [1]}`), []byte(`{"a": [1]}`)); len(p) != 0 {
+		t.Errorf("Patch of equal documents must be empty, got %+v", p)
+	}
+}
+
+func TestApplyStandardOperations(t *testing.T) {
+	var p Patch
+	json.Unmarshal([]byte(`[
+		{"op": "add", "path": "/hnd/1", "value": "9I"},
+		{"op": "add", "path": "/hnd/-", "value": "12A"},
+		{"op": "remove", "path": "/hnd/0"},
+		{"op": "add", "path": "/new", "value": {"x": 1}}
+	]`), &p)
+
+	result, err := Apply([]byte(`{"hnd": ["1A", "2B"]}`), p)
+	if err != nil {
+		t.Fatalf("Apply must not return an error, got %s", err.Error())
+	}
+	if !equalJSON(t, result, []byte(`{"hnd": ["9I", "2B", "12A"], "new": {"x": 1}}`)) {
+		t.Errorf("Unexpected patched document, got %s", result)
+	}
+}
+
+func TestApplyInvalidPaths(t *testing.T) {
+	patches := []Patch{
+		{{Op: Replace, Path: "/missing", Value: json.RawMessage(`1`)}},
+		{{Op: Remove, Path: "/hnd/5"}},
+		{{Op: Add, Path: "/a/b", Value: json.RawMessage(`1`)}},
+		{{Op: Replace, Path: "hnd", Value: json.RawMessage(`1`)}},
+		{{Op: "move", Path: "/hnd"}},
+	}
+	for _, p := range patches {
+		if _, err := Apply([]byte(`{"hnd": ["1A"]}`), p); err == nil {
+			t.Errorf("Applying %+v must return an error", p)
+		}
+	}
+}
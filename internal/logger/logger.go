@@ -0,0 +1,190 @@
+// Package logger implements a levelled, structured logger which writes entries
+// as text or JSON, each one carrying the context fields of the logger used,
+// e.g. the room or client it refers to.
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level defines the importance of a log entry
+type Level int
+
+// Log levels, from less to more important
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level
+func (l Level) String() string {
+	if l < DebugLevel || l > ErrorLevel {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the passed name
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.ToLower(name) == n {
+			return Level(i), nil
+		}
+	}
+	return InfoLevel, errors.New("Invalid log level " + name)
+}
+
+// Options holds the settings of a logger
+type Options struct {
+	// JSON makes entries be written as JSON objects, one per line, instead of text
+	JSON bool
+	// Level is the minimum level of the entries written
+	Level Level
+	// Levels overrides Level for the subsystems with the passed names
+	Levels map[string]Level
+}
+
+// sink is shared by a logger and all the loggers derived from it
+type sink struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	options Options
+	now     func() time.Time
+}
+
+// Logger writes log entries with the fields of its context
+type Logger struct {
+	sink   *sink
+	level  Level
+	fields []interface{}
+}
+
+// New returns a new Logger instance which writes to out
+func New(out io.Writer, opts Options) *Logger {
+	return &Logger{
+		sink:  &sink{out: out, options: opts, now: time.Now},
+		level: opts.Level,
+	}
+}
+
+// Discard returns a logger which doesn't write anything, useful for tests
+func Discard() *Logger {
+	return New(ioutil.Discard, Options{Level: ErrorLevel + 1})
+}
+
+// Subsystem returns a logger for the subsystem with the passed name,
+// using its own level if one is defined in the logger options
+func (l *Logger) Subsystem(name string) *Logger {
+	child := l.With("subsystem", name)
+	if level, ok := l.sink.options.Levels[name]; ok {
+		child.level = level
+	}
+	return child
+}
+
+// With returns a logger which adds the passed key/value pairs to every entry
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	return &Logger{sink: l.sink, level: l.level, fields: fields}
+}
+
+// Enabled returns true if entries of the passed level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug writes a debug entry with the passed message and key/value pairs
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.write(DebugLevel, msg, keyvals)
+}
+
+// Info writes an info entry with the passed message and key/value pairs
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.write(InfoLevel, msg, keyvals)
+}
+
+// Warn writes a warning entry with the passed message and key/value pairs
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.write(WarnLevel, msg, keyvals)
+}
+
+// Error writes an error entry with the passed message and key/value pairs
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.write(ErrorLevel, msg, keyvals)
+}
+
+func (l *Logger) write(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	fields := append(append([]interface{}{}, l.fields...), keyvals...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, "(missing)")
+	}
+
+	var buf bytes.Buffer
+	now := l.sink.now().UTC().Format(time.RFC3339)
+	if l.sink.options.JSON {
+		writeJSON(&buf, now, level, msg, fields)
+	} else {
+		writeText(&buf, now, level, msg, fields)
+	}
+
+	l.sink.mutex.Lock()
+	defer l.sink.mutex.Unlock()
+	l.sink.out.Write(buf.Bytes())
+}
+
+func writeText(buf *bytes.Buffer, now string, level Level, msg string, fields []interface{}) {
+	fmt.Fprintf(buf, "%s %-5s %s", now, strings.ToUpper(level.String()), msg)
+	for i := 0; i < len(fields); i += 2 {
+		value := fmt.Sprint(value(fields[i+1]))
+		if value == "" || strings.ContainsAny(value, " \"=\n") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(buf, " %v=%s", fields[i], value)
+	}
+	buf.WriteByte('\n')
+}
+
+// writeJSON writes the entry keeping the order of its fields
+func writeJSON(buf *bytes.Buffer, now string, level Level, msg string, fields []interface{}) {
+	fmt.Fprintf(buf, `{"time":%q,"level":%q,"msg":%s`, now, level.String(), marshal(msg))
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(buf, ",%s:%s", marshal(fmt.Sprint(fields[i])), marshal(value(fields[i+1])))
+	}
+	buf.WriteString("}\n")
+}
+
+// value returns the passed field value in a form that can be written
+func value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
+
+func marshal(v interface{}) []byte {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return encoded
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLogger(out *bytes.Buffer, opts Options) *Logger {
+	l := New(out, opts)
+	l.sink.now = func() time.Time {
+		return time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)
+	}
+	return l
+}
+
+func TestTextEntriesCarryContext(t *testing.T) {
+	var out bytes.Buffer
+	l := newTestLogger(&out, Options{Level: DebugLevel})
+
+	l.With("room", "VWXYZ").Info("Client added", "client", "Player 1")
+
+	expected := "2018-01-02T10:00:00Z INFO  Client added room=VWXYZ client=\"Player 1\"\n"
+	if out.String() != expected {
+		t.Errorf("Expected entry '%s', got '%s'", expected, out.String())
+	}
+}
+
+func TestJSONEntries(t *testing.T) {
+	var out bytes.Buffer
+	var entry map[string]interface{}
+	l := newTestLogger(&out, Options{JSON: true, Level: DebugLevel})
+
+	l.With("room", "VWXYZ").Error("Panic", "error", errors.New("boom"), "players", 3)
+
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Entry must be valid JSON, got '%s'", out.String())
+	}
+	if entry["level"] != "error" || entry["msg"] != "Panic" || entry["room"] != "VWXYZ" || entry["error"] != "boom" || entry["players"] != float64(3) {
+		t.Errorf("Entry doesn't have the expected fields, got '%s'", out.String())
+	}
+}
+
+func TestLevelsPerSubsystem(t *testing.T) {
+	var out bytes.Buffer
+	l := newTestLogger(&out, Options{Level: WarnLevel, Levels: map[string]Level{"room": DebugLevel}})
+
+	l.Subsystem("hub").Info("Not written")
+	if out.Len() != 0 {
+		t.Errorf("Entries below the logger level must not be written, got '%s'", out.String())
+	}
+	l.Subsystem("room").Debug("Written")
+	if out.Len() == 0 {
+		t.Errorf("Subsystems must use their own level if defined")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("WARN"); err != nil || level != WarnLevel {
+		t.Errorf("ParseLevel must return the level with the passed name, got %s", level)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("ParseLevel must return an error for unknown levels")
+	}
+}
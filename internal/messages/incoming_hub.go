@@ -1,5 +1,7 @@
 package messages
 
+import "time"
+
 // TypeCreateRoom defines the value that create room
 // messages must have in the Type field.
 //
@@ -13,6 +15,7 @@ package messages
 //     "cnt": {
 //       "drv": "acquire"
 //       "nam": "Sergio" // Name of its creator / owner
+//       "asy": true // Optional, creates a room for asynchronous (correspondence) play
 //     }
 //   }
 const TypeCreateRoom = "cre"
@@ -22,6 +25,9 @@ const TypeCreateRoom = "cre"
 type CreateRoom struct {
 	DriverName string `json:"drv"`
 	ClientName string `json:"nam"`
+	// Async rooms persist when their players disconnect, who can get back to them
+	// later using their session identifier
+	Async bool `json:"asy"`
 }
 
 // TypeJoinRoom defines the value that join room
@@ -62,3 +68,166 @@ type JoinRoom struct {
 //     "cnt": {} // No content needed
 //   }
 const TypeTerminateRoom = "ter"
+
+// TypeRequestLeaderboard defines the value that request leaderboard
+// messages must have in the Type field.
+//
+// A MessageLeaderboard message is sent back to the client with the requested leaderboard.
+// If no driver is specified, the one the client is connected to is used.
+// Leaderboards of games played with bots are returned if "bot" is true.
+//
+// The following is a RequestLeaderboard message example:
+//   {
+//     "typ": "ldb",
+//     "cnt": {
+//       "drv": "acquire",
+//       "bot": false,
+//       "lim": 20
+//     }
+//   }
+const TypeRequestLeaderboard = "ldb"
+
+// RequestLeaderboard defines the needed parameters for a request leaderboard message.
+type RequestLeaderboard struct {
+	DriverName string `json:"drv"`
+	Bots       bool   `json:"bot"`
+	Limit      int    `json:"lim"`
+}
+
+// TypeJoinMatchmaking defines the value that join matchmaking
+// messages must have in the Type field.
+//
+// The client is put in a queue until there are enough compatible players to play a game,
+// then a room is created with all of them and the game is started automatically.
+// If the client allows bots, empty seats are filled with them after waiting in the queue
+// for a while.
+// Rating range limits are optional, and are only used if ratings are enabled.
+// If no driver is specified, the one the client is connected to is used.
+//
+// A MessageMatchmakingStatus message is sent to the client when he/she joins the queue.
+//
+// The following is a JoinMatchmaking message example:
+//   {
+//     "typ": "mmj",
+//     "cnt": {
+//       "drv": "acquire",
+//       "ply": 4,
+//       "bot": true,
+//       "rmn": 1400,
+//       "rmx": 1700
+//     }
+//   }
+const TypeJoinMatchmaking = "mmj"
+
+// JoinMatchmaking defines the needed parameters for a join matchmaking message.
+type JoinMatchmaking struct {
+	DriverName string `json:"drv"`
+	Players    int    `json:"ply"`
+	AllowBots  bool   `json:"bot"`
+	MinRating  int    `json:"rmn"`
+	MaxRating  int    `json:"rmx"`
+}
+
+// TypeLeaveMatchmaking defines the value that leave matchmaking
+// messages must have in the Type field.
+//
+// A MessageMatchmakingStatus message is sent to the client when he/she leaves the queue.
+//
+// The following is a LeaveMatchmaking message example:
+//   {
+//     "typ": "mml",
+//     "cnt": {} // No content needed
+//   }
+const TypeLeaveMatchmaking = "mml"
+
+// TypeCreateTournament defines the value that create tournament
+// messages must have in the Type field.
+//
+// The client who creates the tournament becomes its organizer. Players can join it
+// until the organizer starts it. Then, a room is created for each table of every round,
+// the players of the table are seated in it and the game is started automatically.
+//
+// Format can be "swiss" or "elimination". Swiss tournaments need the number of rounds
+// to be played, while elimination tournaments accept how many players of each table
+// advance to the next round (1 by default).
+//
+// A MessageTournament message is sent to the client with the new tournament.
+//
+// The following is a CreateTournament message example:
+//   {
+//     "typ": "tcr",
+//     "cnt": {
+//       "nam": "Monthly Acquire",
+//       "drv": "acquire",
+//       "fmt": "swiss",
+//       "tsz": 4,
+//       "rnd": 3,
+//       "pto": 60
+//     }
+//   }
+const TypeCreateTournament = "tcr"
+
+// CreateTournament defines the needed parameters for a create tournament message.
+type CreateTournament struct {
+	Name          string        `json:"nam"`
+	DriverName    string        `json:"drv"`
+	Format        string        `json:"fmt"`
+	TableSize     int           `json:"tsz"`
+	Rounds        int           `json:"rnd"`
+	Advance       int           `json:"adv"`
+	PlayerTimeout time.Duration `json:"pto"`
+}
+
+// TypeJoinTournament defines the value that join tournament
+// messages must have in the Type field.
+//
+// Players already registered can join again a running tournament, for example
+// after reconnecting, so they are seated in the tables of the following rounds.
+//
+// A MessageTournament message is sent to all the tournament participants.
+//
+// The following is a JoinTournament message example:
+//   {
+//     "typ": "tjn",
+//     "cnt": {
+//       "tid": "VWXYZ"
+//     }
+//   }
+const TypeJoinTournament = "tjn"
+
+// TypeStartTournament defines the value that start tournament
+// messages must have in the Type field.
+//
+// Can only be issued by the tournament organizer.
+//
+// A MessageTournament message is sent to all the tournament participants
+// with the tables of the first round.
+//
+// The following is a StartTournament message example:
+//   {
+//     "typ": "tst",
+//     "cnt": {
+//       "tid": "VWXYZ"
+//     }
+//   }
+const TypeStartTournament = "tst"
+
+// TypeTournamentInfo defines the value that tournament info
+// messages must have in the Type field.
+//
+// A MessageTournament message is sent back to the client.
+//
+// The following is a TournamentInfo message example:
+//   {
+//     "typ": "tin",
+//     "cnt": {
+//       "tid": "VWXYZ"
+//     }
+//   }
+const TypeTournamentInfo = "tin"
+
+// TournamentRequest defines the needed parameters for join tournament,
+// start tournament and tournament info messages.
+type TournamentRequest struct {
+	ID string `json:"tid"`
+}
@@ -18,6 +18,11 @@ import (
 //     "typ": "ini",
 //     "cnt": {
 //       "pto": 15,
+//       "dln": 48, // Optional, turn deadline in hours for asynchronous rooms
+//       "vot": { // Optional, voting rules of the room's proposals
+//         "maj": "sim",
+//         "tmo": 60
+//       },
 //       "gpa": {
 //         ···
 //       }
@@ -28,10 +33,32 @@ const TypeStartGame = "ini"
 // StartGame defines the needed parameters for a start game
 // message.
 type StartGame struct {
-	PlayerTimeout  time.Duration   `json:"pto"`
+	PlayerTimeout time.Duration `json:"pto"`
+	// TurnDeadline is the time in hours each player has per turn in asynchronous rooms,
+	// replacing PlayerTimeout
+	TurnDeadline   time.Duration   `json:"dln"`
+	Voting         VotingRules     `json:"vot"`
 	GameParameters json.RawMessage `json:"gpa"`
 }
 
+// VotingRules is a struct used inside StartGame messages
+// which defines how the proposals of players are voted
+type VotingRules struct {
+	// Majority is the share of players who have to accept a proposal,
+	// MajorityUnanimous if empty
+	Majority string `json:"maj"`
+	// Timeout is the time in seconds players have to vote,
+	// the server's default if 0
+	Timeout time.Duration `json:"tmo"`
+}
+
+// Voting majorities
+const (
+	MajorityUnanimous = "una"
+	MajoritySimple    = "sim"
+	MajorityTwoThirds = "tth"
+)
+
 // TypeAddBot defines the value that add bot
 // messages must have in the Type field.
 //
@@ -76,6 +103,29 @@ type KickPlayer struct {
 	PlayerNumber int `json:"ply"`
 }
 
+// TypeBanPlayer defines the value that ban player
+// messages must have in the Type field.
+//
+// Can only be issued by the room's owner
+//
+// The player is kicked, and his/her user, session and network address can't join
+// the room again. A ClientOut message with reason ReasonPlayerBanned is sent to him/her.
+//
+// The following is a BanPlayer message example:
+//   {
+//     "typ": "ban",
+//     "cnt": {
+//       "ply": 2
+//     }
+//   }
+const TypeBanPlayer = "ban"
+
+// BanPlayer defines the needed parameters for a ban player
+// message.
+type BanPlayer struct {
+	PlayerNumber int `json:"ply"`
+}
+
 // TypePlayerQuits defines the value that quit room
 // messages must have in the Type field.
 //
@@ -105,3 +155,194 @@ const TypeSetClientData = "scd"
 type SetClientDataParams struct {
 	Name string `json:"nam"`
 }
+
+// TypeRematch defines the value that rematch
+// messages must have in the Type field.
+//
+// Can only be issued by the room's owner, and only once the current game is over.
+// The room goes back to the lobby state with the same seated players,
+// ready to start a new game.
+//
+// A MessageCurrentPlayers is sent to all clients in the room with the new seat order.
+// If seats have been rotated, a MessageJoinedRoom is also sent to every human client
+// in the room with his/her new player number.
+//
+// The following is a Rematch message example:
+//   {
+//     "typ": "rem",
+//     "cnt": {
+//       "rot": true // Optional, rotate seat order
+//     }
+//   }
+const TypeRematch = "rem"
+
+// Rematch defines the needed parameters for a rematch
+// message.
+type Rematch struct {
+	RotateSeats bool `json:"rot"`
+}
+
+// TypeStatusAck defines the value that status acknowledgement
+// messages must have in the Type field.
+//
+// Sent by clients to acknowledge the last game status they have, so the next updates
+// are sent as StatusPatch messages against it, if delta updates are enabled in the server.
+// Clients must keep the status they acknowledged until they acknowledge a newer one.
+//
+// The following is a StatusAck message example:
+//   {
+//     "typ": "sak",
+//     "cnt": {
+//       "seq": 12
+//     }
+//   }
+const TypeStatusAck = "sak"
+
+// StatusAck defines the needed parameters for a status acknowledgement
+// message.
+type StatusAck struct {
+	SequenceNumber int `json:"seq"`
+}
+
+// TypeResync defines the value that resync
+// messages must have in the Type field.
+//
+// Sent by clients who lost track of the game status, for example because
+// a patch couldn't be applied. The whole current status is sent back in an
+// update game status message.
+//
+// The following is a Resync message example:
+//   {
+//     "typ": "rsy",
+//     "cnt": {}
+//   }
+const TypeResync = "rsy"
+
+// TypePropose defines the value that propose
+// messages must have in the Type field.
+//
+// Sent by players to put a proposal to the vote of the human players in the room
+// while a game is running. Kind can be ProposalAbort, ProposalKick along with the number
+// of the player to be kicked, ProposalDraw, ProposalPause, ProposalResume or ProposalUndo,
+// which can only be proposed by the author of the last action and needs all the other
+// human players to accept it, as an UndoRequest message. Undoing fails with
+// an undo_unsupported error if the game driver can't undo actions and its games
+// can't be replayed, as happens with games depending on chance.
+//
+// The proposer votes in favour, and the proposal is carried out as soon as enough
+// players accept it, as set by the voting rules of the StartGame message. Proposals not
+// resolved before the voting timeout expire.
+// Only one proposal can be pending at a time, and a VoteStatus message is sent
+// to all players every time it changes.
+//
+// The following is a Propose message example:
+//   {
+//     "typ": "prp",
+//     "cnt": {
+//       "knd": "kck",
+//       "ply": 2 // Only for kick proposals
+//     }
+//   }
+const TypePropose = "prp"
+
+// Proposal kinds
+const (
+	ProposalAbort  = "abt"
+	ProposalKick   = "kck"
+	ProposalDraw   = "drw"
+	ProposalPause  = "pau"
+	ProposalResume = "res"
+	ProposalUndo   = "und"
+)
+
+// Propose defines the needed parameters for a propose
+// message.
+type Propose struct {
+	Kind         string `json:"knd"`
+	PlayerNumber int    `json:"ply"`
+}
+
+// TypeVote defines the value that vote
+// messages must have in the Type field.
+//
+// Sent by players to accept or reject the pending proposal. Votes can't be changed.
+//
+// The following is a Vote message example:
+//   {
+//     "typ": "vot",
+//     "cnt": {
+//       "acc": true
+//     }
+//   }
+const TypeVote = "vot"
+
+// Vote defines the needed parameters for a vote
+// message.
+type Vote struct {
+	Accept bool `json:"acc"`
+}
+
+// TypeUndoRequest defines the value that undo request
+// messages must have in the Type field.
+//
+// Sent by the author of the last game action to undo it, the same as proposing
+// ProposalUndo. The other human players are asked to vote with an UndoRequested message,
+// and the action is undone only if all of them accept it, whatever the voting rules
+// of the room are. An UndoResult message is sent to all players once the request
+// is resolved. Any action played meanwhile cancels it.
+//
+// The following is an UndoRequest message example:
+//   {
+//     "typ": "und",
+//     "cnt": {}
+//   }
+const TypeUndoRequest = "und"
+
+// TypeUndoVote defines the value that undo vote
+// messages must have in the Type field.
+//
+// Sent by players to accept or reject a pending undo request, the same as
+// a Vote message.
+//
+// The following is an UndoVote message example:
+//   {
+//     "typ": "udv",
+//     "cnt": {
+//       "acc": true
+//     }
+//   }
+const TypeUndoVote = "udv"
+
+// UndoVote defines the needed parameters for an undo vote
+// message.
+type UndoVote struct {
+	Accept bool `json:"acc"`
+}
+
+// TypeVoteExpired defines the value that vote expired
+// messages must have in the Type field.
+//
+// Sent by rooms to themselves through the hub when the voting time of the pending
+// proposal is up, so it is resolved from the hub's goroutine like any other message.
+// It is ignored if received before the voting deadline.
+//
+// The following is a VoteExpired message example:
+//   {
+//     "typ": "vex",
+//     "cnt": {}
+//   }
+const TypeVoteExpired = "vex"
+
+// TypeBotStuck defines the value that bot stuck
+// messages must have in the Type field.
+//
+// Sent by bots to their room through the hub when their AI has no action to play
+// in their turn. As bots have no turn timer, the room takes the bot out of the game
+// instead of waiting for it forever. It is ignored if the bot is not in turn.
+//
+// The following is a BotStuck message example:
+//   {
+//     "typ": "bst",
+//     "cnt": {}
+//   }
+const TypeBotStuck = "bst"
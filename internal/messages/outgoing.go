@@ -3,6 +3,8 @@ package messages
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/svera/sackson-server/internal/jsonpatch"
 )
 
 // Control messages sent to the different players.
@@ -18,12 +20,44 @@ const (
 	ReasonPlayerTimedOut            = "ptm"
 	ReasonPlayerKicked              = "kck"
 	ReasonPlayerQuitted             = "qui"
+	ReasonTournamentTableFinished   = "tbl"
+	ReasonRoomDestroyedByAdmin      = "adm"
+	ReasonServerShutdown            = "shd"
+	ReasonRoomDestroyedGameAborted  = "abt"
+	ReasonPlayerBanned              = "ban"
 )
 
 // TypeUpdateGameStatus defines the value that update game status
 // messages must have in the Type field.
 const TypeUpdateGameStatus = "upd"
 
+// TypeStatusPatch defines the value that status patch
+// messages must have in the Type field.
+//
+// StatusPatch is sent instead of an update game status message to clients who acknowledged
+// a previous status, if delta updates are enabled in the server. Its operations follow
+// the JSON Patch format (RFC 6902) and must be applied to the status with the base
+// sequence number to get the status with the message's sequence number.
+// The following is a StatusPatch message example:
+//   {
+//     "typ": "pat",
+//     "seq": 13,
+//     "cnt": {
+//       "bas": 12,
+//       "ops": [
+//         {"op": "replace", "path": "/brd/5F", "value": "Luxor"}
+//       ]
+//     }
+//   }
+const TypeStatusPatch = "pat"
+
+// StatusPatch defines the needed parameters for a status patch
+// message.
+type StatusPatch struct {
+	Base       int             `json:"bas"`
+	Operations jsonpatch.Patch `json:"ops"`
+}
+
 // TypeClientOut defines the value that client out
 // messages must have in the Type field.
 //
@@ -43,6 +77,46 @@ type ClientOut struct {
 	Reason string `json:"rea"`
 }
 
+// TypeSession defines the value that session
+// messages must have in the Type field.
+//
+// Session is sent to a client when he/she connects to the hub. Its identifier
+// can be passed in the "s" parameter of the connection URL to get back to
+// asynchronous rooms after disconnecting.
+// The following is a Session message example:
+//   {
+//     "typ": "ses",
+//     "cnt": {
+//       "ses": "9b1a2f0c6d3e4a5b8c7d6e5f4a3b2c1d"
+//     }
+//   }
+const TypeSession = "ses"
+
+// Session defines the needed parameters for a session
+// message.
+type Session struct {
+	ID string `json:"ses"`
+}
+
+// TypeNotice defines the value that notice
+// messages must have in the Type field.
+//
+// Notice is sent by the server operators to a client, or to all of them.
+// The following is a Notice message example:
+//   {
+//     "typ": "ntc",
+//     "cnt": {
+//       "msg": "The server will be restarted in 5 minutes"
+//     }
+//   }
+const TypeNotice = "ntc"
+
+// Notice defines the needed parameters for a notice
+// message.
+type Notice struct {
+	Message string `json:"msg"`
+}
+
 // TypeRoomsList defines the value that rooms list
 // messages must have in the Type field.
 //
@@ -97,11 +171,28 @@ type PlayerData struct {
 //
 // Error is a message sent to a specific player
 // when he/she does an action that leads to an error.
+// If the error was caused by a message, its type and request identifier,
+// if the client set one, are included. Some errors have details, like the rule
+// broken by invalid names.
 // The following is a Error message example:
 //   {
 //     "typ": "err",
 //     "cnt": {
-//       "des": "Whatever"
+//       "des": "not_your_turn",
+//       "typ": "ply",
+//       "rid": "42"
+//     }
+//   }
+//
+//   {
+//     "typ": "err",
+//     "cnt": {
+//       "des": "invalid_name",
+//       "typ": "scd",
+//       "dtl": {
+//         "rul": "too_long",
+//         "max": 24
+//       }
 //     }
 //   }
 const TypeError = "err"
@@ -109,7 +200,10 @@ const TypeError = "err"
 // Error defines the needed parameters for an error
 // message.
 type Error struct {
-	Description string `json:"des"`
+	Description string      `json:"des"`
+	Type        string      `json:"typ,omitempty"`
+	RequestID   string      `json:"rid,omitempty"`
+	Details     interface{} `json:"dtl,omitempty"`
 }
 
 // TypeJoinedRoom defines the value that joined room
@@ -160,3 +254,290 @@ type GameStarted struct {
 	PlayerTimeOut  time.Duration   `json:"pto"`
 	GameParameters json.RawMessage `json:"gpa"`
 }
+
+// TypeLegalActions defines the value that legal actions
+// messages must have in the Type field.
+//
+// LegalActions is sent to each player in turn after every game status update,
+// if the game driver supports it, with the actions he/she can do. Each action
+// has the type and content of the message which would do it. Players not in
+// turn have no legal actions.
+// The following is a LegalActions message example:
+//   {
+//     "typ": "lga",
+//     "cnt": {
+//       "seq": 12,
+//       "act": [
+//         {"typ": "ply", "cnt": {"til": "5E"}},
+//         {"typ": "ply", "cnt": {"til": "7A"}}
+//       ]
+//     }
+//   }
+const TypeLegalActions = "lga"
+
+// LegalActions defines the needed parameters for a legal actions
+// message. SequenceNumber is the one of the game status update the actions are for.
+type LegalActions struct {
+	SequenceNumber int           `json:"seq"`
+	Actions        []LegalAction `json:"act"`
+}
+
+// LegalAction is a struct used inside LegalActions with the type
+// and content of an action message
+type LegalAction struct {
+	Type   string          `json:"typ"`
+	Params json.RawMessage `json:"cnt,omitempty"`
+}
+
+// TypeUndoRequested defines the value that undo requested
+// messages must have in the Type field.
+//
+// UndoRequested is sent to the players who have to vote an undo request,
+// with the number and name of the player who asked for it.
+// The following is an UndoRequested message example:
+//   {
+//     "typ": "udq",
+//     "cnt": {
+//       "ply": 1,
+//       "nam": "Sergio"
+//     }
+//   }
+const TypeUndoRequested = "udq"
+
+// UndoRequested defines the needed parameters for an undo requested
+// message.
+type UndoRequested struct {
+	Number int    `json:"ply"`
+	Name   string `json:"nam"`
+}
+
+// TypeUndoResult defines the value that undo result
+// messages must have in the Type field.
+//
+// UndoResult is sent to all players once an undo request is resolved.
+// If it was accepted, the game status before the undone action is sent right after.
+// The following is an UndoResult message example:
+//   {
+//     "typ": "udr",
+//     "cnt": {
+//       "acc": true
+//     }
+//   }
+const TypeUndoResult = "udr"
+
+// UndoResult defines the needed parameters for an undo result
+// message.
+type UndoResult struct {
+	Accepted bool `json:"acc"`
+}
+
+// TypeVoteStatus defines the value that vote status
+// messages must have in the Type field.
+//
+// VoteStatus is sent to all players when a proposal is made, voted or resolved.
+// Player is the number of the player to be kicked in kick proposals (-1 otherwise), and Votes holds
+// the votes cast so far by player number. Timeout is the time in seconds left to vote
+// while the proposal is open, if it has a timeout.
+// State is one of VoteOpen, VoteAccepted, VoteRejected, VoteExpired, VoteCancelled
+// or VoteFailed, the latter if an accepted proposal couldn't be carried out.
+// The following is a VoteStatus message example:
+//   {
+//     "typ": "vst",
+//     "cnt": {
+//       "knd": "kck",
+//       "ply": 2,
+//       "prp": 0,
+//       "vts": {
+//         "0": true,
+//         "1": false
+//       },
+//       "vtr": 3,
+//       "req": 2,
+//       "tmo": 57,
+//       "sta": "opn"
+//     }
+//   }
+const TypeVoteStatus = "vst"
+
+// Vote states
+const (
+	VoteOpen      = "opn"
+	VoteAccepted  = "acc"
+	VoteRejected  = "rej"
+	VoteExpired   = "exp"
+	VoteCancelled = "can"
+	VoteFailed    = "fld"
+)
+
+// VoteStatus defines the needed parameters for a vote status
+// message.
+type VoteStatus struct {
+	Kind     string          `json:"knd"`
+	Player   int             `json:"ply"`
+	Proposer int             `json:"prp"`
+	Votes    map[string]bool `json:"vts"`
+	Voters   int             `json:"vtr"`
+	Required int             `json:"req"`
+	Timeout  time.Duration   `json:"tmo,omitempty"`
+	State    string          `json:"sta"`
+}
+
+// TypeGameOver defines the value that game over
+// messages must have in the Type field.
+//
+// GameOver is a message sent to all players in a room
+// when its game ends, either because it reached its end or because
+// there are not enough players to continue playing.
+// Rank and score are only present if the game driver reports final standings,
+// otherwise they will be 0.
+// The following is a GameOver message example:
+//   {
+//     "typ": "gov",
+//     "cnt": {
+//       "res": [
+//         {"num": 1, "nam": "Sergio", "bot": false, "rnk": 1, "sco": 12300},
+//         {"num": 0, "nam": "Bot 0", "bot": true, "rnk": 2, "sco": 9800}
+//       ]
+//     }
+//   }
+const TypeGameOver = "gov"
+
+// GameOver defines the needed parameters for a game over
+// message.
+type GameOver struct {
+	Results []PlayerResult `json:"res"`
+}
+
+// PlayerResult is a struct used inside MessageGameOver with the final
+// result of a specific player
+type PlayerResult struct {
+	Number int    `json:"num"`
+	Name   string `json:"nam"`
+	Bot    bool   `json:"bot"`
+	// BotLevel is only present for bots
+	BotLevel string `json:"lvl,omitempty"`
+	Rank     int    `json:"rnk"`
+	Score    int    `json:"sco"`
+	// UserID and SessionID identify players in the server's records, they are not sent to clients
+	UserID    string `json:"-"`
+	SessionID string `json:"-"`
+}
+
+// TypeLeaderboard defines the value that leaderboard
+// messages must have in the Type field.
+//
+// Leaderboard is a message sent to a specific client
+// when he/she requests a leaderboard, with the best rated players first.
+// The following is a Leaderboard message example:
+//   {
+//     "typ": "ldb",
+//     "cnt": {
+//       "drv": "acquire",
+//       "bot": false,
+//       "val": [
+//         {"nam": "Sergio", "rat": 1580, "gam": 12},
+//         {"nam": "Miguel", "rat": 1493, "gam": 3}
+//       ]
+//     }
+//   }
+const TypeLeaderboard = "ldb"
+
+// Leaderboard defines the needed parameters for a leaderboard
+// message.
+type Leaderboard struct {
+	DriverName string             `json:"drv"`
+	Bots       bool               `json:"bot"`
+	Values     []LeaderboardEntry `json:"val"`
+}
+
+// LeaderboardEntry is a struct used inside MessageLeaderboard with the rating
+// of a specific player
+type LeaderboardEntry struct {
+	Name   string `json:"nam"`
+	Rating int    `json:"rat"`
+	Games  int    `json:"gam"`
+}
+
+// TypeMatchmakingStatus defines the value that matchmaking status
+// messages must have in the Type field.
+//
+// MatchmakingStatus is a message sent to a specific client
+// when he/she joins or leaves the matchmaking queue.
+// The following is a MatchmakingStatus message example:
+//   {
+//     "typ": "mms",
+//     "cnt": {
+//       "que": true,
+//       "drv": "acquire",
+//       "ply": 4
+//     }
+//   }
+const TypeMatchmakingStatus = "mms"
+
+// MatchmakingStatus defines the needed parameters for a matchmaking status
+// message.
+type MatchmakingStatus struct {
+	Queued     bool   `json:"que"`
+	DriverName string `json:"drv,omitempty"`
+	Players    int    `json:"ply,omitempty"`
+}
+
+// TypeTournament defines the value that tournament
+// messages must have in the Type field.
+//
+// Tournament is a message sent to the participants of a tournament
+// every time it changes, with its standings and the tables of the current round.
+// The following is a Tournament message example:
+//   {
+//     "typ": "trn",
+//     "cnt": {
+//       "id": "VWXYZ",
+//       "nam": "Monthly Acquire",
+//       "drv": "acquire",
+//       "fmt": "swiss",
+//       "sta": "running", // Can be "registering", "running" or "finished"
+//       "rnd": 1,
+//       "std": [
+//         {"nam": "Sergio", "pts": 3, "sco": 12300, "gam": 1, "eli": false},
+//         {"nam": "Miguel", "pts": 2, "sco": 9800, "gam": 1, "eli": false}
+//       ],
+//       "tbl": [
+//         {"num": 1, "pls": ["Sergio", "Miguel"], "rom": "ABCDE", "don": false}
+//       ],
+//       "win": "" // Name of the winner, once the tournament is finished
+//     }
+//   }
+const TypeTournament = "trn"
+
+// Tournament defines the needed parameters for a tournament
+// message.
+type Tournament struct {
+	ID         string               `json:"id"`
+	Name       string               `json:"nam"`
+	DriverName string               `json:"drv"`
+	Format     string               `json:"fmt"`
+	State      string               `json:"sta"`
+	Round      int                  `json:"rnd"`
+	Standings  []TournamentStanding `json:"std"`
+	Tables     []TournamentTable    `json:"tbl"`
+	Winner     string               `json:"win"`
+}
+
+// TournamentStanding is a struct used inside MessageTournament with the accumulated
+// results of a specific player
+type TournamentStanding struct {
+	Name       string  `json:"nam"`
+	Points     float64 `json:"pts"`
+	Score      int     `json:"sco"`
+	Games      int     `json:"gam"`
+	Eliminated bool    `json:"eli"`
+}
+
+// TournamentTable is a struct used inside MessageTournament with the data
+// of a table of the current round
+type TournamentTable struct {
+	Number  int      `json:"num"`
+	Players []string `json:"pls"`
+	Room    string   `json:"rom"`
+	Done    bool     `json:"don"`
+}
@@ -0,0 +1,187 @@
+package messages
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Protocol versions supported by the server.
+// Clients which don't declare the version they use are considered to use the first one.
+const (
+	ProtocolV1 = 1
+	// In version 2, current players messages list the players ordered by their number
+	ProtocolV2 = 2
+)
+
+// LatestProtocolVersion is the most recent protocol version supported by the server
+const LatestProtocolVersion = ProtocolV2
+
+// SupportedProtocolVersions holds all protocol versions supported by the server
+var SupportedProtocolVersions = []int{ProtocolV1, ProtocolV2}
+
+// IsSupportedProtocolVersion returns true if the passed protocol version is supported by the server
+func IsSupportedProtocolVersion(version int) bool {
+	for _, v := range SupportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeHello defines the value that hello
+// messages must have in the Type field.
+//
+// Hello is sent by clients to declare the protocol version they use,
+// which can also be declared with the "v" query parameter when connecting.
+// A Protocol message is sent back to the client.
+// The following is a Hello message example:
+//   {
+//     "typ": "hel",
+//     "cnt": {
+//       "ver": 2
+//     }
+//   }
+const TypeHello = "hel"
+
+// Hello defines the needed parameters for a hello
+// message.
+type Hello struct {
+	Version int `json:"ver"`
+}
+
+// TypeProtocol defines the value that protocol
+// messages must have in the Type field.
+//
+// Protocol is sent to a client when he/she connects or says hello, with the protocol
+// version used to talk with him/her, the versions supported by the server and
+// the optional features enabled in it.
+// The following is a Protocol message example:
+//   {
+//     "typ": "prt",
+//     "cnt": {
+//       "ver": 2,
+//       "sup": [1, 2],
+//       "cap": ["async", "matchmaking", "tournaments"]
+//     }
+//   }
+const TypeProtocol = "prt"
+
+// Protocol defines the needed parameters for a protocol
+// message.
+type Protocol struct {
+	Version      int      `json:"ver"`
+	Supported    []int    `json:"sup"`
+	Capabilities []string `json:"cap"`
+}
+
+// CurrentPlayersV2 is the version 2 of the current players message.
+// The following is a CurrentPlayersV2 message example:
+//   {
+//     "typ": "pls",
+//     "cnt": {
+//       "val": [
+//         {"num": 0, "nam": "Miguel"},
+//         {"num": 1, "nam": "Sergio"}
+//       ]
+//     }
+//   }
+type CurrentPlayersV2 struct {
+	Values []PlayerDataV2 `json:"val"`
+}
+
+// PlayerDataV2 is a struct used inside CurrentPlayersV2 with data of a specific
+// user
+type PlayerDataV2 struct {
+	Number int    `json:"num"`
+	Name   string `json:"nam"`
+}
+
+// Adapt returns the passed message in the format of the passed protocol version
+func Adapt(message interface{}, typeName string, version int) interface{} {
+	if version < ProtocolV2 {
+		return message
+	}
+
+	switch typeName {
+	case TypeCurrentPlayers:
+		if m, ok := message.(CurrentPlayers); ok {
+			return currentPlayersV2(m)
+		}
+	}
+	return message
+}
+
+func currentPlayersV2(m CurrentPlayers) CurrentPlayersV2 {
+	adapted := CurrentPlayersV2{Values: []PlayerDataV2{}}
+	for key, data := range m.Values {
+		number, _ := strconv.Atoi(key)
+		adapted.Values = append(adapted.Values, PlayerDataV2{Number: number, Name: data.Name})
+	}
+	sort.Slice(adapted.Values, func(i, j int) bool {
+		return adapted.Values[i].Number < adapted.Values[j].Number
+	})
+	return adapted
+}
+
+// All messages sent to a client carry a "csq" field with their client sequence number,
+// which increases by one with every message sent in the same session. The last messages
+// are kept by the server, so a client which loses its connection can reconnect
+// passing its session identifier and ask for the ones it missed with a Resume message.
+// Errors sent before a client is registered or about rate limits are not numbered.
+
+// Clients can add a "rid" field to their messages with a request identifier of
+// up to 64 characters. It is sent back in the "rid" field of the direct responses
+// to the message: errors, joined room, client out and protocol messages, as well as
+// the game status sent to the author of a game action.
+// The following is a JoinRoom message with a request identifier example:
+//   {
+//     "typ": "joi",
+//     "rid": "7",
+//     "cnt": {
+//       "rom": "VWXYZ"
+//     }
+//   }
+
+// TypeAck defines the value that acknowledgement
+// messages must have in the Type field.
+//
+// Sent by clients to let the server know they have received all messages
+// up to the one with the passed client sequence number, which won't be sent
+// again in case of resuming the session.
+// The following is an Ack message example:
+//   {
+//     "typ": "ack",
+//     "cnt": {
+//       "csq": 25
+//     }
+//   }
+const TypeAck = "ack"
+
+// Ack defines the needed parameters for an acknowledgement
+// message.
+type Ack struct {
+	ClientSequence int `json:"csq"`
+}
+
+// TypeResume defines the value that resume
+// messages must have in the Type field.
+//
+// Sent by clients after reconnecting with their previous session identifier,
+// with the client sequence number of the last message they received.
+// All messages sent after that one are sent again, in order. If some of them
+// are not kept by the server anymore an error is returned and none are sent.
+// The following is a Resume message example:
+//   {
+//     "typ": "rsm",
+//     "cnt": {
+//       "lst": 25
+//     }
+//   }
+const TypeResume = "rsm"
+
+// Resume defines the needed parameters for a resume
+// message.
+type Resume struct {
+	Last int `json:"lst"`
+}
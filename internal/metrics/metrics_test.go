@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteCountersAndGauges(t *testing.T) {
+	var out bytes.Buffer
+	reg := NewRegistry()
+	sent := reg.NewCounter("messages_total", "Messages sent.", "type")
+	clients := reg.NewGauge("clients", "Connected clients.")
+	reg.NewGaugeFunc("rooms", "Rooms by state.", "state", func() map[string]float64 {
+		return map[string]float64{"waiting": 2}
+	})
+
+	sent.Inc("upd")
+	sent.Add(2, "upd")
+	sent.Inc(`a"b`)
+	clients.Inc()
+	clients.Inc()
+	clients.Dec()
+	reg.Write(&out)
+
+	expected := `# HELP messages_total Messages sent.
+# TYPE messages_total counter
+messages_total{type="a\"b"} 1
+messages_total{type="upd"} 3
+# HELP clients Connected clients.
+# TYPE clients gauge
+clients 1
+# HELP rooms Rooms by state.
+# TYPE rooms gauge
+rooms{state="waiting"} 2
+`
+	if out.String() != expected {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	var out bytes.Buffer
+	reg := NewRegistry()
+	h := reg.NewHistogram("execute_seconds", "Execute latency.", []float64{0.1, 1}, "driver")
+
+	h.Observe(0.05, "acquire")
+	h.Observe(0.5, "acquire")
+	h.Observe(2, "acquire")
+	reg.Write(&out)
+
+	for _, line := range []string{
+		`execute_seconds_bucket{driver="acquire",le="0.1"} 1`,
+		`execute_seconds_bucket{driver="acquire",le="1"} 2`,
+		`execute_seconds_bucket{driver="acquire",le="+Inf"} 3`,
+		`execute_seconds_sum{driver="acquire"} 2.55`,
+		`execute_seconds_count{driver="acquire"} 3`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Histogram output must contain '%s', got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestSeriesAreCapped(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("messages_total", "Messages sent.", "type")
+
+	for i := 0; i < MaxSeries+10; i++ {
+		c.Inc(strconv.Itoa(i))
+	}
+
+	if len(c.values) != MaxSeries+1 || c.values["other"] != 10 {
+		t.Errorf("Series beyond the limit must be added up in the 'other' series, got %d series and %f in 'other'", len(c.values), c.values["other"])
+	}
+}
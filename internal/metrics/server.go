@@ -0,0 +1,53 @@
+package metrics
+
+// Default is the registry holding the server metrics
+var Default = NewRegistry()
+
+// Server metrics, updated from the different parts of the server
+var (
+	MessagesReceived = Default.NewCounter(
+		"sackson_messages_received_total",
+		"Number of messages received from clients, by message type.",
+		"type",
+	)
+	MessagesSent = Default.NewCounter(
+		"sackson_messages_sent_total",
+		"Number of messages sent to clients, by message type.",
+		"type",
+	)
+	ClientsDropped = Default.NewCounter(
+		"sackson_clients_dropped_total",
+		"Number of clients disconnected because they couldn't be sent a message.",
+	)
+	MessagesRateLimited = Default.NewCounter(
+		"sackson_messages_rate_limited_total",
+		"Number of messages from clients rejected for exceeding the rate limits, by message type.",
+		"type",
+	)
+	ClientsRateLimited = Default.NewCounter(
+		"sackson_clients_rate_limited_total",
+		"Number of clients disconnected for persistently exceeding the rate limits.",
+	)
+	RoomPanics = Default.NewCounter(
+		"sackson_room_panics_total",
+		"Number of panics recovered while parsing room messages, by game driver.",
+		"driver",
+	)
+	RoomsDestroyed = Default.NewCounter(
+		"sackson_rooms_destroyed_total",
+		"Number of destroyed rooms, by reason.",
+		"reason",
+	)
+	DriverExecuteDuration = Default.NewHistogram(
+		"sackson_driver_execute_seconds",
+		"Time spent by game drivers executing actions, by game driver.",
+		DefaultBuckets,
+		"driver",
+	)
+	BotPlayDuration = Default.NewHistogram(
+		"sackson_bot_play_seconds",
+		"Time spent by bots choosing their actions, by game driver.",
+		DefaultBuckets,
+		"driver",
+	)
+)
@@ -0,0 +1,149 @@
+// Package names checks the names chosen by players against the server's name policy,
+// and makes them unique among the players of a room.
+package names
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/svera/sackson-server/internal/config"
+)
+
+// InvalidName is the code of the errors returned for names which break the policy
+const InvalidName = "invalid_name"
+
+// Rules broken by invalid names
+const (
+	RuleTooShort   = "too_short"
+	RuleTooLong    = "too_long"
+	RuleCharacters = "characters"
+	RuleReserved   = "reserved"
+	RuleFiltered   = "filtered"
+)
+
+// Error is returned for names which break the policy, holding the rule broken
+type Error struct {
+	Rule string `json:"rul"`
+	// Min and Max are the length limits, for length rules
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+	// Prefix is the reserved prefix used, for the reserved rule
+	Prefix string `json:"pfx,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return InvalidName
+}
+
+// Details returns the error itself, sent to clients along with its code
+func (e *Error) Details() interface{} {
+	return e
+}
+
+// Filter decides whether names are acceptable, e.g. because of their language
+type Filter interface {
+	Allowed(name string) bool
+}
+
+// WordFilter is a Filter which rejects names containing any of its words, ignoring case
+type WordFilter []string
+
+// Allowed returns false if the name contains any of the filter's words
+func (f WordFilter) Allowed(name string) bool {
+	lower := strings.ToLower(name)
+	for _, w := range f {
+		if w != "" && strings.Contains(lower, strings.ToLower(w)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy defines the names players can use
+type Policy struct {
+	MinLength        int
+	MaxLength        int
+	ReservedPrefixes []string
+	// Filter is an additional check names have to pass, leave nil for none
+	Filter  Filter
+	pattern *regexp.Regexp
+}
+
+// NewPolicy returns the policy set in the passed configuration,
+// filtering names with a WordFilter of its blocked words
+func NewPolicy(cfg config.NamesConfig) *Policy {
+	p := &Policy{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		ReservedPrefixes: cfg.ReservedPrefixes,
+		Filter:           WordFilter(cfg.BlockedWords),
+	}
+	if cfg.Pattern != "" {
+		// Patterns are already validated when loading the configuration
+		p.pattern, _ = regexp.Compile(cfg.Pattern)
+	}
+	return p
+}
+
+// Check returns the passed name without leading and trailing spaces,
+// or an *Error if it breaks the policy. Names can never be empty.
+func (p *Policy) Check(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	length := utf8.RuneCountInString(name)
+
+	min := p.MinLength
+	if min < 1 {
+		min = 1
+	}
+	if length < min {
+		return name, &Error{Rule: RuleTooShort, Min: min}
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return name, &Error{Rule: RuleTooLong, Max: p.MaxLength}
+	}
+	if p.pattern != nil && !p.pattern.MatchString(name) {
+		return name, &Error{Rule: RuleCharacters}
+	}
+	lower := strings.ToLower(name)
+	for _, prefix := range p.ReservedPrefixes {
+		if prefix != "" && strings.HasPrefix(lower, strings.ToLower(prefix)) {
+			return name, &Error{Rule: RuleReserved, Prefix: prefix}
+		}
+	}
+	if p.Filter != nil && !p.Filter.Allowed(name) {
+		return name, &Error{Rule: RuleFiltered}
+	}
+	return name, nil
+}
+
+// Unique returns the passed name if none of the taken ones is the same ignoring case,
+// or the name followed by the lowest number which makes it unique otherwise,
+// shortening it if needed so it doesn't exceed the maximum length. At least one character
+// of the name is kept, so the result is only longer than the maximum if this is too short
+// to hold a character and the number.
+func (p *Policy) Unique(name string, taken []string) string {
+	used := make(map[string]bool, len(taken))
+	for _, t := range taken {
+		used[strings.ToLower(t)] = true
+	}
+	if !used[strings.ToLower(name)] {
+		return name
+	}
+	for n := 2; ; n++ {
+		suffix := " " + strconv.Itoa(n)
+		base := []rune(name)
+		if p.MaxLength > 0 && len(base)+len(suffix) > p.MaxLength {
+			keep := p.MaxLength - len(suffix)
+			if keep < 1 {
+				keep = 1
+			}
+			base = base[:keep]
+		}
+		candidate := string(base) + suffix
+		if !used[strings.ToLower(candidate)] {
+			return candidate
+		}
+	}
+}
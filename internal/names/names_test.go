@@ -0,0 +1,52 @@
+package names
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/svera/sackson-server/internal/config"
+)
+
+func TestCheck(t *testing.T) {
+	p := NewPolicy(config.NamesConfig{
+		MaxLength:        10,
+		Pattern:          `^[\p{L}\p{N} _.-]+$`,
+		ReservedPrefixes: []string{"Bot"},
+		BlockedWords:     []string{"admin"},
+	})
+
+	if name, err := p.Check("  Sergio "); err != nil || name != "Sergio" {
+		t.Errorf("Valid names must be accepted without surrounding spaces, got '%s' %v", name, err)
+	}
+	invalid := map[string]string{
+		"   ":            RuleTooShort,
+		"Sergio Miguel!": RuleTooLong,
+		"<Sergio>":       RuleCharacters,
+		"bot 3":          RuleReserved,
+		"TheAdmin":       RuleFiltered,
+	}
+	for name, rule := range invalid {
+		_, err := p.Check(name)
+		if e, ok := err.(*Error); !ok || e.Rule != rule || e.Error() != InvalidName {
+			t.Errorf("Name '%s' must break rule '%s', got %v", name, rule, err)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	p := NewPolicy(config.NamesConfig{MaxLength: 6})
+
+	if name := p.Unique("Sergio", []string{"Miguel"}); name != "Sergio" {
+		t.Errorf("Names not taken must be kept, got '%s'", name)
+	}
+	if name := p.Unique("Sergio", []string{"sergio", "Serg 2"}); name != "Serg 3" {
+		t.Errorf("Taken names must be suffixed with the lowest free number within the maximum length, got '%s'", name)
+	}
+	taken := []string{"Sergio"}
+	for n := 2; n <= 10; n++ {
+		taken = append(taken, "S "+strconv.Itoa(n))
+	}
+	if name := NewPolicy(config.NamesConfig{MaxLength: 3}).Unique("Sergio", taken); name != "S 11" {
+		t.Errorf("Taken names must keep at least one character, got '%s'", name)
+	}
+}
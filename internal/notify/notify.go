@@ -0,0 +1,126 @@
+// Package notify lets players know that it is their turn in asynchronous rooms,
+// so they can get back to them while disconnected. Notifiers are pluggable,
+// this package providing one which writes to a log and another one which posts
+// notifications to a webhook.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/svera/sackson-server/internal/client"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
+)
+
+// Notification holds the data sent to a player when it is his/her turn.
+// Session IDs are never included, as they would let anyone receiving notifications
+// take the player's seat.
+type Notification struct {
+	RoomID string `json:"room_id"`
+	Driver string `json:"driver"`
+	Player string `json:"player"`
+	// UserID identifies authenticated players, omitted for anonymous ones
+	UserID string `json:"user_id,omitempty"`
+	// Online is false if the player is not connected to the room
+	Online bool `json:"online"`
+	// Deadline is the time when the player will be expelled if he/she doesn't play,
+	// omitted if there's no deadline
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// Notifier defines the methods a notification channel must implement
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// RegisterEvents sends a notification through the passed notifier
+// every time it becomes a player's turn in an asynchronous room.
+// Notifications are sent asynchronously, so slow notifiers don't block the room.
+func RegisterEvents(obs interfaces.Observer, n Notifier, log *logger.Logger) {
+	obs.On(events.TurnStarted{}, func(ev interface{}) {
+		if event, ok := ev.(events.TurnStarted); ok {
+			notification := NewNotification(event)
+			go func() {
+				if err := n.Notify(notification); err != nil {
+					log.Warn("Couldn't notify turn", "room", notification.RoomID, "driver", notification.Driver, "client", notification.Player, "error", err)
+				}
+			}()
+		}
+	})
+}
+
+// NewNotification returns the notification for the passed event
+func NewNotification(event events.TurnStarted) Notification {
+	_, offline := event.Client.(*client.Offline)
+	n := Notification{
+		RoomID: event.Room.ID(),
+		Driver: event.Room.GameDriverName(),
+		Player: event.Client.Name(),
+		UserID: event.Client.UserID(),
+		Online: !offline,
+	}
+	if !event.Deadline.IsZero() {
+		deadline := event.Deadline.UTC()
+		n.Deadline = &deadline
+	}
+	return n
+}
+
+// LogNotifier writes notifications to a log, which is useful for development
+type LogNotifier struct {
+	log *logger.Logger
+}
+
+// NewLogNotifier returns a new LogNotifier instance which writes to the passed logger
+func NewLogNotifier(log *logger.Logger) *LogNotifier {
+	return &LogNotifier{
+		log: log,
+	}
+}
+
+// Notify writes the notification to the log
+func (l *LogNotifier) Notify(n Notification) error {
+	deadline := "none"
+	if n.Deadline != nil {
+		deadline = n.Deadline.Format(time.RFC3339)
+	}
+	l.log.Info("Turn notification", "room", n.RoomID, "driver", n.Driver, "client", n.Player, "online", n.Online, "deadline", deadline)
+	return nil
+}
+
+// WebhookNotifier posts notifications as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a new WebhookNotifier instance which posts to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts the notification to the webhook, returning an error if it doesn't answer
+// with a successful status code
+func (w *WebhookNotifier) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/svera/sackson-server/internal/logger"
+)
+
+func TestWebhookNotifierPostsNotification(t *testing.T) {
+	received := make(chan Notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("Webhook must receive a JSON notification, got error %s", err.Error())
+		}
+		received <- n
+	}))
+	defer server.Close()
+
+	deadline := time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)
+	sent := Notification{RoomID: "VWXYZ", Driver: "acquire", Player: "Sergio", UserID: "abc", Deadline: &deadline}
+	if err := NewWebhookNotifier(server.URL).Notify(sent); err != nil {
+		t.Fatalf("Notify must not return an error, got %s", err.Error())
+	}
+
+	n := <-received
+	if n.RoomID != sent.RoomID || n.Player != sent.Player || n.UserID != sent.UserID || !n.Deadline.Equal(deadline) {
+		t.Errorf("Webhook must receive %+v, got %+v", sent, n)
+	}
+}
+
+func TestWebhookNotifierFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(server.URL).Notify(Notification{}); err == nil {
+		t.Errorf("Notify must return an error if the webhook doesn't answer with a successful status")
+	}
+}
+
+func TestLogNotifierWritesNotification(t *testing.T) {
+	var out bytes.Buffer
+
+	NewLogNotifier(logger.New(&out, logger.Options{})).Notify(Notification{RoomID: "VWXYZ", Driver: "acquire", Player: "Sergio"})
+	if !strings.Contains(out.String(), "room=VWXYZ driver=acquire client=Sergio") {
+		t.Errorf("Log must contain the notification, got '%s'", out.String())
+	}
+}
@@ -0,0 +1,113 @@
+// Package ratelimit implements token bucket limiters used to throttle
+// the messages sent by clients.
+package ratelimit
+
+import "time"
+
+// Rule defines the rate at which messages are allowed
+type Rule struct {
+	// Rate is the number of messages allowed per second
+	Rate float64
+	// Burst is the number of messages that can be sent at once
+	Burst int
+}
+
+// Rules defines the limits applied to the messages of a client
+type Rules struct {
+	// Default applies to all messages, no matter their type
+	Default Rule
+	// Types holds stricter rules for specific message types,
+	// applied besides the default one. As the default one,
+	// rules with a rate of 0 don't limit messages.
+	Types map[string]Rule
+	// MaxViolations is the number of consecutive rejected messages after which
+	// a client is considered an abuser (0 for never)
+	MaxViolations int
+}
+
+// Enabled returns true if the rules limit any message
+func (r Rules) Enabled() bool {
+	if r.Default.Rate > 0 {
+		return true
+	}
+	for _, rule := range r.Types {
+		if rule.Rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type bucket struct {
+	rule   Rule
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rule Rule, now time.Time) *bucket {
+	return &bucket{rule: rule, tokens: float64(rule.Burst), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rule.Rate
+	if b.tokens > float64(b.rule.Burst) {
+		b.tokens = float64(b.rule.Burst)
+	}
+	b.last = now
+}
+
+// Limiter throttles the messages of a single client. It is not safe for concurrent use.
+type Limiter struct {
+	rules      Rules
+	all        *bucket
+	types      map[string]*bucket
+	violations int
+	now        func() time.Time
+}
+
+// New returns a new Limiter instance applying the passed rules, using now to get the current time
+func New(rules Rules, now func() time.Time) *Limiter {
+	l := &Limiter{
+		rules: rules,
+		types: map[string]*bucket{},
+		now:   now,
+	}
+	if rules.Default.Rate > 0 {
+		l.all = newBucket(rules.Default, now())
+	}
+	return l
+}
+
+// Allow returns true if a message of the passed type can be processed now,
+// consuming a token from the buckets that apply to it
+func (l *Limiter) Allow(typ string) bool {
+	now := l.now()
+	buckets := []*bucket{}
+	if l.all != nil {
+		buckets = append(buckets, l.all)
+	}
+	if rule, ok := l.rules.Types[typ]; ok && rule.Rate > 0 {
+		if _, ok = l.types[typ]; !ok {
+			l.types[typ] = newBucket(rule, now)
+		}
+		buckets = append(buckets, l.types[typ])
+	}
+
+	for _, b := range buckets {
+		b.refill(now)
+		if b.tokens < 1 {
+			l.violations++
+			return false
+		}
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	l.violations = 0
+	return true
+}
+
+// Abusive returns true if the client had more consecutive messages rejected than allowed
+func (l *Limiter) Abusive() bool {
+	return l.rules.MaxViolations > 0 && l.violations >= l.rules.MaxViolations
+}
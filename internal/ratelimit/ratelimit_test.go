@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestBurstAndRefill(t *testing.T) {
+	clock := newFakeClock()
+	l := New(Rules{Default: Rule{Rate: 2, Burst: 3}}, clock.Now)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("pla") {
+			t.Fatalf("Message %d must be allowed within the burst", i+1)
+		}
+	}
+	if l.Allow("pla") {
+		t.Errorf("Messages over the burst must be rejected")
+	}
+	clock.Advance(time.Millisecond * 500)
+	if !l.Allow("pla") {
+		t.Errorf("A message must be allowed once a token is refilled")
+	}
+	if l.Allow("pla") {
+		t.Errorf("Messages must be rejected until tokens are refilled")
+	}
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !l.Allow("pla") {
+			t.Fatalf("Tokens must be refilled up to the burst")
+		}
+	}
+	if l.Allow("pla") {
+		t.Errorf("Tokens must not be refilled over the burst")
+	}
+}
+
+func TestTypeRules(t *testing.T) {
+	clock := newFakeClock()
+	l := New(Rules{
+		Default: Rule{Rate: 10, Burst: 10},
+		Types:   map[string]Rule{"cre": {Rate: 0.1, Burst: 1}},
+	}, clock.Now)
+
+	if !l.Allow("cre") {
+		t.Fatalf("First room creation must be allowed")
+	}
+	if l.Allow("cre") {
+		t.Errorf("Second room creation must be rejected by its type rule")
+	}
+	if !l.Allow("pla") {
+		t.Errorf("Other message types must not be limited by the type rule")
+	}
+	clock.Advance(time.Second * 10)
+	if !l.Allow("cre") {
+		t.Errorf("Room creation must be allowed again after refilling")
+	}
+}
+
+func TestTypeRulesWithoutDefault(t *testing.T) {
+	l := New(Rules{Types: map[string]Rule{"scd": {Rate: 1, Burst: 1}}}, newFakeClock().Now)
+
+	for i := 0; i < 100; i++ {
+		if !l.Allow("pla") {
+			t.Fatalf("Messages without a rule must always be allowed")
+		}
+	}
+}
+
+func TestTypeRulesWithoutRate(t *testing.T) {
+	rules := Rules{Types: map[string]Rule{"cre": {Rate: 0, Burst: 1}}}
+	l := New(rules, newFakeClock().Now)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("cre") {
+			t.Fatalf("Type rules with a rate of 0 must not limit messages")
+		}
+	}
+	if rules.Enabled() {
+		t.Errorf("Rules without any rate must not be enabled")
+	}
+}
+
+func TestAbusive(t *testing.T) {
+	clock := newFakeClock()
+	l := New(Rules{Default: Rule{Rate: 1, Burst: 1}, MaxViolations: 3}, clock.Now)
+
+	l.Allow("pla")
+	l.Allow("pla")
+	l.Allow("pla")
+	if l.Abusive() {
+		t.Errorf("Client must not be considered abusive under the maximum violations")
+	}
+	clock.Advance(time.Second)
+	l.Allow("pla")
+	l.Allow("pla")
+	l.Allow("pla")
+	if l.Abusive() {
+		t.Errorf("Allowed messages must reset violations")
+	}
+	l.Allow("pla")
+	if !l.Abusive() {
+		t.Errorf("Client must be considered abusive after %d consecutive violations", 3)
+	}
+}
@@ -0,0 +1,132 @@
+package rating
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileStore is a Store implementation that keeps ratings in memory,
+// writing all of them to a JSON file every time they are updated.
+type FileStore struct {
+	mutex   sync.RWMutex
+	path    string
+	ladders map[Ladder]map[string]Rating
+}
+
+// storedLadder is the format of each ladder in the store's file
+type storedLadder struct {
+	Ladder
+	Ratings []Rating `json:"ratings"`
+}
+
+// NewFileStore returns a new FileStore instance, loading the ratings
+// previously stored in the file at path, if it exists
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:    path,
+		ladders: map[Ladder]map[string]Rating{},
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []storedLadder
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	for _, sl := range stored {
+		s.ladders[sl.Ladder] = map[string]Rating{}
+		for _, rt := range sl.Ratings {
+			s.ladders[sl.Ladder][rt.Player] = rt
+		}
+	}
+	return s, nil
+}
+
+// Rating returns the rating of the passed player in the passed ladder,
+// or a new one with InitialRating if he/she hasn't played in it yet
+func (s *FileStore) Rating(l Ladder, player string) (Rating, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if rt, ok := s.ladders[l][player]; ok {
+		return rt, nil
+	}
+	return Rating{Player: player, Value: InitialRating}, nil
+}
+
+// Update stores the passed ratings in the passed ladder and writes all ladders to the store's file
+func (s *FileStore) Update(l Ladder, ratings []Rating) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.ladders[l]; !ok {
+		s.ladders[l] = map[string]Rating{}
+	}
+	for _, rt := range ratings {
+		s.ladders[l][rt.Player] = rt
+	}
+	return s.write()
+}
+
+// Leaderboard returns up to limit ratings of the passed ladder, highest first
+func (s *FileStore) Leaderboard(l Ladder, limit int) ([]Rating, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	leaderboard := make([]Rating, 0, len(s.ladders[l]))
+	for _, rt := range s.ladders[l] {
+		leaderboard = append(leaderboard, rt)
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].Value != leaderboard[j].Value {
+			return leaderboard[i].Value > leaderboard[j].Value
+		}
+		return leaderboard[i].Player < leaderboard[j].Player
+	})
+	if len(leaderboard) > limit {
+		leaderboard = leaderboard[:limit]
+	}
+	return leaderboard, nil
+}
+
+// write replaces the store's file contents with the current ratings.
+// A temporary file is used so the stored ratings are never left half written.
+func (s *FileStore) write() error {
+	stored := make([]storedLadder, 0, len(s.ladders))
+	for l, ratings := range s.ladders {
+		sl := storedLadder{Ladder: l, Ratings: make([]Rating, 0, len(ratings))}
+		for _, rt := range ratings {
+			sl.Ratings = append(sl.Ratings, rt)
+		}
+		stored = append(stored, sl)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,57 @@
+package rating
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/svera/sackson-server/internal/httpjson"
+)
+
+const (
+	// DefaultLeaderboardLimit is the number of ratings returned in a leaderboard if no limit is specified
+	DefaultLeaderboardLimit = 20
+
+	// MaxLeaderboardLimit is the maximum number of ratings that can be returned in a leaderboard
+	MaxLeaderboardLimit = 100
+)
+
+// RegisterRoutes adds the leaderboard endpoint to the passed router:
+//   GET /leaderboards/{driver}?bots=1&limit=20
+// Leaderboards of games played with bots are returned if the bots parameter is set to 1.
+func RegisterRoutes(r *mux.Router, s Store) {
+	r.HandleFunc("/leaderboards/{driver}", leaderboardHandler(s)).Methods(http.MethodGet)
+}
+
+func leaderboardHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := DefaultLeaderboardLimit
+		if value := r.FormValue("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ladder := Ladder{Driver: mux.Vars(r)["driver"], Bots: r.FormValue("bots") == "1"}
+		leaderboard, err := s.Leaderboard(ladder, ClampLimit(limit))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		httpjson.Write(w, leaderboard)
+	}
+}
+
+// ClampLimit returns the passed leaderboard limit, replacing it with a default value
+// if it is not positive or capping it if it is too big
+func ClampLimit(limit int) int {
+	if limit < 1 {
+		return DefaultLeaderboardLimit
+	}
+	if limit > MaxLeaderboardLimit {
+		return MaxLeaderboardLimit
+	}
+	return limit
+}
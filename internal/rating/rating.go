@@ -0,0 +1,185 @@
+// Package rating implements an Elo based rating system for multiplayer games,
+// updating players' ratings from the final standings of finished games.
+package rating
+
+import (
+	"math"
+
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+const (
+	// InitialRating is the rating assigned to players who haven't played any ranked game,
+	// also used as the fixed rating of bots
+	InitialRating = 1500
+
+	// kFactor is the maximum rating change a player can get in a two players game
+	kFactor = 32
+)
+
+// Ladder identifies a set of ratings. Games played with bots
+// are tracked in a different ladder than games played only by humans.
+type Ladder struct {
+	Driver string `json:"driver"`
+	Bots   bool   `json:"bots"`
+}
+
+// Rating holds the rating of a player in a ladder.
+// Players are identified by their authenticated user ID, Name being the last one they used.
+type Rating struct {
+	Player string  `json:"player"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+// Store is an interface that defines the minimum set of functions needed
+// to implement a ratings storage
+type Store interface {
+	// Rating returns the rating of the passed player in the passed ladder,
+	// or a new one with InitialRating if he/she hasn't played in it yet
+	Rating(l Ladder, player string) (Rating, error)
+
+	// Update stores the passed ratings in the passed ladder
+	Update(l Ladder, ratings []Rating) error
+
+	// Leaderboard returns up to limit ratings of the passed ladder, highest first
+	Leaderboard(l Ladder, limit int) ([]Rating, error)
+}
+
+// Participant holds the data of a player needed to compute the new ratings after a game
+type Participant struct {
+	Player string
+	Rating float64
+	Rank   int
+	// Fixed participants (e.g. bots) affect other players' ratings but don't get theirs updated
+	Fixed bool
+}
+
+// Compute returns the rating changes of every non fixed participant in a game,
+// indexed by player.
+// Multiplayer games are handled as if every participant had played a two players game
+// against each one of the rest, the result of each of these virtual games depending
+// on the ranks of both. Changes are scaled so a game has the same weight
+// no matter the number of players.
+func Compute(participants []Participant) map[string]float64 {
+	changes := map[string]float64{}
+	if len(participants) < 2 {
+		return changes
+	}
+	k := kFactor / float64(len(participants)-1)
+	for i, p := range participants {
+		if p.Fixed {
+			continue
+		}
+		var delta float64
+		for j, opponent := range participants {
+			if i == j {
+				continue
+			}
+			delta += k * (score(p.Rank, opponent.Rank) - expected(p.Rating, opponent.Rating))
+		}
+		changes[p.Player] = delta
+	}
+	return changes
+}
+
+// score returns the result of a virtual two players game between players with the passed ranks
+func score(rank int, opponentRank int) float64 {
+	switch {
+	case rank < opponentRank:
+		return 1
+	case rank > opponentRank:
+		return 0
+	}
+	return 0.5
+}
+
+// expected returns the expected score of a player against an opponent with the passed ratings
+func expected(rating float64, opponentRating float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponentRating-rating)/400))
+}
+
+// updatesQueueSize is the number of finished games whose ratings can be waiting to be updated
+const updatesQueueSize = 64
+
+// RegisterEvents makes the ratings of the passed store be updated every time a game ends.
+// Games with bots are tracked in their own ladders if includeBotGames is true,
+// and ignored otherwise.
+// Updates are processed one at a time, so games ending at the same time
+// can't overwrite each other's changes to the ratings of their players,
+// and dropped if the store falls too far behind.
+func RegisterEvents(obs interfaces.Observer, s Store, includeBotGames bool, log *logger.Logger) {
+	updates := make(chan events.GameEnded, updatesQueueSize)
+	go func() {
+		for event := range updates {
+			ladder := Ladder{Driver: event.Room.GameDriverName(), Bots: hasBots(event.Results)}
+			if err := UpdateFromResults(s, ladder, event.Results); err != nil {
+				log.Error("Couldn't update ratings", "room", event.Room.ID(), "driver", ladder.Driver, "error", err)
+			}
+		}
+	}()
+
+	obs.On(events.GameEnded{}, func(ev interface{}) {
+		if event, ok := ev.(events.GameEnded); ok {
+			if hasBots(event.Results) && !includeBotGames {
+				return
+			}
+			// Observers run on the goroutine of the room or the hub, which must not wait
+			// for the store, so updates are dropped if too many are queued
+			select {
+			case updates <- event:
+			default:
+				log.Error("Too many ratings updates queued, dropping one", "room", event.Room.ID(), "driver", event.Room.GameDriverName())
+			}
+		}
+	})
+}
+
+// UpdateFromResults updates the ratings of the authenticated human players in the passed results.
+// Bots and anonymous players affect the ratings of the rest but don't get their own,
+// and unranked players are not taken into account.
+func UpdateFromResults(s Store, l Ladder, results []messages.PlayerResult) error {
+	participants := []Participant{}
+	current := map[string]Rating{}
+	for _, r := range results {
+		if r.Rank == 0 {
+			continue
+		}
+		if r.Bot || r.UserID == "" {
+			participants = append(participants, Participant{Rating: InitialRating, Rank: r.Rank, Fixed: true})
+			continue
+		}
+		rt, err := s.Rating(l, r.UserID)
+		if err != nil {
+			return err
+		}
+		rt.Name = r.Name
+		current[r.UserID] = rt
+		participants = append(participants, Participant{Player: r.UserID, Rating: rt.Value, Rank: r.Rank})
+	}
+	if len(current) == 0 || len(participants) < 2 {
+		return nil
+	}
+
+	updated := make([]Rating, 0, len(current))
+	for player, change := range Compute(participants) {
+		rt := current[player]
+		rt.Value += change
+		rt.Games++
+		updated = append(updated, rt)
+	}
+	return s.Update(l, updated)
+}
+
+func hasBots(results []messages.PlayerResult) bool {
+	for _, r := range results {
+		if r.Bot {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package rating
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/logger"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/room"
+	"github.com/svera/sackson-server/observer"
+)
+
+func TestComputeTwoPlayers(t *testing.T) {
+	changes := Compute([]Participant{
+		{Player: "Sergio", Rating: 1500, Rank: 1},
+		{Player: "Miguel", Rating: 1500, Rank: 2},
+	})
+
+	if changes["Sergio"] != 16 || changes["Miguel"] != -16 {
+		t.Errorf("Equally rated players must win/lose half of K factor, got %v", changes)
+	}
+}
+
+func TestComputeMultiplayerIsZeroSum(t *testing.T) {
+	changes := Compute([]Participant{
+		{Player: "Sergio", Rating: 1600, Rank: 2},
+		{Player: "Miguel", Rating: 1500, Rank: 1},
+		{Player: "Ana", Rating: 1450, Rank: 2},
+		{Player: "Eva", Rating: 1400, Rank: 4},
+	})
+
+	var sum float64
+	for _, c := range changes {
+		sum += c
+	}
+	if sum > 1e-9 || sum < -1e-9 {
+		t.Errorf("Rating changes must add up to 0, got %f", sum)
+	}
+	if changes["Miguel"] <= 0 || changes["Eva"] >= 0 {
+		t.Errorf("Winner must gain rating and last player must lose it, got %v", changes)
+	}
+}
+
+func TestComputeSkipsFixedParticipants(t *testing.T) {
+	changes := Compute([]Participant{
+		{Player: "Sergio", Rating: 1500, Rank: 1},
+		{Player: "Bot 1", Rating: InitialRating, Rank: 2, Fixed: true},
+	})
+
+	if _, ok := changes["Bot 1"]; ok {
+		t.Errorf("Fixed participants must not get their rating changed")
+	}
+}
+
+func TestUpdateFromResults(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "rating")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ratings.json")
+	s, _ := NewFileStore(path)
+	ladder := Ladder{Driver: "acquire"}
+
+	err := UpdateFromResults(s, ladder, []messages.PlayerResult{
+		{Number: 0, Name: "Sergio", UserID: "u1", Rank: 1},
+		{Number: 1, Name: "Miguel", UserID: "u2", Rank: 2},
+		{Number: 2, Name: "Anonymous", Rank: 3},
+		{Number: 3, Name: "Unranked", UserID: "u3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, _ := NewFileStore(path)
+	leaderboard, _ := reopened.Leaderboard(ladder, 10)
+	if len(leaderboard) != 2 {
+		t.Fatalf("Leaderboard must have 2 players, got %d", len(leaderboard))
+	}
+	if leaderboard[0].Player != "u1" || leaderboard[0].Name != "Sergio" || leaderboard[0].Games != 1 {
+		t.Errorf("Winner must go first in the leaderboard, got %v", leaderboard)
+	}
+}
+
+func TestGamesEndingTogetherKeepAllUpdates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "rating")
+	defer os.RemoveAll(dir)
+	s, _ := NewFileStore(filepath.Join(dir, "ratings.json"))
+	obs := observer.New()
+	RegisterEvents(obs, s, false, logger.New(ioutil.Discard, logger.Options{}))
+
+	for _, opponent := range []string{"u2", "u3", "u4"} {
+		obs.Trigger(events.GameEnded{Room: room.NewMock(), Results: []messages.PlayerResult{
+			{Number: 0, Name: "Sergio", UserID: "u1", Rank: 1},
+			{Number: 1, Name: "Miguel", UserID: opponent, Rank: 2},
+		}})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		rt, _ := s.Rating(Ladder{Driver: "test"}, "u1")
+		if rt.Games == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Every finished game must update the player's rating, got %d games", rt.Games)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockedStore is a Store which doesn't answer until it is released
+type blockedStore struct {
+	Store
+	release chan struct{}
+}
+
+func (s blockedStore) Rating(l Ladder, player string) (Rating, error) {
+	<-s.release
+	return Rating{Player: player, Value: InitialRating}, nil
+}
+
+func (s blockedStore) Update(l Ladder, ratings []Rating) error {
+	return nil
+}
+
+func TestSlowStoreDoesNotBlockGamesEnding(t *testing.T) {
+	s := blockedStore{release: make(chan struct{})}
+	defer close(s.release)
+	obs := observer.New()
+	RegisterEvents(obs, s, false, logger.New(ioutil.Discard, logger.Options{}))
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < updatesQueueSize+2; i++ {
+			obs.Trigger(events.GameEnded{Room: room.NewMock(), Results: []messages.PlayerResult{
+				{Number: 0, Name: "Sergio", UserID: "u1", Rank: 1},
+			}})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Ending games must not wait for the ratings store")
+	}
+}
@@ -20,7 +20,7 @@ func (r *Room) addBotAction(m *interfaces.IncomingMessage) error {
 	var parsed messages.AddBot
 	if err = json.Unmarshal(m.Content, &parsed); err == nil {
 		if err = r.addBot(parsed.BotLevel); err != nil {
-			r.observer.Trigger(events.Error{Client: m.Author, ErrorText: err.Error()})
+			r.observer.Trigger(events.MessageError(m, err.Error()))
 		}
 	}
 	return err
@@ -31,9 +31,13 @@ func (r *Room) addBot(level string) error {
 	var ai api.AI
 	var c interfaces.Client
 
+	if max := r.configuration.MaxBotsPerRoom; max > 0 && len(r.clients)-len(r.HumanClients()) >= max {
+		return errors.New(BotsLimitReached)
+	}
 	if ai, err = r.gameDriver.CreateAI(level); err == nil {
-		c = client.NewBot(ai, r, r.observer)
+		c = client.NewBot(ai, level, r, r.observer, r.log)
 		c.SetName(fmt.Sprintf("Bot %d", r.clientCounter))
+		c.SetGame(r.gameDriver.Name())
 		if _, err = r.addClient(c); err == nil {
 			go c.WritePump()
 			go c.ReadPump(r.messages, r.unregister)
@@ -41,3 +45,18 @@ func (r *Room) addBot(level string) error {
 	}
 	return err
 }
+
+// botStuckAction takes out of the game a bot whose AI has no action to play,
+// as bots have no turn timer and the game would wait for it forever
+func (r *Room) botStuckAction(m *interfaces.IncomingMessage) error {
+	if !m.Author.IsBot() {
+		return errors.New(Forbidden)
+	}
+	if !r.isInTurn(m.Author) {
+		return nil
+	}
+	r.log.Warn("Bot removed from the game, it has no action to play", "room", r.id, "client", r.playerName(m.Author))
+	r.RemoveClient(m.Author)
+	m.Author.Close()
+	return nil
+}
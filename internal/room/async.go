@@ -0,0 +1,131 @@
+package room
+
+import (
+	"time"
+
+	"github.com/svera/sackson-server/internal/client"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+)
+
+// IsAsync returns true if the room is played in asynchronous (correspondence) mode,
+// persisting while its players are disconnected
+func (r *Room) IsAsync() bool {
+	return r.async
+}
+
+// SetAsync sets whether the room is played in asynchronous mode or not
+func (r *Room) SetAsync(value bool) {
+	r.async = value
+}
+
+// DetachClient replaces a disconnected client with an offline placeholder,
+// which keeps his/her seat until he/she comes back to the room
+func (r *Room) DetachClient(c interfaces.Client) {
+	// Disconnected players can't vote the pending proposal
+	defer r.voterLeft(c)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for n, cl := range r.clients {
+		if cl == c {
+			r.replaceClient(n, client.NewOffline(c))
+			c.SetRoom(nil)
+			r.log.Debug("Client detached", "client", c.Name())
+			return
+		}
+	}
+}
+
+// ReattachClient seats the passed client in the place of the offline placeholder
+// with the same session identifier or authenticated user, returning false if there's none in the room
+func (r *Room) ReattachClient(c interfaces.Client) bool {
+	var number int
+	found := false
+
+	mutex.Lock()
+	for n, cl := range r.clients {
+		if offline, ok := cl.(*client.Offline); ok && sameIdentity(offline, c) {
+			c.SetName(offline.Name())
+			r.replaceClient(n, c)
+			number, found = n, true
+			break
+		}
+	}
+	mutex.Unlock()
+
+	if !found {
+		return false
+	}
+	r.log.Debug("Client reattached", "client", c.Name())
+	r.observer.Trigger(events.ClientJoined{Client: c, ClientNumber: number, Owner: c == r.owner})
+	r.observer.Trigger(events.ClientsUpdated{Clients: r.HumanClients(), PlayersData: r.playersData()})
+	if r.GameStarted() {
+		st, _ := r.gameDriver.Status(number)
+		r.sendStatus(c, st, "")
+		r.sendLegalActions(c)
+	}
+	return true
+}
+
+func sameIdentity(a interfaces.Client, b interfaces.Client) bool {
+	if a.UserID() != "" || b.UserID() != "" {
+		return a.UserID() == b.UserID()
+	}
+	return a.SessionID() != "" && a.SessionID() == b.SessionID()
+}
+
+// replaceClient seats a new client in the place of the one with the passed number,
+// keeping its turn and its remaining time to play
+func (r *Room) replaceClient(number int, newClient interfaces.Client) {
+	old := r.clients[number]
+	old.StopTimer()
+	r.forgetStatuses(old)
+	r.clients[number] = newClient
+	r.playerNames[newClient] = r.playerName(old)
+	delete(r.playerNames, old)
+	newClient.SetRoom(r)
+	if r.owner == old {
+		r.owner = newClient
+	}
+
+	inTurn := false
+	for i, cl := range r.clientsInTurn {
+		if cl == old {
+			r.clientsInTurn[i] = newClient
+			inTurn = true
+		}
+	}
+	if !r.GameStarted() || r.IsGameOver() {
+		return
+	}
+	r.setUpTimeOut(newClient)
+	if inTurn && r.playerTimeOut > 0 {
+		remaining := time.Until(r.turnDeadline)
+		if remaining < 0 {
+			remaining = 0
+		}
+		newClient.StartTimer(remaining)
+	}
+}
+
+// notifyTurn informs that it is the turn of the human clients who have to play,
+// so they can be notified in asynchronous rooms
+func (r *Room) notifyTurn() {
+	if !r.async {
+		return
+	}
+	for _, cl := range r.clientsInTurn {
+		if !cl.IsBot() {
+			r.observer.Trigger(events.TurnStarted{Room: r, Client: cl, Deadline: r.turnDeadline})
+		}
+	}
+}
+
+// lifetime returns the time the room lives before being destroyed
+func (r *Room) lifetime() time.Duration {
+	if r.async {
+		return time.Hour * r.configuration.AsyncTimeout
+	}
+	return time.Second * r.configuration.Timeout
+}
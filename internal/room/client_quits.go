@@ -6,8 +6,8 @@ import (
 	"github.com/svera/sackson-server/internal/messages"
 )
 
-func (r *Room) clientQuits(cl interfaces.Client) error {
+func (r *Room) clientQuits(cl interfaces.Client, requestID string) error {
 	r.RemoveClient(cl)
-	r.observer.Trigger(events.ClientOut{Client: cl, Reason: messages.ReasonPlayerQuitted, Room: r})
+	r.observer.Trigger(events.ClientOut{Client: cl, Reason: messages.ReasonPlayerQuitted, Room: r, RequestID: requestID})
 	return nil
 }
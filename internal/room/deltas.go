@@ -0,0 +1,126 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/jsonpatch"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// maxPendingStatuses is the number of statuses not acknowledged yet kept for every client
+const maxPendingStatuses = 32
+
+// statusTracker keeps the game statuses sent to a client, so the following
+// ones can be sent as changes from the last one he/she acknowledged
+type statusTracker struct {
+	acked       int
+	ackedStatus []byte
+	// sent holds the encoded statuses not acknowledged yet, by sequence number
+	sent map[int][]byte
+	// patches is the number of patches sent since the last whole status
+	patches int
+}
+
+// sendStatus sends the passed game status to the client, as a patch if possible.
+// requestID identifies the client's message which led to the status, if any.
+func (r *Room) sendStatus(cl interfaces.Client, status interface{}, requestID string) {
+	ev := events.GameStatusUpdated{Client: cl, Message: status, SequenceNumber: r.updateSequenceNumber, RequestID: requestID}
+	if r.configuration.DeltaUpdates && !cl.IsBot() {
+		r.track(cl, &ev)
+	}
+	r.observer.Trigger(ev)
+}
+
+// track stores the status of the event and, if the client acknowledged a previous one,
+// sets the event patch with the changes since then, unless a whole status is due
+func (r *Room) track(cl interfaces.Client, ev *events.GameStatusUpdated) {
+	current, err := json.Marshal(ev.Message)
+	if err != nil {
+		return
+	}
+
+	r.statusesMutex.Lock()
+	defer r.statusesMutex.Unlock()
+	t, ok := r.statuses[cl]
+	if !ok {
+		t = &statusTracker{sent: map[int][]byte{}}
+		r.statuses[cl] = t
+	}
+	t.sent[ev.SequenceNumber] = current
+	for seq := range t.sent {
+		if seq <= ev.SequenceNumber-maxPendingStatuses {
+			delete(t.sent, seq)
+		}
+	}
+
+	if t.ackedStatus == nil || (r.configuration.DeltaKeyframeInterval > 0 && t.patches >= r.configuration.DeltaKeyframeInterval) {
+		t.patches = 0
+		return
+	}
+	patch, err := jsonpatch.Diff(t.ackedStatus, current)
+	if err != nil {
+		return
+	}
+	if encoded, _ := json.Marshal(patch); len(encoded) >= len(current) {
+		t.patches = 0
+		return
+	}
+	ev.Patch, ev.Base = patch, t.acked
+	t.patches++
+}
+
+// forgetStatuses removes the statuses tracked for the client
+func (r *Room) forgetStatuses(cl interfaces.Client) {
+	r.statusesMutex.Lock()
+	defer r.statusesMutex.Unlock()
+	delete(r.statuses, cl)
+}
+
+func (r *Room) statusAckAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.StatusAck
+
+	if !r.configuration.DeltaUpdates {
+		return errors.New(DeltaUpdatesDisabled)
+	}
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+
+	r.statusesMutex.Lock()
+	defer r.statusesMutex.Unlock()
+	t, ok := r.statuses[m.Author]
+	if !ok || t.sent[parsed.SequenceNumber] == nil {
+		return errors.New(InexistentStatus)
+	}
+	t.acked, t.ackedStatus = parsed.SequenceNumber, t.sent[parsed.SequenceNumber]
+	for seq := range t.sent {
+		if seq <= parsed.SequenceNumber {
+			delete(t.sent, seq)
+		}
+	}
+	return nil
+}
+
+// resyncAction sends the whole current game status to the client,
+// who has to acknowledge it again to get patches
+func (r *Room) resyncAction(m *interfaces.IncomingMessage) error {
+	if !r.GameStarted() {
+		return errors.New(GameNotStarted)
+	}
+	for n, cl := range r.clients {
+		if cl == m.Author {
+			status, err := r.gameDriver.Status(n)
+			if err != nil {
+				return err
+			}
+			r.forgetStatuses(cl)
+			r.sendStatus(cl, status, m.RequestID)
+			r.sendLegalActions(cl)
+			return nil
+		}
+	}
+	return errors.New(InexistentClient)
+}
@@ -6,4 +6,30 @@ const (
 	OwnerNotRemovable = "owner_not_removable"
 	Forbidden         = "forbidden"
 	GameOver          = "game_over"
+	GameNotOver       = "game_not_over"
+	UserAlreadySeated = "user_already_seated"
+	NameNotEditable   = "name_not_editable"
+	BotsLimitReached  = "bots_limit_reached"
+	BannedFromRoom    = "banned_from_room"
+
+	GameNotStarted       = "game_not_started"
+	InexistentStatus     = "inexistent_status"
+	DeltaUpdatesDisabled = "delta_updates_disabled"
+	NotYourTurn          = "not_your_turn"
+	UnknownMessageType   = "unknown_message_type"
+
+	LegalActionsUnsupported = "legal_actions_unsupported"
+
+	NothingToUndo   = "nothing_to_undo"
+	UndoNotAllowed  = "undo_not_allowed"
+	NoUndoPending   = "no_undo_pending"
+	UndoUnsupported = "undo_unsupported"
+
+	InvalidVotingRules = "invalid_voting_rules"
+	UnknownProposal    = "unknown_proposal"
+	VotePending        = "vote_pending"
+	NoVotePending      = "no_vote_pending"
+	AlreadyVoted       = "already_voted"
+	GamePaused         = "game_paused"
+	GameNotPaused      = "game_not_paused"
 )
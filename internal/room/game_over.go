@@ -0,0 +1,87 @@
+package room
+
+import (
+	"sort"
+
+	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/client"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// checkGameOver notifies that the game has ended the first time
+// the driver reports it is over
+func (r *Room) checkGameOver() {
+	if r.gameEnded || !r.IsGameOver() {
+		return
+	}
+	r.gameEnded = true
+	r.cancelProposal()
+	r.observer.Trigger(events.GameEnded{
+		Room:      r,
+		Results:   r.results(),
+		StartedAt: r.gameStartedAt,
+		Actions:   r.actionsCount,
+	})
+}
+
+// results returns the final result of every player seated when the game started,
+// ordered by rank if the driver reports final standings
+func (r *Room) results() []messages.PlayerResult {
+	standings := map[int]api.Standing{}
+	// Drawn games share the first place
+	if r.drawn {
+		for n := range r.seats {
+			standings[n] = api.Standing{PlayerNumber: n, Rank: 1}
+		}
+	} else if reporter, ok := r.gameDriver.(api.ResultsReporter); ok {
+		st, err := reporter.FinalStandings()
+		if err != nil {
+			r.log.Warn("Couldn't get final standings", "error", err)
+		}
+		for _, s := range st {
+			standings[s.PlayerNumber] = s
+		}
+	}
+
+	results := make([]messages.PlayerResult, 0, len(r.seats))
+	for n, seat := range r.seats {
+		result := seat
+		if s, ok := standings[n]; ok {
+			result.Rank = s.Rank
+			result.Score = s.Score
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			// Unranked players go last
+			if results[i].Rank == 0 || results[j].Rank == 0 {
+				return results[j].Rank == 0
+			}
+			return results[i].Rank < results[j].Rank
+		}
+		return results[i].Number < results[j].Number
+	})
+	return results
+}
+
+// seatsData returns the data of the clients seated in the room
+func (r *Room) seatsData() map[int]messages.PlayerResult {
+	seats := make(map[int]messages.PlayerResult, len(r.clients))
+	for n, cl := range r.clients {
+		seat := messages.PlayerResult{
+			Number:    n,
+			Name:      r.playerName(cl),
+			Bot:       cl.IsBot(),
+			UserID:    cl.UserID(),
+			SessionID: cl.SessionID(),
+		}
+		if bot, ok := cl.(*client.BotClient); ok {
+			seat.BotLevel = bot.Level()
+		}
+		seats[n] = seat
+	}
+	return seats
+}
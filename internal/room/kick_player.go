@@ -21,7 +21,39 @@ func (r *Room) kickPlayerAction(m *interfaces.IncomingMessage) error {
 	return err
 }
 
+func (r *Room) banPlayerAction(m *interfaces.IncomingMessage) error {
+	var err error
+	if m.Author != r.owner {
+		return errors.New(Forbidden)
+	}
+	var parsed messages.BanPlayer
+	if err = json.Unmarshal(m.Content, &parsed); err == nil {
+		return r.banClient(parsed.PlayerNumber)
+	}
+	return err
+}
+
 func (r *Room) kickClient(number int) error {
+	return r.expelClient(number, messages.ReasonPlayerKicked)
+}
+
+// banClient kicks a client, preventing his/her user, session and address from joining the room again
+func (r *Room) banClient(number int) error {
+	cl, exist := r.clients[number]
+	if !exist {
+		return errors.New(InexistentClient)
+	}
+	if cl == r.owner {
+		return errors.New(OwnerNotRemovable)
+	}
+	if err := r.bans.BanClient(cl.UserID(), cl.SessionID(), cl.Address(), ""); err != nil {
+		return err
+	}
+	r.log.Debug("Client banned", "client", cl.Name())
+	return r.expelClient(number, messages.ReasonPlayerBanned)
+}
+
+func (r *Room) expelClient(number int, reason string) error {
 	if _, exist := r.clients[number]; !exist {
 		return errors.New(InexistentClient)
 	}
@@ -31,7 +63,7 @@ func (r *Room) kickClient(number int) error {
 	}
 	cl.SetRoom(nil)
 	r.RemoveClient(r.clients[number])
-	r.observer.Trigger(events.ClientOut{Client: cl, Reason: messages.ReasonPlayerKicked, Room: r})
+	r.observer.Trigger(events.ClientOut{Client: cl, Reason: reason, Room: r})
 
 	return nil
 }
@@ -0,0 +1,48 @@
+package room
+
+import (
+	"errors"
+
+	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// LegalActions returns the actions the client can currently do in the game,
+// if the game driver supports listing them
+func (r *Room) LegalActions(c interfaces.Client) ([]api.Action, error) {
+	lister, ok := r.gameDriver.(api.LegalActionLister)
+	if !ok {
+		return nil, errors.New(LegalActionsUnsupported)
+	}
+	for n, cl := range r.Clients() {
+		if cl == c {
+			return lister.LegalActions(n)
+		}
+	}
+	return nil, errors.New(InexistentClient)
+}
+
+// sendLegalActions sends the passed clients the actions they can do, if they are humans
+// in turn and the game driver supports listing them
+func (r *Room) sendLegalActions(clients ...interfaces.Client) {
+	if _, ok := r.gameDriver.(api.LegalActionLister); !ok {
+		return
+	}
+	for _, cl := range clients {
+		if cl.IsBot() || !r.isInTurn(cl) {
+			continue
+		}
+		actions, err := r.LegalActions(cl)
+		if err != nil {
+			r.log.Warn("Couldn't get legal actions", "client", cl.Name(), "error", err)
+			continue
+		}
+		legal := make([]messages.LegalAction, len(actions))
+		for i, a := range actions {
+			legal[i] = messages.LegalAction{Type: a.Type, Params: a.Params}
+		}
+		r.observer.Trigger(events.LegalActionsUpdated{Client: cl, Actions: legal, SequenceNumber: r.updateSequenceNumber})
+	}
+}
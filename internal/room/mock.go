@@ -3,7 +3,9 @@ package room
 import (
 	"time"
 
+	"github.com/svera/sackson-server/api"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/names"
 )
 
 // Mock is a structure that implements the Room interface for testing
@@ -16,7 +18,7 @@ type Mock struct {
 	FakeOwner                     func() interfaces.Client
 	FakeClients                   func() map[int]interfaces.Client
 	FakeHumanClients              func() []interfaces.Client
-	FakeAddHuman                  func(c interfaces.Client) error
+	FakeAddHuman                  func(c interfaces.Client, requestID string) error
 	FakeSetTimer                  func(t *time.Timer)
 	FakeTimer                     func() *time.Timer
 	FakeGameCurrentPlayersClients func() ([]interfaces.Client, error)
@@ -24,11 +26,20 @@ type Mock struct {
 	FakeToBeDestroyed             func(bool)
 	FakeGameDriverName            func() string
 	FakePlayerTimeOut             func() time.Duration
+	FakeIsAsync                   func() bool
+	FakeSetAsync                  func(bool)
+	FakeSetNamePolicy             func(p *names.Policy)
+	FakeDetachClient              func(c interfaces.Client)
+	FakeReattachClient            func(c interfaces.Client) bool
+	FakeCreatedAt                 func() time.Time
+	FakeSnapshot                  func() ([]byte, error)
+	FakeLegalActions              func(c interfaces.Client) ([]api.Action, error)
 	Calls                         map[string]int
 }
 
 // NewMock returns a new Mock instance ready to use
 func NewMock() *Mock {
+	toBeDestroyed := false
 	return &Mock{
 		FakeID: func() string {
 			return "testRoom"
@@ -42,11 +53,11 @@ func NewMock() *Mock {
 		FakeGameStarted: func() bool {
 			return false
 		},
-		FakeAddHuman: func(c interfaces.Client) error {
+		FakeAddHuman: func(c interfaces.Client, requestID string) error {
 			return nil
 		},
-		FakeToBeDestroyed: func(bool) {
-
+		FakeToBeDestroyed: func(v bool) {
+			toBeDestroyed = v
 		},
 		FakeTimer: func() *time.Timer {
 			return nil
@@ -60,8 +71,35 @@ func NewMock() *Mock {
 			return make([]interfaces.Client, 0)
 		},
 		FakeIsToBeDestroyed: func() bool {
+			return toBeDestroyed
+		},
+		FakeIsGameOver: func() bool {
+			return false
+		},
+		FakeOwner: func() interfaces.Client {
+			return nil
+		},
+		FakeIsAsync: func() bool {
 			return false
 		},
+		FakeSetNamePolicy: func(p *names.Policy) {
+		},
+		FakeSetAsync: func(bool) {
+		},
+		FakeDetachClient: func(c interfaces.Client) {
+		},
+		FakeReattachClient: func(c interfaces.Client) bool {
+			return false
+		},
+		FakeCreatedAt: func() time.Time {
+			return time.Time{}
+		},
+		FakeSnapshot: func() ([]byte, error) {
+			return nil, nil
+		},
+		FakeLegalActions: func(c interfaces.Client) ([]api.Action, error) {
+			return nil, nil
+		},
 		Calls: make(map[string]int),
 	}
 }
@@ -107,9 +145,9 @@ func (r *Mock) HumanClients() []interfaces.Client {
 }
 
 // AddHuman mocks the AddHuman method defined in the Room interface
-func (r *Mock) AddHuman(c interfaces.Client) error {
+func (r *Mock) AddHuman(c interfaces.Client, requestID string) error {
 	r.Calls["AddHuman"]++
-	return r.FakeAddHuman(c)
+	return r.FakeAddHuman(c, requestID)
 }
 
 // SetTimer mocks the SetTimer method defined in the Room interface
@@ -146,3 +184,44 @@ func (r *Mock) GameDriverName() string {
 func (r *Mock) PlayerTimeOut() time.Duration {
 	return r.FakePlayerTimeOut()
 }
+
+// IsAsync mocks the IsAsync method defined in the Room interface
+func (r *Mock) IsAsync() bool {
+	return r.FakeIsAsync()
+}
+
+// SetAsync mocks the SetAsync method defined in the Room interface
+func (r *Mock) SetAsync(value bool) {
+	r.FakeSetAsync(value)
+}
+
+// SetNamePolicy mocks the SetNamePolicy method defined in the Room interface
+func (r *Mock) SetNamePolicy(p *names.Policy) {
+	r.FakeSetNamePolicy(p)
+}
+
+// DetachClient mocks the DetachClient method defined in the Room interface
+func (r *Mock) DetachClient(c interfaces.Client) {
+	r.Calls["DetachClient"]++
+	r.FakeDetachClient(c)
+}
+
+// ReattachClient mocks the ReattachClient method defined in the Room interface
+func (r *Mock) ReattachClient(c interfaces.Client) bool {
+	return r.FakeReattachClient(c)
+}
+
+// CreatedAt mocks the CreatedAt method defined in the Room interface
+func (r *Mock) CreatedAt() time.Time {
+	return r.FakeCreatedAt()
+}
+
+// Snapshot mocks the Snapshot method defined in the Room interface
+func (r *Mock) Snapshot() ([]byte, error) {
+	return r.FakeSnapshot()
+}
+
+// LegalActions mocks the LegalActions method defined in the Room interface
+func (r *Mock) LegalActions(c interfaces.Client) ([]api.Action, error) {
+	return r.FakeLegalActions(c)
+}
@@ -0,0 +1,106 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/client"
+	"github.com/svera/sackson-server/internal/drivers"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// CreateDriver holds a factory function that can be replaced in tests, so it returns a mocked driver instead
+var CreateDriver = func(name string) (api.Driver, error) {
+	return drivers.Create(name)
+}
+
+func (r *Room) rematchAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.Rematch
+	var err error
+
+	if m.Author != r.owner {
+		return errors.New(Forbidden)
+	}
+	if !r.IsGameOver() {
+		return errors.New(GameNotOver)
+	}
+	// Rotation is optional, so an empty content is allowed
+	if len(m.Content) > 0 {
+		if err = json.Unmarshal(m.Content, &parsed); err != nil {
+			return err
+		}
+	}
+	return r.rematch(parsed.RotateSeats)
+}
+
+// rematch puts the room back to its lobby state, keeping the same seated clients
+// but with a fresh instance of the game driver
+func (r *Room) rematch(rotateSeats bool) error {
+	driver, err := CreateDriver(r.gameDriver.Name())
+	if err != nil {
+		return err
+	}
+	// Bots need new AIs too, as the old ones keep the state of the finished game
+	ais := map[*client.BotClient]api.AI{}
+	for _, cl := range r.clients {
+		if bot, ok := cl.(*client.BotClient); ok {
+			if ais[bot], err = driver.CreateAI(bot.Level()); err != nil {
+				return err
+			}
+		}
+	}
+
+	mutex.Lock()
+	for _, cl := range r.clientsInTurn {
+		cl.StopTimer()
+	}
+	r.clientsInTurn = nil
+	r.playerTimeOut = 0
+	r.gameDriver = driver
+	for bot, ai := range ais {
+		bot.SetAI(ai)
+	}
+	r.seats = nil
+	r.gameEnded = false
+	r.drawn = false
+	r.paused = false
+	if rotateSeats {
+		r.rotateSeats()
+	}
+	mutex.Unlock()
+
+	if r.timer != nil && r.lifetime() > 0 {
+		r.timer.Reset(r.lifetime())
+	}
+
+	if rotateSeats {
+		for n, cl := range r.clients {
+			if !cl.IsBot() {
+				r.observer.Trigger(events.ClientJoined{Client: cl, ClientNumber: n, Owner: cl == r.owner})
+			}
+		}
+	}
+	r.observer.Trigger(events.ClientsUpdated{Clients: mapToSlice(r.clients), PlayersData: r.playersData()})
+	r.observer.Trigger(events.RoomReset{Room: r})
+	return nil
+}
+
+// rotateSeats moves every client to the next occupied seat,
+// the one in the last seat moving to the first one
+func (r *Room) rotateSeats() {
+	numbers := make([]int, 0, len(r.clients))
+	for n := range r.clients {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	rotated := make(map[int]interfaces.Client, len(r.clients))
+	for i, n := range numbers {
+		rotated[numbers[(i+1)%len(numbers)]] = r.clients[n]
+	}
+	r.clients = rotated
+}
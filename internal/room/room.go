@@ -1,16 +1,20 @@
 package room
 
 import (
-	"log"
+	"errors"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/ban"
 	"github.com/svera/sackson-server/internal/config"
 	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
 	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/metrics"
+	"github.com/svera/sackson-server/internal/names"
 )
 
 var (
@@ -53,6 +57,59 @@ type Room struct {
 	updateSequenceNumber int
 
 	toBeDestroyed bool
+
+	// seats holds the players seated when the game started
+	seats map[int]messages.PlayerResult
+
+	gameStartedAt time.Time
+
+	actionsCount int
+
+	// gameEnded is set to true once the end of the game has been notified
+	gameEnded bool
+
+	// async rooms keep the seats of their disconnected players
+	async bool
+
+	// turnDeadline is the time when the players in turn will time out
+	turnDeadline time.Time
+
+	createdAt time.Time
+
+	// startingPlayers holds the names of the players when the game started, by number
+	startingPlayers map[int]string
+
+	// history holds the changes made to the game since it started
+	history []change
+
+	// proposal is the proposal being voted by the players, if any
+	proposal *proposal
+
+	// voteMajority and voteTimeout are the rules used to vote proposals
+	voteMajority string
+	voteTimeout  time.Duration
+
+	// paused is set to true while the game is paused by a vote
+	paused bool
+
+	// drawn is set to true if the players agreed to end the game as a draw
+	drawn bool
+
+	// names is the policy the names chosen by players must follow
+	names *names.Policy
+
+	// playerNames holds the names of the seated clients in the room, made unique among them
+	// without renaming the clients themselves
+	playerNames map[interfaces.Client]string
+
+	// bans holds the users, sessions and addresses banned from the room by its owner
+	bans *ban.List
+
+	// statuses tracks the game statuses sent to every human client, for delta updates
+	statuses      map[interfaces.Client]*statusTracker
+	statusesMutex sync.Mutex
+
+	log *logger.Logger
 }
 
 // New returns a new Room instance
@@ -64,6 +121,7 @@ func New(
 	unregister chan interfaces.Client,
 	cfg *config.Config,
 	ob interfaces.Observer,
+	log *logger.Logger,
 ) *Room {
 	return &Room{
 		id:                   id,
@@ -78,6 +136,12 @@ func New(
 		clientCounter:        0,
 		updateSequenceNumber: 0,
 		toBeDestroyed:        false,
+		createdAt:            time.Now(),
+		statuses:             map[interfaces.Client]*statusTracker{},
+		bans:                 ban.NewList(),
+		names:                names.NewPolicy(cfg.Names),
+		playerNames:          map[interfaces.Client]string{},
+		log:                  log.With("room", id, "driver", g.Name()),
 	}
 }
 
@@ -86,8 +150,8 @@ func New(
 func (r *Room) Parse(m *interfaces.IncomingMessage) {
 	if r.isControlMessage(m) {
 		r.parseControlMessage(m)
-	} else if r.gameDriver.IsGameOver() {
-		r.observer.Trigger(events.Error{Client: m.Author, ErrorText: GameOver})
+	} else if r.IsGameOver() {
+		r.observer.Trigger(events.MessageError(m, GameOver))
 	} else {
 		r.passMessageToGame(m)
 	}
@@ -99,8 +163,18 @@ func (r *Room) isControlMessage(m *interfaces.IncomingMessage) bool {
 		messages.TypeAddBot,
 		messages.TypeStartGame,
 		messages.TypeKickPlayer,
+		messages.TypeBanPlayer,
 		messages.TypePlayerQuits,
-		messages.TypeSetClientData:
+		messages.TypeSetClientData,
+		messages.TypeRematch,
+		messages.TypeStatusAck,
+		messages.TypeResync,
+		messages.TypePropose,
+		messages.TypeVote,
+		messages.TypeVoteExpired,
+		messages.TypeBotStuck,
+		messages.TypeUndoRequest,
+		messages.TypeUndoVote:
 		return true
 	}
 	return false
@@ -119,15 +193,45 @@ func (r *Room) parseControlMessage(m *interfaces.IncomingMessage) {
 	case messages.TypeKickPlayer:
 		err = r.kickPlayerAction(m)
 
+	case messages.TypeBanPlayer:
+		err = r.banPlayerAction(m)
+
 	case messages.TypePlayerQuits:
-		err = r.clientQuits(m.Author)
+		err = r.clientQuits(m.Author, m.RequestID)
 
 	case messages.TypeSetClientData:
 		err = r.setClientDataAction(m)
+
+	case messages.TypeRematch:
+		err = r.rematchAction(m)
+
+	case messages.TypeStatusAck:
+		err = r.statusAckAction(m)
+
+	case messages.TypeResync:
+		err = r.resyncAction(m)
+
+	case messages.TypePropose:
+		err = r.proposeAction(m)
+
+	case messages.TypeVote:
+		err = r.voteAction(m)
+
+	case messages.TypeVoteExpired:
+		r.voteExpiredAction()
+
+	case messages.TypeBotStuck:
+		err = r.botStuckAction(m)
+
+	case messages.TypeUndoRequest:
+		err = r.propose(m.Author, messages.Propose{Kind: messages.ProposalUndo})
+
+	case messages.TypeUndoVote:
+		err = r.undoVoteAction(m)
 	}
 
 	if err != nil {
-		r.observer.Trigger(events.Error{Client: m.Author, ErrorText: err.Error()})
+		r.observer.Trigger(events.MessageFailed(m, err))
 	}
 }
 
@@ -135,29 +239,81 @@ func (r *Room) passMessageToGame(m *interfaces.IncomingMessage) {
 	var err error
 	var st interface{}
 
-	if r.messageAuthorIsInTurn(m) {
-		p := api.Action{PlayerName: m.Author.Name(), Type: m.Type, Params: m.Content}
-		if err = r.gameDriver.Execute(p); err == nil {
-			r.updateSequenceNumber++
-			for n, cl := range r.clients {
-				if cl.IsBot() && r.IsGameOver() {
-					continue
-				}
-				st, _ = r.gameDriver.Status(n)
-				r.observer.Trigger(events.GameStatusUpdated{Client: cl, Message: st, SequenceNumber: r.updateSequenceNumber})
-			}
-			if r.turnMovedToNewPlayers() {
-				r.changeClientsInTurn()
-			}
+	if !r.isActionType(m.Type) {
+		r.observer.Trigger(events.MessageError(m, UnknownMessageType))
+		return
+	}
+	if r.paused {
+		r.observer.Trigger(events.MessageError(m, GamePaused))
+		return
+	}
+	if !r.messageAuthorIsInTurn(m) {
+		if r.GameStarted() {
+			r.observer.Trigger(events.MessageError(m, NotYourTurn))
 		} else {
-			r.observer.Trigger(events.Error{Client: m.Author, ErrorText: err.Error()})
+			r.observer.Trigger(events.MessageError(m, GameNotStarted))
+		}
+		return
+	}
+
+	p := api.Action{PlayerName: r.playerName(m.Author), Type: m.Type, Params: m.Content}
+	before := r.statusesBeforeChange()
+	start := time.Now()
+	err = r.gameDriver.Execute(p)
+	metrics.DriverExecuteDuration.Observe(time.Since(start).Seconds(), r.gameDriver.Name())
+	if err != nil {
+		r.observer.Trigger(events.MessageError(m, err.Error()))
+		return
+	}
+	r.recordChange(m.Author, before, func(d api.Driver) error {
+		return d.Execute(p)
+	})
+	r.actionsCount++
+	r.updateSequenceNumber++
+	for n, cl := range r.clients {
+		if cl.IsBot() && r.IsGameOver() {
+			continue
+		}
+		st, _ = r.gameDriver.Status(n)
+		var requestID string
+		if cl == m.Author {
+			requestID = m.RequestID
 		}
+		r.sendStatus(cl, st, requestID)
 	}
+	if r.turnMovedToNewPlayers() {
+		r.changeClientsInTurn()
+	}
+	r.sendLegalActions(r.clientsInTurn...)
+	r.checkGameOver()
+}
+
+// isActionType returns true if the game driver accepts actions of the passed type.
+// All types are accepted if the driver doesn't declare them.
+func (r *Room) isActionType(typ string) bool {
+	typer, ok := r.gameDriver.(api.ActionTyper)
+	if !ok {
+		return true
+	}
+	types := typer.ActionTypes()
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *Room) messageAuthorIsInTurn(m *interfaces.IncomingMessage) bool {
+	return r.isInTurn(m.Author)
+}
+
+func (r *Room) isInTurn(c interfaces.Client) bool {
 	for _, cl := range r.clientsInTurn {
-		if m.Author == cl {
+		if c == cl {
 			return true
 		}
 	}
@@ -185,9 +341,14 @@ func (r *Room) changeClientsInTurn() {
 	}
 	r.clientsInTurn, _ = r.GameCurrentPlayersClients()
 	r.startClientsInTurnTimers()
+	r.notifyTurn()
 }
 
 func (r *Room) startClientsInTurnTimers() {
+	r.turnDeadline = time.Time{}
+	if r.playerTimeOut > 0 {
+		r.turnDeadline = time.Now().Add(time.Second * r.playerTimeOut)
+	}
 	for _, cl := range r.clientsInTurn {
 		if !cl.IsBot() && r.playerTimeOut > 0 {
 			cl.StartTimer(time.Second * r.playerTimeOut)
@@ -199,7 +360,7 @@ func (r *Room) playersData() map[string]messages.PlayerData {
 	players := make(map[string]messages.PlayerData, len(r.clients))
 	for n, c := range r.clients {
 		players[strconv.Itoa(n)] = messages.PlayerData{
-			Name: c.Name(),
+			Name: r.playerName(c),
 		}
 	}
 	return players
@@ -216,16 +377,15 @@ func (r *Room) GameCurrentPlayersClients() ([]interfaces.Client, error) {
 }
 
 // AddHuman adds a new client to the room. If the client has successfully joined,
-// a message with his/her number in the room is send back to the client.
-func (r *Room) AddHuman(cl interfaces.Client) error {
+// a message with his/her number in the room is send back to the client,
+// along with the passed request identifier.
+func (r *Room) AddHuman(cl interfaces.Client, requestID string) error {
 	var err error
 	var clientNumber int
 
 	if clientNumber, err = r.addClient(cl); err == nil {
-		if r.configuration.Debug {
-			log.Printf("Client '%s' added to room %s", cl.Name(), r.ID())
-		}
-		r.observer.Trigger(events.ClientJoined{Client: cl, ClientNumber: clientNumber, Owner: cl == r.owner})
+		r.log.Debug("Client added", "client", cl.Name(), "request", requestID)
+		r.observer.Trigger(events.ClientJoined{Client: cl, ClientNumber: clientNumber, Owner: cl == r.owner, RequestID: requestID})
 	}
 	return err
 }
@@ -234,6 +394,20 @@ func (r *Room) addClient(c interfaces.Client) (int, error) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	if r.bans.Banned(c.UserID(), c.SessionID(), c.Address()) {
+		return 0, errors.New(BannedFromRoom)
+	}
+
+	// Authenticated users can only have one seat in a room
+	if c.UserID() != "" {
+		for _, cl := range r.clients {
+			if cl.UserID() == c.UserID() {
+				return 0, errors.New(UserAlreadySeated)
+			}
+		}
+	}
+
+	r.playerNames[c] = r.uniqueName(c, c.Name())
 	r.clients[r.clientCounter] = c
 	newClientNumber := r.clientCounter
 	r.clientCounter++
@@ -246,9 +420,31 @@ func (r *Room) addClient(c interfaces.Client) (int, error) {
 	return newClientNumber, nil
 }
 
+// uniqueName returns the passed name for the passed client, followed by a number
+// if another client in the room already uses it
+func (r *Room) uniqueName(c interfaces.Client, name string) string {
+	taken := make([]string, 0, len(r.clients))
+	for _, cl := range r.clients {
+		if cl != c {
+			taken = append(taken, r.playerName(cl))
+		}
+	}
+	return r.names.Unique(name, taken)
+}
+
+// playerName returns the name the passed client has in the room
+func (r *Room) playerName(c interfaces.Client) string {
+	if name, ok := r.playerNames[c]; ok {
+		return name
+	}
+	return c.Name()
+}
+
 // RemoveClient removes a client and its player
 // depending wether the game has already started or not.
 func (r *Room) RemoveClient(c interfaces.Client) {
+	// Deferred before locking, so the pending proposal is updated once the client is out
+	defer r.clientLeft(c)
 	mutex.Lock()
 	defer mutex.Unlock()
 
@@ -257,12 +453,14 @@ func (r *Room) RemoveClient(c interfaces.Client) {
 			r.clients[i].SetRoom(nil)
 			c.StopTimer()
 			delete(r.clients, i)
+			delete(r.playerNames, c)
+			r.forgetStatuses(c)
 
 			if len(r.HumanClients()) == 0 {
 				return
 			}
 
-			if r.gameDriver.GameStarted() && !r.gameDriver.IsGameOver() {
+			if r.gameDriver.GameStarted() && !r.IsGameOver() {
 				r.removePlayer(i)
 			} else {
 				r.observer.Trigger(events.ClientsUpdated{Clients: r.HumanClients(), PlayersData: r.playersData()})
@@ -276,16 +474,26 @@ func (r *Room) RemoveClient(c interfaces.Client) {
 // removePlayer removes a player from a game,
 // and returns an updated game status to all the players as a response
 func (r *Room) removePlayer(playerNumber int) {
+	before := r.statusesBeforeChange()
 	r.gameDriver.RemovePlayer(playerNumber)
+	r.recordChange(nil, before, func(d api.Driver) error {
+		return d.RemovePlayer(playerNumber)
+	})
 
 	if r.turnMovedToNewPlayers() {
 		r.changeClientsInTurn()
 	}
 
+	r.broadcastStatuses()
+	r.checkGameOver()
+}
+
+// broadcastStatuses sends the current game status to all clients with a new sequence number
+func (r *Room) broadcastStatuses() {
 	r.updateSequenceNumber++
 	for i, cl := range r.clients {
 		st, _ := r.gameDriver.Status(i)
-		r.observer.Trigger(events.GameStatusUpdated{Client: cl, Message: st, SequenceNumber: r.updateSequenceNumber})
+		r.sendStatus(cl, st, "")
 	}
 }
 
@@ -296,7 +504,7 @@ func (r *Room) GameStarted() bool {
 
 // IsGameOver returns true if the room's game has ended, false otherwise
 func (r *Room) IsGameOver() bool {
-	return r.gameDriver.IsGameOver()
+	return r.drawn || r.gameDriver.IsGameOver()
 }
 
 // ID returns the room's ID
@@ -332,6 +540,11 @@ func (r *Room) SetTimer(t *time.Timer) {
 	r.timer = t
 }
 
+// SetNamePolicy sets the policy the names chosen by players must follow
+func (r *Room) SetNamePolicy(p *names.Policy) {
+	r.names = p
+}
+
 // Timer returns the room's timer
 func (r *Room) Timer() *time.Timer {
 	return r.timer
@@ -360,6 +573,20 @@ func (r *Room) GameDriverName() string {
 	return r.gameDriver.Name()
 }
 
+// CreatedAt returns the time when the room was created
+func (r *Room) CreatedAt() time.Time {
+	return r.createdAt
+}
+
+// Snapshot returns the serialized state of the room's game, or nil if its
+// game driver does not implement the api.Snapshotter interface
+func (r *Room) Snapshot() ([]byte, error) {
+	if s, ok := r.gameDriver.(api.Snapshotter); ok {
+		return s.Snapshot()
+	}
+	return nil, nil
+}
+
 // PlayerTimeOut returns the allowed time per turn for every player
 func (r *Room) PlayerTimeOut() time.Duration {
 	return r.playerTimeOut
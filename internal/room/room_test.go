@@ -2,15 +2,19 @@ package room
 
 import (
 	"testing"
+	"time"
 
 	"encoding/json"
 
+	"github.com/svera/sackson-server/api"
 	"github.com/svera/sackson-server/internal/client"
 	"github.com/svera/sackson-server/internal/config"
 	"github.com/svera/sackson-server/internal/drivers"
 	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/logger"
 	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/names"
 	"github.com/svera/sackson-server/observer"
 )
 
@@ -24,11 +28,19 @@ func setup() (c interfaces.Client, b *drivers.Mock, r *Room) {
 	obs.On(events.GameStatusUpdated{}, func(interface{}) {})
 	obs.On(events.ClientsUpdated{}, func(interface{}) {})
 	obs.On(events.Error{}, func(interface{}) {})
+	obs.On(events.RoomReset{}, func(interface{}) {})
+	obs.On(events.GameEnded{}, func(interface{}) {})
+	obs.On(events.TurnStarted{}, func(interface{}) {})
+	obs.On(events.LegalActionsUpdated{}, func(interface{}) {})
+	obs.On(events.VoteUpdated{}, func(interface{}) {})
+	obs.On(events.UndoRequested{}, func(interface{}) {})
+	obs.On(events.UndoResolved{}, func(interface{}) {})
+	obs.On(events.GameAborted{}, func(interface{}) {})
 
 	c = client.NewMock()
 	b = drivers.NewMock().(*drivers.Mock)
 
-	r = New("test", b, c, make(chan *interfaces.IncomingMessage), make(chan interfaces.Client), &config.Config{Timeout: 1}, obs)
+	r = New("test", b, c, make(chan *interfaces.IncomingMessage), make(chan interfaces.Client), &config.Config{Timeout: 1}, obs, logger.Discard())
 	return c, b, r
 }
 
@@ -65,6 +77,51 @@ func TestAddBot(t *testing.T) {
 	}
 }
 
+func TestAddBotOverLimit(t *testing.T) {
+	_, _, r := setup()
+	r.configuration.MaxBotsPerRoom = 1
+
+	if err := r.addBot("chaotic"); err != nil {
+		t.Fatalf("Adding a bot under the limit must not return an error, got %s", err.Error())
+	}
+	if err := r.addBot("chaotic"); err == nil || err.Error() != BotsLimitReached {
+		t.Errorf("Adding a bot over the limit must return error '%s', got %v", BotsLimitReached, err)
+	}
+	if len(r.clients) != 1 {
+		t.Errorf("Room must have 1 client, got %d", len(r.clients))
+	}
+}
+
+func TestBotWithNoActionIsTakenOutOfTheGame(t *testing.T) {
+	c, b, r := setup()
+	b.FakeGameStarted = true
+	b.FakeCurrentPlayersNumbers = []int{1}
+	bot := client.NewBot(&drivers.AI{Calls: map[string]int{}}, "easy", r, r.observer, r.log)
+	r.clients[0] = c
+	r.clients[1] = bot
+	r.clientsInTurn = []interfaces.Client{bot}
+	go bot.WritePump()
+	go bot.ReadPump(r.messages, r.unregister)
+
+	bot.Incoming() <- []byte(`{"typ":"upd","seq":1,"cnt":{}}`)
+	var m *interfaces.IncomingMessage
+	select {
+	case m = <-r.messages:
+	case <-time.After(time.Second):
+		t.Fatal("Bot with no action to play must tell the room")
+	}
+	if m.Type != messages.TypeBotStuck {
+		t.Fatalf("Bot with no action to play must send a '%s' message, got '%s'", messages.TypeBotStuck, m.Type)
+	}
+
+	b.FakeCurrentPlayersNumbers = []int{0}
+	r.Parse(m)
+
+	if len(r.clients) != 1 || r.clients[0] != c {
+		t.Errorf("Bot with no action to play must be taken out of the game, got %v", r.clients)
+	}
+}
+
 func TestKickPlayer(t *testing.T) {
 	c, _, r := setup()
 
@@ -85,6 +142,35 @@ func TestKickPlayer(t *testing.T) {
 	}
 }
 
+func TestBanPlayer(t *testing.T) {
+	c, _, r := setup()
+	var reasons []string
+	r.observer.On(events.ClientOut{}, func(ev interface{}) {
+		reasons = append(reasons, ev.(events.ClientOut).Reason)
+	})
+	toBeBanned := client.NewMock()
+	toBeBanned.FakeSessionID = func() string { return "abc" }
+	toBeBanned.FakeAddress = func() string { return "10.0.0.1" }
+	r.clients[0] = c
+	r.clients[1] = toBeBanned
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: messages.TypeBanPlayer, Content: json.RawMessage(`{"ply": 1}`)})
+	if len(r.clients) != 1 || len(reasons) != 1 || reasons[0] != messages.ReasonPlayerBanned {
+		t.Fatalf("Banned player must be expelled from the room, got reasons %v", reasons)
+	}
+
+	sameAddress := client.NewMock()
+	sameAddress.FakeAddress = func() string { return "10.0.0.1" }
+	for _, cl := range []interfaces.Client{toBeBanned, sameAddress} {
+		if err := r.AddHuman(cl, ""); err == nil || err.Error() != BannedFromRoom {
+			t.Errorf("Banned clients must not join the room again, got %v", err)
+		}
+	}
+	if err := r.AddHuman(client.NewMock(), ""); err != nil {
+		t.Errorf("Clients not banned must join the room, got %s", err.Error())
+	}
+}
+
 func TestKickOwnerNotAllowed(t *testing.T) {
 	c, _, r := setup()
 
@@ -124,9 +210,565 @@ func TestPlayerQuits(t *testing.T) {
 func TestAddHuman(t *testing.T) {
 	c, _, r := setup()
 
-	r.AddHuman(c)
+	r.AddHuman(c, "")
 
 	if len(r.clients) != 1 {
 		t.Errorf("Room must have 1 client, got %d", len(r.clients))
 	}
 }
+
+// namedMock returns a client mock which keeps the names set to it
+func namedMock(name string) *client.Mock {
+	c := client.NewMock()
+	c.FakeName = func() string {
+		return name
+	}
+	c.FakeSetName = func(n string) interfaces.Client {
+		name = n
+		return c
+	}
+	return c
+}
+
+func TestClientNamesAreValidAndUnique(t *testing.T) {
+	_, _, r := setup()
+	r.configuration.Names = config.NamesConfig{MaxLength: 10}
+	r.SetNamePolicy(names.NewPolicy(r.configuration.Names))
+	var rejections []events.Error
+	r.observer.On(events.Error{}, func(ev interface{}) {
+		rejections = append(rejections, ev.(events.Error))
+	})
+	first, second := namedMock("Sergio"), namedMock("sergio")
+	r.AddHuman(first, "")
+	r.AddHuman(second, "")
+	if r.playerName(second) != "sergio 2" || second.Name() != "sergio" {
+		t.Errorf("Clients joining with a taken name must get a number appended in the room only, got '%s'", r.playerName(second))
+	}
+
+	r.Parse(&interfaces.IncomingMessage{Author: second, Type: messages.TypeSetClientData, Content: json.RawMessage(`{"nam": "Sergio Miguel"}`)})
+	if len(rejections) != 1 || rejections[0].ErrorText != names.InvalidName || rejections[0].Details.(*names.Error).Rule != names.RuleTooLong {
+		t.Fatalf("Too long names must be rejected with their details, got %v", rejections)
+	}
+	r.Parse(&interfaces.IncomingMessage{Author: second, Type: messages.TypeSetClientData, Content: json.RawMessage(`{"nam": " "}`)})
+	if len(rejections) != 2 || r.playerName(second) != "sergio 2" {
+		t.Fatalf("Empty names must be rejected, got %v", rejections)
+	}
+	r.Parse(&interfaces.IncomingMessage{Author: second, Type: messages.TypeSetClientData, Content: json.RawMessage(`{"nam": " SERGIO "}`)})
+	if r.playerName(second) != "SERGIO 2" {
+		t.Errorf("Names taken by other clients must get a number appended, got '%s'", r.playerName(second))
+	}
+	r.RemoveClient(second)
+	if second.Name() != "SERGIO" {
+		t.Errorf("Clients must keep their own names once out of the room, got '%s'", second.Name())
+	}
+}
+
+func TestAddHumanOneSeatPerUser(t *testing.T) {
+	c, _, r := setup()
+	c.SetUserID("42")
+	r.AddHuman(c, "")
+
+	sameUser := client.NewMock()
+	sameUser.SetUserID("42")
+	if err := r.AddHuman(sameUser, ""); err == nil || err.Error() != UserAlreadySeated {
+		t.Errorf("Room must return error '%s' when adding a user already seated, got %v", UserAlreadySeated, err)
+	}
+	if len(r.clients) != 1 {
+		t.Errorf("Room must have 1 client, got %d", len(r.clients))
+	}
+}
+
+func TestRematch(t *testing.T) {
+	c, b, r := setup()
+	b.FakeIsGameOver = true
+	newDriver := drivers.NewMock()
+	defer func(f func(string) (api.Driver, error)) { CreateDriver = f }(CreateDriver)
+	CreateDriver = func(name string) (api.Driver, error) {
+		return newDriver, nil
+	}
+
+	m := &interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeRematch,
+		Content: (json.RawMessage)([]byte(`{}`)),
+	}
+	r.clients[0] = c
+	r.clients[1] = client.NewBot(&drivers.AI{}, "easy", r, r.observer, r.log)
+	r.owner = c
+	r.Parse(m)
+
+	if r.gameDriver != newDriver {
+		t.Errorf("Room must use a new game driver instance after a rematch")
+	}
+	if newDriver.(*drivers.Mock).Calls["CreateAI"] != 1 {
+		t.Errorf("Bots must get a new AI from the new game driver after a rematch")
+	}
+}
+
+func TestRematchNotAllowedIfGameNotOver(t *testing.T) {
+	c, b, r := setup()
+	b.FakeIsGameOver = false
+
+	m := &interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeRematch,
+		Content: (json.RawMessage)([]byte(`{}`)),
+	}
+	r.clients[0] = c
+	r.owner = c
+	r.Parse(m)
+
+	if r.gameDriver != b {
+		t.Errorf("Room must keep its game driver if the game is not over")
+	}
+}
+
+func TestRematchRotatesSeats(t *testing.T) {
+	c, b, r := setup()
+	b.FakeIsGameOver = true
+	defer func(f func(string) (api.Driver, error)) { CreateDriver = f }(CreateDriver)
+	CreateDriver = func(name string) (api.Driver, error) {
+		return drivers.NewMock(), nil
+	}
+	c2 := client.NewMock()
+	c2.FakeIsBot = func() bool { return true }
+
+	m := &interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeRematch,
+		Content: (json.RawMessage)([]byte(`{"rot": true}`)),
+	}
+	r.clients[0] = c
+	r.clients[2] = c2
+	r.owner = c
+	r.Parse(m)
+
+	if r.clients[2] != c || r.clients[0] != c2 {
+		t.Errorf("Room must rotate seats after a rematch with rotation")
+	}
+}
+
+func TestGameEndedTriggeredOnce(t *testing.T) {
+	c, b, r := setup()
+	var results []messages.PlayerResult
+	triggered := 0
+	r.observer.On(events.GameEnded{}, func(ev interface{}) {
+		triggered++
+		results = ev.(events.GameEnded).Results
+	})
+	c2 := client.NewMock()
+	c2.FakeIsBot = func() bool { return true }
+	r.clients[0] = c
+	r.clients[1] = c2
+	r.clientsInTurn = []interfaces.Client{c}
+	r.seats = r.seatsData()
+	b.FakeStandings = []api.Standing{{PlayerNumber: 1, Rank: 1, Score: 10}, {PlayerNumber: 0, Rank: 2, Score: 5}}
+	b.FakeExecute = func(action api.Action) error {
+		b.FakeIsGameOver = true
+		return nil
+	}
+
+	m := &interfaces.IncomingMessage{
+		Author:  c,
+		Type:    "gam",
+		Content: (json.RawMessage)([]byte(`{}`)),
+	}
+	r.Parse(m)
+	r.removePlayer(1)
+
+	if triggered != 1 {
+		t.Fatalf("Room must trigger GameEnded once, got %d", triggered)
+	}
+	if len(results) != 2 || results[0].Number != 1 || results[0].Rank != 1 || !results[0].Bot {
+		t.Errorf("Results must be ordered by rank, got %v", results)
+	}
+}
+
+func TestAsyncRoomKeepsSeatOfDisconnectedClient(t *testing.T) {
+	c, _, r := setup()
+	r.SetAsync(true)
+	c.SetSessionID("abc")
+	r.AddHuman(c, "")
+
+	r.DetachClient(c)
+	if _, ok := r.clients[0].(*client.Offline); !ok {
+		t.Fatalf("Room must keep the seat of a detached client with an offline client, got %T", r.clients[0])
+	}
+
+	other := client.NewMock()
+	other.SetSessionID("xyz")
+	if r.ReattachClient(other) {
+		t.Errorf("Room must not reattach a client with a different session")
+	}
+
+	back := client.NewMock()
+	back.SetSessionID("abc")
+	if !r.ReattachClient(back) {
+		t.Fatalf("Room must reattach a client with the session of an offline seat")
+	}
+	if r.clients[0] != back {
+		t.Errorf("Reattached client must take back his/her seat")
+	}
+	if r.Owner() != back {
+		t.Errorf("Reattached client must keep being the owner of the room")
+	}
+}
+
+func TestAsyncRoomNotifiesTurn(t *testing.T) {
+	c, b, r := setup()
+	notified := []interfaces.Client{}
+	r.observer.On(events.TurnStarted{}, func(ev interface{}) {
+		notified = append(notified, ev.(events.TurnStarted).Client)
+	})
+	mock := c.(*client.Mock)
+	mock.FakeSetTimer = func(*time.Timer) {}
+	mock.FakeStartTimer = func(time.Duration) {}
+	mock.FakeStopTimer = func() {}
+	r.SetAsync(true)
+	r.AddHuman(c, "")
+	b.FakeCurrentPlayersNumbers = []int{0}
+
+	r.Parse(&interfaces.IncomingMessage{
+		Author:  c,
+		Type:    messages.TypeStartGame,
+		Content: json.RawMessage(`{"dln": 24}`),
+	})
+
+	if len(notified) != 1 || notified[0] != c {
+		t.Errorf("Room must notify the turn of the client in turn, got %v", notified)
+	}
+	if r.PlayerTimeOut() != 24*60*60 {
+		t.Errorf("Turn deadline must be converted to seconds, got %d", r.PlayerTimeOut())
+	}
+}
+
+func TestDeltaUpdates(t *testing.T) {
+	c, b, r := setup()
+	sent := []events.GameStatusUpdated{}
+	r.observer.On(events.GameStatusUpdated{}, func(ev interface{}) {
+		sent = append(sent, ev.(events.GameStatusUpdated))
+	})
+	r.configuration.DeltaUpdates = true
+	r.clients[0] = c
+	b.FakeGameStarted = true
+	status := map[string]interface{}{"brd": map[string]string{"1A": "empty", "2A": "empty", "3A": "empty"}, "sta": "PlayTile"}
+
+	r.updateSequenceNumber = 1
+	r.sendStatus(c, status, "")
+	if sent[0].Patch != nil {
+		t.Fatalf("First status must be sent whole")
+	}
+
+	ack := &interfaces.IncomingMessage{Author: c, Type: messages.TypeStatusAck, Content: json.RawMessage(`{"seq": 1}`)}
+	r.Parse(ack)
+	status["sta"] = "BuyStock"
+	r.updateSequenceNumber = 2
+	r.sendStatus(c, status, "")
+	if len(sent[1].Patch) != 1 || sent[1].Patch[0].Path != "/sta" || sent[1].Base != 1 {
+		t.Errorf("Status after an acknowledgement must be sent as a patch over it, got %v with base %d", sent[1].Patch, sent[1].Base)
+	}
+
+	if err := r.statusAckAction(&interfaces.IncomingMessage{Author: c, Content: json.RawMessage(`{"seq": 9}`)}); err == nil || err.Error() != InexistentStatus {
+		t.Errorf("Acknowledging an unknown status must return error '%s', got %v", InexistentStatus, err)
+	}
+
+	b.FakeStatus = []byte(`{}`)
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: messages.TypeResync})
+	if len(sent) != 3 || sent[2].Patch != nil {
+		t.Errorf("Status must be sent whole after a resync")
+	}
+}
+
+func TestGameMessagesRejected(t *testing.T) {
+	c, b, r := setup()
+	var rejections []events.Error
+	r.observer.On(events.Error{}, func(ev interface{}) {
+		rejections = append(rejections, ev.(events.Error))
+	})
+	executed := 0
+	b.FakeExecute = func(action api.Action) error {
+		executed++
+		return nil
+	}
+	b.FakeGameStarted = true
+	b.FakeActionTypes = []string{"ply"}
+	r.clients[0] = c
+	r.clients[1] = client.NewMock()
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", RequestID: "r1", Content: json.RawMessage(`{}`)})
+	r.clientsInTurn = []interfaces.Client{c}
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "xyz", RequestID: "r2", Content: json.RawMessage(`{}`)})
+
+	if executed != 0 {
+		t.Errorf("Rejected messages must not reach the game driver")
+	}
+	if len(rejections) != 2 {
+		t.Fatalf("Both messages must be rejected, got %d errors", len(rejections))
+	}
+	if rejections[0].ErrorText != NotYourTurn || rejections[0].MessageType != "ply" || rejections[0].RequestID != "r1" {
+		t.Errorf("Message out of turn must return error '%s' with its type and request identifier, got %+v", NotYourTurn, rejections[0])
+	}
+	if rejections[1].ErrorText != UnknownMessageType || rejections[1].MessageType != "xyz" || rejections[1].RequestID != "r2" {
+		t.Errorf("Message with an undeclared type must return error '%s' with its type and request identifier, got %+v", UnknownMessageType, rejections[1])
+	}
+}
+
+// legalActionsDriver is a driver mock which lists the legal actions of each player
+type legalActionsDriver struct {
+	*drivers.Mock
+	actions map[int][]api.Action
+}
+
+func (d legalActionsDriver) LegalActions(playerNumber int) ([]api.Action, error) {
+	return d.actions[playerNumber], nil
+}
+
+func TestLegalActionsSentToPlayersInTurn(t *testing.T) {
+	c, b, r := setup()
+	var sent []events.LegalActionsUpdated
+	r.observer.On(events.LegalActionsUpdated{}, func(ev interface{}) {
+		sent = append(sent, ev.(events.LegalActionsUpdated))
+	})
+	r.gameDriver = legalActionsDriver{Mock: b, actions: map[int][]api.Action{
+		0: {{Type: "ply", Params: json.RawMessage(`{"til":"5E"}`)}},
+	}}
+	other := client.NewMock()
+	r.clients[0] = c
+	r.clients[1] = other
+	r.clientsInTurn = []interfaces.Client{c}
+	b.FakeCurrentPlayersNumbers = []int{0}
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", Content: json.RawMessage(`{"til":"3A"}`)})
+
+	if len(sent) != 1 || sent[0].Client != c || sent[0].SequenceNumber != 1 {
+		t.Fatalf("Legal actions must be sent only to the player in turn, got %v", sent)
+	}
+	if len(sent[0].Actions) != 1 || sent[0].Actions[0].Type != "ply" || string(sent[0].Actions[0].Params) != `{"til":"5E"}` {
+		t.Errorf("Unexpected legal actions, got %v", sent[0].Actions)
+	}
+	if actions, err := r.LegalActions(other); err != nil || len(actions) != 0 {
+		t.Errorf("Player not in turn must have no legal actions, got %v %v", actions, err)
+	}
+}
+
+func TestUndoLastAction(t *testing.T) {
+	c, b, r := setup()
+	var votes []messages.VoteStatus
+	r.observer.On(events.VoteUpdated{}, func(ev interface{}) {
+		votes = append(votes, ev.(events.VoteUpdated).Status)
+	})
+	replayed := drivers.NewMock().(*drivers.Mock)
+	defer func(f func(string) (api.Driver, error)) { CreateDriver = f }(CreateDriver)
+	CreateDriver = func(name string) (api.Driver, error) {
+		return replayed, nil
+	}
+	opponent := client.NewMock()
+	r.clients[0] = c
+	r.clients[1] = opponent
+	r.clientsInTurn = []interfaces.Client{c}
+	b.FakeGameStarted = true
+	b.FakeCurrentPlayersNumbers = []int{0}
+	replayed.FakeGameStarted = true
+	replayed.FakeCurrentPlayersNumbers = []int{0}
+	undo := json.RawMessage(`{"knd": "und"}`)
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", Content: json.RawMessage(`{}`)})
+	r.Parse(&interfaces.IncomingMessage{Author: opponent, Type: messages.TypePropose, Content: undo})
+	if r.proposal != nil {
+		t.Fatalf("Only the author of the last action can propose to undo it")
+	}
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: messages.TypePropose, Content: undo})
+	if len(votes) != 1 || votes[0].State != messages.VoteOpen || votes[0].Required != 2 {
+		t.Fatalf("The opponent must be asked to vote the undo proposal, got %v", votes)
+	}
+	r.Parse(&interfaces.IncomingMessage{Author: opponent, Type: messages.TypeVote, Content: json.RawMessage(`{"acc": false}`)})
+	if len(votes) != 2 || votes[1].State != messages.VoteRejected || len(r.history) != 1 {
+		t.Fatalf("Rejected undo proposal must keep the action, got %v", votes)
+	}
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: messages.TypePropose, Content: undo})
+	r.Parse(&interfaces.IncomingMessage{Author: opponent, Type: messages.TypeVote, Content: json.RawMessage(`{"acc": true}`)})
+	if len(votes) != 4 || votes[3].State != messages.VoteAccepted {
+		t.Fatalf("Undo proposal must be accepted, got %v", votes)
+	}
+	if r.gameDriver != replayed || len(r.history) != 0 || r.updateSequenceNumber != 2 {
+		t.Errorf("Game must be rebuilt without the undone action, got %d changes and sequence number %d", len(r.history), r.updateSequenceNumber)
+	}
+}
+
+func TestUndoRequestMessages(t *testing.T) {
+	c, b, r := setup()
+	var requested []events.UndoRequested
+	var resolved []events.UndoResolved
+	r.observer.On(events.UndoRequested{}, func(ev interface{}) {
+		requested = append(requested, ev.(events.UndoRequested))
+	})
+	r.observer.On(events.UndoResolved{}, func(ev interface{}) {
+		resolved = append(resolved, ev.(events.UndoResolved))
+	})
+	replayed := drivers.NewMock().(*drivers.Mock)
+	defer func(f func(string) (api.Driver, error)) { CreateDriver = f }(CreateDriver)
+	CreateDriver = func(name string) (api.Driver, error) {
+		return replayed, nil
+	}
+	opponent, other := client.NewMock(), client.NewMock()
+	r.clients[0] = c
+	r.clients[1] = opponent
+	r.clients[2] = other
+	r.clientsInTurn = []interfaces.Client{c}
+	r.setVotingRules(messages.VotingRules{Majority: messages.MajoritySimple})
+	b.FakeGameStarted = true
+	b.FakeCurrentPlayersNumbers = []int{0}
+	replayed.FakeGameStarted = true
+	replayed.FakeCurrentPlayersNumbers = []int{0}
+	accept := json.RawMessage(`{"acc": true}`)
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", Content: json.RawMessage(`{}`)})
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: messages.TypeUndoRequest})
+	if len(requested) != 1 || len(requested[0].Voters) != 2 || requested[0].PlayerNumber != 0 {
+		t.Fatalf("The other human players must be asked to vote the undo request, got %v", requested)
+	}
+	r.Parse(&interfaces.IncomingMessage{Author: opponent, Type: messages.TypeUndoVote, Content: accept})
+	if len(resolved) != 0 || len(r.history) != 1 {
+		t.Fatalf("Undo requests must be accepted by all the other human players, whatever the room's majority, got %v", resolved)
+	}
+	r.Parse(&interfaces.IncomingMessage{Author: other, Type: messages.TypeUndoVote, Content: accept})
+	if len(resolved) != 1 || !resolved[0].Accepted {
+		t.Fatalf("Accepted undo request must be resolved as accepted, got %v", resolved)
+	}
+	if r.gameDriver != replayed || len(r.history) != 0 {
+		t.Errorf("Game must be rebuilt without the undone action, got %d changes", len(r.history))
+	}
+}
+
+func TestUndoThroughDriver(t *testing.T) {
+	c, _, r := setup()
+	undoer := drivers.NewUndoerMock().(*drivers.UndoerMock)
+	undoer.FakeGameStarted = true
+	undoer.FakeCurrentPlayersNumbers = []int{0}
+	r.gameDriver = undoer
+	opponent := client.NewMock()
+	r.clients[0] = c
+	r.clients[1] = opponent
+	r.clientsInTurn = []interfaces.Client{c}
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", Content: json.RawMessage(`{}`)})
+	if r.history[0].before != nil {
+		t.Errorf("Statuses must not be kept if the driver can undo actions")
+	}
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: messages.TypePropose, Content: json.RawMessage(`{"knd": "und"}`)})
+	r.Parse(&interfaces.IncomingMessage{Author: opponent, Type: messages.TypeVote, Content: json.RawMessage(`{"acc": true}`)})
+
+	if undoer.Calls["Undo"] != 1 || r.gameDriver != undoer || len(r.history) != 0 {
+		t.Errorf("Driver must undo the last action, got %d calls and %d changes", undoer.Calls["Undo"], len(r.history))
+	}
+}
+
+func TestHistoryKeepsStatusesOfLatestChange(t *testing.T) {
+	c, b, r := setup()
+	r.clients[0] = c
+	r.clientsInTurn = []interfaces.Client{c}
+	b.FakeGameStarted = true
+	b.FakeCurrentPlayersNumbers = []int{0}
+
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", Content: json.RawMessage(`{}`)})
+	r.Parse(&interfaces.IncomingMessage{Author: c, Type: "ply", Content: json.RawMessage(`{}`)})
+
+	if len(r.history) != 2 || r.history[0].before != nil || r.history[1].before == nil {
+		t.Errorf("Statuses before a change must only be kept for the latest one, got %v", r.history)
+	}
+}
+
+func TestVotes(t *testing.T) {
+	c, b, r := setup()
+	var votes []messages.VoteStatus
+	r.observer.On(events.VoteUpdated{}, func(ev interface{}) {
+		votes = append(votes, ev.(events.VoteUpdated).Status)
+	})
+	var rejections []events.Error
+	r.observer.On(events.Error{}, func(ev interface{}) {
+		rejections = append(rejections, ev.(events.Error))
+	})
+	aborted := 0
+	r.observer.On(events.GameAborted{}, func(interface{}) {
+		aborted++
+	})
+	var results []messages.PlayerResult
+	r.observer.On(events.GameEnded{}, func(ev interface{}) {
+		results = ev.(events.GameEnded).Results
+	})
+	a, d, target := client.NewMock(), client.NewMock(), client.NewMock()
+	r.clients[0] = c
+	r.clients[1] = a
+	r.clients[2] = d
+	r.clients[3] = target
+	r.clientsInTurn = []interfaces.Client{a}
+	r.seats = r.seatsData()
+	b.FakeGameStarted = true
+	propose := func(author interfaces.Client, content string) {
+		r.Parse(&interfaces.IncomingMessage{Author: author, Type: messages.TypePropose, Content: json.RawMessage(content)})
+	}
+	vote := func(author interfaces.Client, accept string) {
+		r.Parse(&interfaces.IncomingMessage{Author: author, Type: messages.TypeVote, Content: json.RawMessage(`{"acc": ` + accept + `}`)})
+	}
+
+	if err := r.setVotingRules(messages.VotingRules{Majority: "xyz"}); err == nil || err.Error() != InvalidVotingRules {
+		t.Errorf("Unknown majorities must return error '%s', got %v", InvalidVotingRules, err)
+	}
+	r.setVotingRules(messages.VotingRules{Majority: messages.MajoritySimple})
+	propose(a, `{"knd": "kck", "ply": 3}`)
+	if r.proposal == nil || r.proposal.required != 2 || votes[0].Player != 3 || votes[0].Proposer != 1 {
+		t.Fatalf("Kick proposal must need 2 of the 3 remaining players, got %v", votes)
+	}
+	vote(target, "false")
+	propose(c, `{"knd": "drw"}`)
+	if len(rejections) != 2 || rejections[0].ErrorText != Forbidden || rejections[1].ErrorText != VotePending {
+		t.Fatalf("Player to be kicked can't vote, and only one proposal can be pending, got %v", rejections)
+	}
+	vote(d, "true")
+	if _, seated := r.clients[3]; seated || r.proposal != nil || votes[len(votes)-1].State != messages.VoteAccepted {
+		t.Fatalf("Kick proposal accepted by the majority must remove the player, got %v", votes)
+	}
+
+	r.setVotingRules(messages.VotingRules{})
+	propose(c, `{"knd": "pau"}`)
+	vote(a, "true")
+	vote(d, "true")
+	r.Parse(&interfaces.IncomingMessage{Author: a, Type: "ply", Content: json.RawMessage(`{}`)})
+	if !r.paused || rejections[len(rejections)-1].ErrorText != GamePaused {
+		t.Fatalf("Game messages must be rejected while the game is paused, got %v", rejections)
+	}
+
+	propose(c, `{"knd": "res"}`)
+	vote(a, "true")
+	r.proposal.deadline = time.Now().Add(time.Minute)
+	r.Parse(&interfaces.IncomingMessage{Author: a, Type: messages.TypeVoteExpired})
+	if r.proposal == nil {
+		t.Fatalf("Proposal must not expire before its deadline")
+	}
+	r.proposal.deadline = time.Now()
+	r.Parse(&interfaces.IncomingMessage{Author: a, Type: messages.TypeVoteExpired})
+	if !r.paused || r.proposal != nil || votes[len(votes)-1].State != messages.VoteExpired {
+		t.Fatalf("Proposal without enough votes must expire, got %v", votes)
+	}
+
+	propose(c, `{"knd": "drw"}`)
+	vote(a, "false")
+	if r.IsGameOver() || votes[len(votes)-1].State != messages.VoteRejected {
+		t.Fatalf("Unanimous proposal rejected by a player must not be carried out, got %v", votes)
+	}
+	propose(a, `{"knd": "abt"}`)
+	vote(c, "true")
+	vote(d, "true")
+	if aborted != 1 {
+		t.Errorf("Accepted abort proposal must abort the game")
+	}
+
+	propose(c, `{"knd": "drw"}`)
+	vote(a, "true")
+	vote(d, "true")
+	if !r.IsGameOver() || len(results) != 4 || results[0].Rank != 1 || results[3].Rank != 1 {
+		t.Errorf("Accepted draw proposal must end the game with all players ranked first, got %v", results)
+	}
+}
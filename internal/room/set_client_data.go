@@ -2,6 +2,7 @@ package room
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/svera/sackson-server/internal/events"
 	"github.com/svera/sackson-server/internal/interfaces"
@@ -12,10 +13,19 @@ func (r *Room) setClientDataAction(m *interfaces.IncomingMessage) error {
 	var parsed messages.SetClientDataParams
 	var err error
 
+	// Authenticated users keep the name given by the authenticator
+	if m.Author.UserID() != "" {
+		return errors.New(NameNotEditable)
+	}
 	if err = json.Unmarshal(m.Content, &parsed); err != nil {
 		return err
 	}
-	m.Author.SetName(parsed.Name)
+	name, err := r.names.Check(parsed.Name)
+	if err != nil {
+		return err
+	}
+	m.Author.SetName(name)
+	r.playerNames[m.Author] = r.uniqueName(m.Author, name)
 	r.observer.Trigger(events.ClientsUpdated{Clients: mapToSlice(r.clients), PlayersData: r.playersData()})
 
 	return nil
@@ -3,7 +3,6 @@ package room
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"time"
 
 	"github.com/svera/sackson-server/internal/events"
@@ -23,10 +22,30 @@ func (r *Room) startGameAction(m *interfaces.IncomingMessage) error {
 		return err
 	}
 	r.playerTimeOut = parsed.PlayerTimeout
+	if r.async && parsed.TurnDeadline > 0 {
+		// Player timeouts are expressed in seconds
+		r.playerTimeOut = parsed.TurnDeadline * time.Hour / time.Second
+	}
+
+	if err = r.setVotingRules(parsed.Voting); err != nil {
+		return err
+	}
 
-	if err = r.gameDriver.StartGame(r.mapPlayerNames()); err != nil {
+	players := r.mapPlayerNames()
+	if err = r.gameDriver.StartGame(players); err != nil {
 		return err
 	}
+	r.startingPlayers = players
+	r.history = nil
+	r.cancelProposal()
+	r.paused = false
+	r.drawn = false
+	r.seats = r.seatsData()
+	r.statusesMutex.Lock()
+	r.statuses = map[interfaces.Client]*statusTracker{}
+	r.statusesMutex.Unlock()
+	r.gameStartedAt = time.Now()
+	r.actionsCount = 0
 
 	if err = r.sendInitialMessage(); err != nil {
 		return err
@@ -35,6 +54,7 @@ func (r *Room) startGameAction(m *interfaces.IncomingMessage) error {
 	r.changeClientsInTurn()
 
 	r.observer.Trigger(events.GameStarted{Room: r, GameParameters: m.Content})
+	r.sendLegalActions(r.clientsInTurn...)
 	return err
 }
 
@@ -48,7 +68,7 @@ func (r *Room) sendInitialMessage() error {
 			return err
 		}
 		r.setUpTimeOut(cl)
-		r.observer.Trigger(events.GameStatusUpdated{Client: cl, Message: status, SequenceNumber: r.updateSequenceNumber})
+		r.sendStatus(cl, status, "")
 	}
 	return nil
 }
@@ -57,9 +77,7 @@ func (r *Room) sendInitialMessage() error {
 func (r *Room) setUpTimeOut(cl interfaces.Client) {
 	if r.playerTimeOut > 0 && !cl.IsBot() {
 		cl.SetTimer(time.AfterFunc(time.Second*r.playerTimeOut, func() {
-			if r.configuration.Debug {
-				log.Printf("Client '%s' timed out", cl.Name())
-			}
+			r.log.Info("Client timed out", "client", cl.Name())
 			r.timeoutPlayer(cl)
 		}))
 	}
@@ -73,7 +91,7 @@ func (r *Room) timeoutPlayer(cl interfaces.Client) {
 func (r *Room) mapPlayerNames() map[int]string {
 	names := map[int]string{}
 	for n, cl := range r.clients {
-		names[n] = cl.Name()
+		names[n] = r.playerName(cl)
 	}
 	return names
 }
@@ -0,0 +1,143 @@
+package room
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/svera/sackson-server/api"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// change is a modification of the game state made since it started,
+// kept so the game can be rebuilt without it
+type change struct {
+	apply func(api.Driver) error
+	// author is the client who played the action, nil for changes not made by players
+	author interfaces.Client
+	// before holds the encoded statuses of all players before the change,
+	// to check that a rebuilt game is the same it was. It is only kept for the latest change,
+	// and is nil if the driver can undo actions.
+	before map[int][]byte
+}
+
+// statusesBeforeChange returns the encoded statuses of all players,
+// if they are needed to undo the next change
+func (r *Room) statusesBeforeChange() map[int][]byte {
+	if _, ok := r.gameDriver.(api.Undoer); ok {
+		return nil
+	}
+	return encodedStatuses(r.gameDriver, r.clients)
+}
+
+// recordChange adds a change of the game to its history, cancelling any pending undo proposal
+// as the last action is not the same anymore
+func (r *Room) recordChange(author interfaces.Client, before map[int][]byte, apply func(api.Driver) error) {
+	if n := len(r.history); n > 0 {
+		r.history[n-1].before = nil
+	}
+	r.history = append(r.history, change{apply: apply, author: author, before: before})
+	if r.proposal != nil && r.proposal.kind == messages.ProposalUndo {
+		r.cancelProposal()
+	}
+}
+
+// acceptUndo undoes the last action, notifying players whether it could be done,
+// and sends the resulting game status to all of them
+func (r *Room) acceptUndo() error {
+	err := r.undoLastAction()
+	r.observer.Trigger(events.UndoResolved{Clients: r.HumanClients(), Accepted: err == nil})
+	if err != nil {
+		return err
+	}
+	r.broadcastStatuses()
+	if r.turnMovedToNewPlayers() {
+		r.changeClientsInTurn()
+	}
+	r.sendLegalActions(r.clientsInTurn...)
+	return nil
+}
+
+// undoLastAction puts the game back in the state it had before the last change,
+// either through the driver or replaying the previous changes in a new game
+func (r *Room) undoLastAction() error {
+	last := r.history[len(r.history)-1]
+	if undoer, ok := r.gameDriver.(api.Undoer); ok {
+		if err := undoer.Undo(); err != nil {
+			return err
+		}
+	} else {
+		driver, err := r.replay(r.history[:len(r.history)-1])
+		if err != nil {
+			return err
+		}
+		// Games with random elements take a different course when replayed
+		if !r.sameAsBefore(driver, last) {
+			r.log.Warn("Replayed game differs from the original one")
+			return errors.New(UndoUnsupported)
+		}
+		r.gameDriver = driver
+	}
+	r.history = r.history[:len(r.history)-1]
+	r.actionsCount--
+	r.log.Debug("Action undone", "client", last.author.Name())
+	return nil
+}
+
+// replay returns a new instance of the room's game driver with the passed changes applied
+func (r *Room) replay(changes []change) (api.Driver, error) {
+	driver, err := CreateDriver(r.gameDriver.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err = driver.StartGame(r.startingPlayers); err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if err = c.apply(driver); err != nil {
+			return nil, err
+		}
+	}
+	return driver, nil
+}
+
+// sameAsBefore returns true if the passed driver, rebuilt without the last change,
+// is in the same state the game was before it. The statuses before a change are only kept
+// for the latest one, so once it has been undone, replaying all the remaining changes
+// must give the current game instead.
+func (r *Room) sameAsBefore(rebuilt api.Driver, last change) bool {
+	if last.before != nil {
+		return sameStatuses(encodedStatuses(rebuilt, r.clients), last.before)
+	}
+	current, err := r.replay(r.history)
+	if err != nil {
+		return false
+	}
+	return sameStatuses(encodedStatuses(current, r.clients), encodedStatuses(r.gameDriver, r.clients))
+}
+
+func encodedStatuses(driver api.Driver, clients map[int]interfaces.Client) map[int][]byte {
+	statuses := make(map[int][]byte, len(clients))
+	for n := range clients {
+		st, err := driver.Status(n)
+		if err != nil {
+			continue
+		}
+		statuses[n], _ = json.Marshal(st)
+	}
+	return statuses
+}
+
+func sameStatuses(a, b map[int][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n, st := range a {
+		if !bytes.Equal(st, b[n]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,382 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/svera/sackson-server/internal/client"
+	"github.com/svera/sackson-server/internal/events"
+	"github.com/svera/sackson-server/internal/interfaces"
+	"github.com/svera/sackson-server/internal/messages"
+)
+
+// proposal is a change of the room put to the vote of its human players
+type proposal struct {
+	kind     string
+	proposer interfaces.Client
+	// target is the player to be kicked, for kick proposals
+	target interfaces.Client
+	// numbers holds the player numbers of the clients in the room when the proposal was made
+	numbers map[interfaces.Client]int
+	// voters are the players who can vote the proposal, proposer included
+	voters []interfaces.Client
+	// votes holds the votes cast so far
+	votes map[interfaces.Client]bool
+	// majority is the share of voters who have to accept the proposal
+	majority string
+	required int
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// favour returns the number of votes in favour of the proposal
+func (p *proposal) favour() int {
+	n := 0
+	for _, accepted := range p.votes {
+		if accepted {
+			n++
+		}
+	}
+	return n
+}
+
+// rejected returns true if the proposal can't get enough votes anymore
+func (p *proposal) rejected() bool {
+	return len(p.voters)-len(p.votes)+p.favour() < p.required
+}
+
+func (p *proposal) isVoter(c interfaces.Client) bool {
+	for _, cl := range p.voters {
+		if cl == c {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredVotes returns the number of votes in favour needed to carry out a proposal
+func requiredVotes(majority string, voters int) int {
+	switch majority {
+	case messages.MajoritySimple:
+		return voters/2 + 1
+	case messages.MajorityTwoThirds:
+		return (2*voters + 2) / 3
+	}
+	return voters
+}
+
+// setVotingRules sets how the room's proposals are voted, using the server's default timeout if none is passed
+func (r *Room) setVotingRules(rules messages.VotingRules) error {
+	switch rules.Majority {
+	case "", messages.MajorityUnanimous, messages.MajoritySimple, messages.MajorityTwoThirds:
+	default:
+		return errors.New(InvalidVotingRules)
+	}
+	if rules.Timeout < 0 {
+		return errors.New(InvalidVotingRules)
+	}
+	r.voteMajority = rules.Majority
+	r.voteTimeout = rules.Timeout
+	if r.voteTimeout == 0 {
+		r.voteTimeout = r.configuration.VoteTimeout
+	}
+	return nil
+}
+
+func (r *Room) proposeAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.Propose
+
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	return r.propose(m.Author, parsed)
+}
+
+// propose puts the passed proposal made by author to the vote. Undo proposals
+// need all the other human players to accept them, whatever the room's voting rules are.
+func (r *Room) propose(author interfaces.Client, parsed messages.Propose) error {
+	if !r.GameStarted() {
+		return errors.New(GameNotStarted)
+	}
+	if r.IsGameOver() {
+		return errors.New(GameOver)
+	}
+	if r.proposal != nil {
+		return errors.New(VotePending)
+	}
+
+	p := &proposal{
+		kind:     parsed.Kind,
+		proposer: author,
+		numbers:  map[interfaces.Client]int{},
+		votes:    map[interfaces.Client]bool{author: true},
+		majority: r.voteMajority,
+	}
+	switch parsed.Kind {
+	case messages.ProposalAbort, messages.ProposalDraw:
+	case messages.ProposalKick:
+		target, exist := r.clients[parsed.PlayerNumber]
+		if !exist {
+			return errors.New(InexistentClient)
+		}
+		if target == r.owner {
+			return errors.New(OwnerNotRemovable)
+		}
+		if target == author {
+			return errors.New(Forbidden)
+		}
+		p.target = target
+	case messages.ProposalPause:
+		if r.paused {
+			return errors.New(GamePaused)
+		}
+	case messages.ProposalResume:
+		if !r.paused {
+			return errors.New(GameNotPaused)
+		}
+	case messages.ProposalUndo:
+		if len(r.history) == 0 {
+			return errors.New(NothingToUndo)
+		}
+		if r.history[len(r.history)-1].author != author {
+			return errors.New(UndoNotAllowed)
+		}
+		p.majority = messages.MajorityUnanimous
+	default:
+		return errors.New(UnknownProposal)
+	}
+
+	for n, cl := range r.clients {
+		p.numbers[cl] = n
+		// Disconnected players in asynchronous rooms can't vote
+		if _, offline := cl.(*client.Offline); !cl.IsBot() && !offline && cl != p.target {
+			p.voters = append(p.voters, cl)
+		}
+	}
+	p.required = requiredVotes(p.majority, len(p.voters))
+	r.log.Debug("Proposal made", "client", author.Name(), "kind", p.kind)
+
+	if p.favour() >= p.required {
+		return r.carryOut(p)
+	}
+	r.proposal = p
+	if r.voteTimeout > 0 {
+		p.deadline = time.Now().Add(time.Second * r.voteTimeout)
+		// The proposal is resolved from the hub's goroutine, as any other change of the room
+		proposer := p.proposer
+		p.timer = time.AfterFunc(time.Second*r.voteTimeout, func() {
+			r.messages <- &interfaces.IncomingMessage{Author: proposer, Type: messages.TypeVoteExpired}
+		})
+	}
+	r.notifyVote(p, messages.VoteOpen)
+	if p.kind == messages.ProposalUndo {
+		others := []interfaces.Client{}
+		for _, cl := range p.voters {
+			if cl != author {
+				others = append(others, cl)
+			}
+		}
+		r.observer.Trigger(events.UndoRequested{Client: author, PlayerNumber: p.numbers[author], Name: r.playerName(author), Voters: others})
+	}
+	return nil
+}
+
+func (r *Room) voteAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.Vote
+
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if r.proposal == nil {
+		return errors.New(NoVotePending)
+	}
+	return r.vote(m.Author, parsed.Accept)
+}
+
+// undoVoteAction votes the pending proposal, which must be an undo one
+func (r *Room) undoVoteAction(m *interfaces.IncomingMessage) error {
+	var parsed messages.UndoVote
+
+	if err := json.Unmarshal(m.Content, &parsed); err != nil {
+		return err
+	}
+	if r.proposal == nil || r.proposal.kind != messages.ProposalUndo {
+		return errors.New(NoUndoPending)
+	}
+	return r.vote(m.Author, parsed.Accept)
+}
+
+func (r *Room) vote(voter interfaces.Client, accept bool) error {
+	p := r.proposal
+	if !p.isVoter(voter) {
+		return errors.New(Forbidden)
+	}
+	if _, voted := p.votes[voter]; voted {
+		return errors.New(AlreadyVoted)
+	}
+	p.votes[voter] = accept
+	return r.countVotes(p)
+}
+
+// countVotes carries out or rejects the pending proposal once its result is known
+func (r *Room) countVotes(p *proposal) error {
+	if p.favour() >= p.required {
+		return r.carryOut(p)
+	}
+	if p.rejected() {
+		r.closeProposal(p, messages.VoteRejected)
+		return nil
+	}
+	r.notifyVote(p, messages.VoteOpen)
+	return nil
+}
+
+// voteExpiredAction expires the pending proposal if its voting time is up.
+// Messages received before that are ignored, as any client could send them.
+func (r *Room) voteExpiredAction() {
+	p := r.proposal
+	if p == nil || p.deadline.IsZero() || time.Now().Before(p.deadline) {
+		return
+	}
+	r.expireProposal(p)
+}
+
+// expireProposal resolves a proposal when its voting time is up. Proposals
+// are carried out as soon as enough players accept them, so expired ones never are.
+func (r *Room) expireProposal(p *proposal) {
+	if r.proposal != p {
+		return
+	}
+	r.closeProposal(p, messages.VoteExpired)
+}
+
+// clientLeft updates the pending proposal when a client leaves the room,
+// cancelling it if the client was the one to be kicked
+func (r *Room) clientLeft(c interfaces.Client) {
+	if r.proposal != nil && c == r.proposal.target {
+		r.closeProposal(r.proposal, messages.VoteCancelled)
+		return
+	}
+	r.voterLeft(c)
+}
+
+// voterLeft removes a player who can't vote anymore from the pending proposal,
+// cancelling it if the player was its proposer
+func (r *Room) voterLeft(c interfaces.Client) {
+	p := r.proposal
+	if p == nil || !p.isVoter(c) {
+		return
+	}
+	if c == p.proposer || r.toBeDestroyed {
+		r.closeProposal(p, messages.VoteCancelled)
+		return
+	}
+	for i, cl := range p.voters {
+		if cl == c {
+			p.voters = append(p.voters[:i], p.voters[i+1:]...)
+			break
+		}
+	}
+	delete(p.votes, c)
+	p.required = requiredVotes(p.majority, len(p.voters))
+	if err := r.countVotes(p); err != nil {
+		r.log.Warn("Couldn't carry out proposal", "kind", p.kind, "error", err)
+	}
+}
+
+// cancelProposal cancels the pending proposal, if any
+func (r *Room) cancelProposal() {
+	if r.proposal != nil {
+		r.closeProposal(r.proposal, messages.VoteCancelled)
+	}
+}
+
+// closeProposal resolves a proposal, notifying players of its final state
+func (r *Room) closeProposal(p *proposal, state string) {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if r.proposal == p {
+		r.proposal = nil
+	}
+	r.log.Debug("Proposal resolved", "kind", p.kind, "state", state)
+	r.notifyVote(p, state)
+	// Accepted undo requests are resolved once the action is undone, see acceptUndo
+	if p.kind == messages.ProposalUndo && state != messages.VoteAccepted {
+		r.observer.Trigger(events.UndoResolved{Clients: r.HumanClients(), Accepted: false})
+	}
+}
+
+// carryOut resolves a proposal as accepted and executes it
+func (r *Room) carryOut(p *proposal) error {
+	var err error
+
+	r.closeProposal(p, messages.VoteAccepted)
+	switch p.kind {
+	case messages.ProposalAbort:
+		r.observer.Trigger(events.GameAborted{Room: r})
+	case messages.ProposalKick:
+		err = r.kickClient(p.numbers[p.target])
+	case messages.ProposalDraw:
+		r.endInDraw()
+	case messages.ProposalPause:
+		r.pause()
+	case messages.ProposalResume:
+		r.resume()
+	case messages.ProposalUndo:
+		err = r.acceptUndo()
+	}
+	if err != nil {
+		r.notifyVote(p, messages.VoteFailed)
+	}
+	return err
+}
+
+func (r *Room) notifyVote(p *proposal, state string) {
+	status := messages.VoteStatus{
+		Kind:     p.kind,
+		Player:   -1,
+		Proposer: p.numbers[p.proposer],
+		Votes:    make(map[string]bool, len(p.votes)),
+		Voters:   len(p.voters),
+		Required: p.required,
+		State:    state,
+	}
+	if p.target != nil {
+		status.Player = p.numbers[p.target]
+	}
+	for cl, accepted := range p.votes {
+		status.Votes[strconv.Itoa(p.numbers[cl])] = accepted
+	}
+	if state == messages.VoteOpen && !p.deadline.IsZero() {
+		status.Timeout = time.Until(p.deadline) / time.Second
+	}
+	r.observer.Trigger(events.VoteUpdated{Clients: r.HumanClients(), Status: status})
+}
+
+// endInDraw ends the game with all its players sharing the first place
+func (r *Room) endInDraw() {
+	for _, cl := range r.clientsInTurn {
+		cl.StopTimer()
+	}
+	r.drawn = true
+	r.checkGameOver()
+}
+
+// pause stops the game, rejecting game messages and the timers of the players in turn until it is resumed
+func (r *Room) pause() {
+	for _, cl := range r.clientsInTurn {
+		cl.StopTimer()
+	}
+	r.turnDeadline = time.Time{}
+	r.paused = true
+}
+
+// resume restarts a paused game, sending its status again so bots in turn play
+func (r *Room) resume() {
+	r.paused = false
+	r.startClientsInTurnTimers()
+	r.broadcastStatuses()
+}
@@ -0,0 +1,90 @@
+// Package snapshot persists the state of the games still running when the server
+// shuts down, for game drivers which are able to serialize it.
+package snapshot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Player holds the data of a player seated in a room when its snapshot was taken
+type Player struct {
+	Number    int    `json:"number"`
+	Name      string `json:"name"`
+	Bot       bool   `json:"bot"`
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// Snapshot holds the state of a room's game
+type Snapshot struct {
+	RoomID  string    `json:"room_id"`
+	Driver  string    `json:"driver"`
+	Async   bool      `json:"async"`
+	TakenAt time.Time `json:"taken_at"`
+	Players []Player  `json:"players"`
+	// State is the game state as serialized by its driver
+	State []byte `json:"state"`
+}
+
+// Store defines the methods a snapshot storage must implement
+type Store interface {
+	Save(s Snapshot) error
+}
+
+// FileStore is a Store implementation that writes every snapshot
+// to its own JSON file in a directory, named after its room
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a new FileStore instance which writes to dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes the snapshot, replacing any previous one of the same room.
+// A temporary file is used so snapshots are never left half written.
+func (s *FileStore) Save(sn Snapshot) error {
+	data, err := json.Marshal(sn)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(s.dir, sn.RoomID)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path(sn.RoomID))
+}
+
+// Load returns the snapshot of the room with the passed ID
+func (s *FileStore) Load(roomID string) (Snapshot, error) {
+	var sn Snapshot
+	data, err := ioutil.ReadFile(s.Path(roomID))
+	if err != nil {
+		return sn, err
+	}
+	err = json.Unmarshal(data, &sn)
+	return sn, err
+}
+
+// Path returns the path of the file holding the snapshot of the room with the passed ID
+func (s *FileStore) Path(roomID string) string {
+	return filepath.Join(s.dir, roomID+".json")
+}
@@ -0,0 +1,46 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSavedSnapshotCanBeLoaded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sn := Snapshot{
+		RoomID:  "VWXYZ",
+		Driver:  "acquire",
+		TakenAt: time.Now().UTC().Truncate(time.Second),
+		Players: []Player{{Number: 0, Name: "Sergio"}, {Number: 1, Name: "Bot 1", Bot: true}},
+		State:   []byte(`{"turn": 3}`),
+	}
+	if err = s.Save(sn); err != nil {
+		t.Fatalf("Save must not return an error, got %s", err.Error())
+	}
+	sn.State = []byte(`{"turn": 4}`)
+	if err = s.Save(sn); err != nil {
+		t.Fatalf("Save must not return an error, got %s", err.Error())
+	}
+
+	loaded, err := s.Load("VWXYZ")
+	if err != nil {
+		t.Fatalf("Load must not return an error, got %s", err.Error())
+	}
+	if loaded.Driver != "acquire" || len(loaded.Players) != 2 || string(loaded.State) != `{"turn": 4}` || !loaded.TakenAt.Equal(sn.TakenAt) {
+		t.Errorf("Loaded snapshot must be the last one saved, got %+v", loaded)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Store must keep one file per room, got %d", len(files))
+	}
+}
@@ -0,0 +1,33 @@
+package tournament
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/svera/sackson-server/internal/httpjson"
+)
+
+// RegisterRoutes adds the tournament endpoints to the passed router:
+//   GET /tournaments returns all tournaments
+//   GET /tournaments/{id} returns the tournament with the passed ID
+func RegisterRoutes(r *mux.Router, m *Manager) {
+	r.HandleFunc("/tournaments", listHandler(m)).Methods(http.MethodGet)
+	r.HandleFunc("/tournaments/{id}", getHandler(m)).Methods(http.MethodGet)
+}
+
+func listHandler(m *Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpjson.Write(w, m.List())
+	}
+}
+
+func getHandler(m *Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := m.Get(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Tournament not found", http.StatusNotFound)
+			return
+		}
+		httpjson.Write(w, t)
+	}
+}
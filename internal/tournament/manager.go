@@ -0,0 +1,267 @@
+package tournament
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Error messages returned from the tournament manager
+const (
+	InexistentTournament = "inexistent_tournament"
+	InvalidFormat        = "invalid_tournament_format"
+	InvalidTableSize     = "invalid_table_size"
+	InvalidRounds        = "invalid_rounds"
+	InvalidAdvance       = "invalid_advance"
+	AlreadyStarted       = "tournament_already_started"
+	AlreadyRegistered    = "already_registered"
+	NameTaken            = "name_taken"
+	NotEnoughPlayers     = "not_enough_players"
+	InexistentTable      = "inexistent_table"
+	TableAlreadyPlayed   = "table_already_played"
+	NotRunning           = "tournament_not_running"
+	Forbidden            = "forbidden"
+)
+
+// tableRef locates a table inside a tournament
+type tableRef struct {
+	tournament *Tournament
+	round      int
+	table      *Table
+	// ended is true once the game of the table has ended, even if its results are not recorded yet
+	ended bool
+}
+
+// Manager holds all tournaments, and is safe to be used concurrently.
+// All tournaments returned by its methods are copies, so any change made to them
+// doesn't affect the managed ones.
+type Manager struct {
+	mutex       sync.RWMutex
+	tournaments map[string]*Tournament
+	// tables holds the tables being played, indexed by the ID of their rooms
+	tables     map[string]*tableRef
+	generateID func() string
+}
+
+// NewManager returns a new Manager instance, which will use generateID
+// to get an identifier for every new tournament
+func NewManager(generateID func() string) *Manager {
+	return &Manager{
+		tournaments: map[string]*Tournament{},
+		tables:      map[string]*tableRef{},
+		generateID:  generateID,
+	}
+}
+
+// Create adds a new tournament with the passed settings, open for registration.
+// The organizer is identified by organizerID, organizer being his/her name.
+func (m *Manager) Create(organizerID string, organizer string, s Settings) (*Tournament, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if s.Format == Elimination && s.Advance == 0 {
+		s.Advance = 1
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	id := m.generateID()
+	for _, exists := m.tournaments[id]; exists; _, exists = m.tournaments[id] {
+		id = m.generateID()
+	}
+	t := &Tournament{
+		ID:          id,
+		Organizer:   organizer,
+		OrganizerID: organizerID,
+		Settings:    s,
+		State:       Registering,
+		Players:     []*Player{},
+		Rounds:      []*Round{},
+	}
+	m.tournaments[id] = t
+	return t.clone(), nil
+}
+
+func (s Settings) validate() error {
+	if s.Format != Swiss && s.Format != Elimination {
+		return errors.New(InvalidFormat)
+	}
+	if s.TableSize < 2 {
+		return errors.New(InvalidTableSize)
+	}
+	if s.Format == Swiss && s.Rounds < 1 {
+		return errors.New(InvalidRounds)
+	}
+	if s.Format == Elimination && (s.Advance < 0 || s.Advance >= s.TableSize) {
+		return errors.New(InvalidAdvance)
+	}
+	return nil
+}
+
+// Join registers the player with the passed ID and name in a tournament.
+// Players who are already registered can join again once the tournament has started,
+// for example after reconnecting.
+func (m *Manager) Join(id string, playerID string, name string) (*Tournament, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return nil, errors.New(InexistentTournament)
+	}
+	registered := t.PlayerByID(playerID) != nil
+	if t.State != Registering {
+		if registered && t.State == Running {
+			return t.clone(), nil
+		}
+		return nil, errors.New(AlreadyStarted)
+	}
+	if registered {
+		return nil, errors.New(AlreadyRegistered)
+	}
+	if t.Player(name) != nil {
+		return nil, errors.New(NameTaken)
+	}
+	t.Players = append(t.Players, &Player{ID: playerID, Name: name})
+	return t.clone(), nil
+}
+
+// Start closes registration and pairs the players for the first round.
+// Only the tournament organizer, identified by organizerID, can start it.
+func (m *Manager) Start(id string, organizerID string) (*Tournament, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return nil, errors.New(InexistentTournament)
+	}
+	if t.OrganizerID != organizerID {
+		return nil, errors.New(Forbidden)
+	}
+	if t.State != Registering {
+		return nil, errors.New(AlreadyStarted)
+	}
+	if len(t.Players) < 2 {
+		return nil, errors.New(NotEnoughPlayers)
+	}
+	t.State = Running
+	t.next()
+	return t.clone(), nil
+}
+
+// SetTableRoom links a table of the current round of a tournament with the room where it is played
+func (m *Manager) SetTableRoom(id string, tableNumber int, roomID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return errors.New(InexistentTournament)
+	}
+	for _, table := range t.CurrentRound().Tables {
+		if table.Number == tableNumber {
+			table.RoomID = roomID
+			m.tables[roomID] = &tableRef{tournament: t, round: t.CurrentRound().Number, table: table}
+			return nil
+		}
+	}
+	return errors.New(InexistentTable)
+}
+
+// EndTable marks the game of the table played in the room with the passed ID as ended,
+// returning the tournament ID. ok is false if the room is not being used by any tournament
+// or its game had already ended, so the results of every table are recorded only once.
+func (m *Manager) EndTable(roomID string) (id string, ok bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ref, ok := m.tables[roomID]
+	if !ok || ref.ended {
+		return "", false
+	}
+	ref.ended = true
+	return ref.tournament.ID, true
+}
+
+// Record stores the results of the table played in the room with the passed ID.
+// If that finishes the round, the next one is paired, or the tournament finished
+// if there are no more rounds to play, and roundFinished is returned as true.
+func (m *Manager) Record(roomID string, results []Result) (t *Tournament, roundFinished bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ref, ok := m.tables[roomID]
+	if !ok {
+		return nil, false, errors.New(InexistentTable)
+	}
+	delete(m.tables, roomID)
+	return ref.tournament.recordTable(ref.round, ref.table, results)
+}
+
+// RecordForfeit stores the results of a table which couldn't be played in a room,
+// in the passed round of a tournament. See Record.
+func (m *Manager) RecordForfeit(id string, round int, tableNumber int, results []Result) (t *Tournament, roundFinished bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tr, ok := m.tournaments[id]
+	if !ok {
+		return nil, false, errors.New(InexistentTournament)
+	}
+	if round < 1 || round > len(tr.Rounds) {
+		return nil, false, errors.New(InexistentTable)
+	}
+	for _, table := range tr.Rounds[round-1].Tables {
+		if table.Number == tableNumber {
+			return tr.recordTable(round, table, results)
+		}
+	}
+	return nil, false, errors.New(InexistentTable)
+}
+
+// recordTable stores the results of a table of the passed round, pairing the next round
+// if that finishes the current one. Tables of previous rounds, or already played,
+// are not recorded again.
+func (t *Tournament) recordTable(round int, table *Table, results []Result) (*Tournament, bool, error) {
+	if t.State != Running {
+		return nil, false, errors.New(NotRunning)
+	}
+	if table.Done || round != t.CurrentRound().Number {
+		return nil, false, errors.New(TableAlreadyPlayed)
+	}
+
+	t.record(table, results)
+	if !t.roundFinished() {
+		return t.clone(), false, nil
+	}
+	t.next()
+	return t.clone(), true, nil
+}
+
+// Get returns the tournament with the passed ID
+func (m *Manager) Get(id string) (*Tournament, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return nil, errors.New(InexistentTournament)
+	}
+	return t.clone(), nil
+}
+
+// List returns all tournaments, ordered by ID
+func (m *Manager) List() []*Tournament {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	list := make([]*Tournament, 0, len(m.tournaments))
+	for _, t := range m.tournaments {
+		list = append(list, t.clone())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
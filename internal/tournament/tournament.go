@@ -0,0 +1,328 @@
+// Package tournament implements the logic needed to run tournaments,
+// in Swiss or elimination formats, with tables of any number of players.
+// It manages registration, pairings and standings, leaving the creation of the rooms
+// where each table is played to its users.
+package tournament
+
+import (
+	"sort"
+	"time"
+)
+
+// Tournament formats
+const (
+	// Swiss tournaments are played for a fixed number of rounds, players with
+	// similar standings sharing table in each one
+	Swiss = "swiss"
+
+	// Elimination tournaments are played until there's only one table left,
+	// only the best players of each table advancing to the next round
+	Elimination = "elimination"
+)
+
+// Tournament states
+const (
+	Registering = "registering"
+	Running     = "running"
+	Finished    = "finished"
+)
+
+// Settings holds the parameters of a tournament, defined by its organizer
+type Settings struct {
+	Name   string `json:"name"`
+	Driver string `json:"driver"`
+	Format string `json:"format"`
+	// TableSize is the maximum number of players per table
+	TableSize int `json:"table_size"`
+	// Rounds is the number of rounds to be played in Swiss tournaments
+	Rounds int `json:"rounds,omitempty"`
+	// Advance is the number of players of each table that advance to the next round
+	// in elimination tournaments
+	Advance int `json:"advance,omitempty"`
+	// PlayerTimeout is the time in seconds each player has per turn (0 for no timeout)
+	PlayerTimeout time.Duration `json:"player_timeout"`
+}
+
+// Player holds the data and accumulated results of a tournament participant.
+// Participants are identified by ID, which is never made public, and also by name
+// in tables and standings, so names are unique inside a tournament.
+type Player struct {
+	ID   string `json:"-"`
+	Name string `json:"name"`
+	// Points are awarded for every table played, one for each player at the table
+	// who ended in a worse position, and half a point for each one tied
+	Points     float64 `json:"points"`
+	Score      int     `json:"score"`
+	Games      int     `json:"games"`
+	Byes       int     `json:"byes"`
+	Eliminated bool    `json:"eliminated"`
+}
+
+// Table holds the players that play a game in a round, and its result once finished
+type Table struct {
+	Number  int      `json:"number"`
+	Players []string `json:"players"`
+	RoomID  string   `json:"room_id"`
+	Done    bool     `json:"done"`
+	// Ranks of the players at the table, indexed by player name
+	Ranks map[string]int `json:"ranks,omitempty"`
+	// Scores of the players at the table, indexed by player name
+	Scores map[string]int `json:"scores,omitempty"`
+}
+
+// Round holds the tables played in a tournament round
+type Round struct {
+	Number int      `json:"number"`
+	Tables []*Table `json:"tables"`
+	// Byes holds the players who didn't have to play this round
+	Byes []string `json:"byes,omitempty"`
+}
+
+// Tournament holds the state of a tournament
+type Tournament struct {
+	ID          string    `json:"id"`
+	Organizer   string    `json:"organizer"`
+	OrganizerID string    `json:"-"`
+	Settings    Settings  `json:"settings"`
+	State       string    `json:"state"`
+	Players     []*Player `json:"players"`
+	Rounds      []*Round  `json:"rounds"`
+	Winner      string    `json:"winner,omitempty"`
+}
+
+// Result holds the final rank and score of a player in a table's game
+type Result struct {
+	Player string
+	Rank   int
+	Score  int
+}
+
+// CurrentRound returns the round being played, or nil if the tournament hasn't started yet
+func (t *Tournament) CurrentRound() *Round {
+	if len(t.Rounds) == 0 {
+		return nil
+	}
+	return t.Rounds[len(t.Rounds)-1]
+}
+
+// Player returns the participant with the passed name, or nil if there's none
+func (t *Tournament) Player(name string) *Player {
+	for _, p := range t.Players {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// PlayerByID returns the participant with the passed ID, or nil if there's none
+func (t *Tournament) PlayerByID(id string) *Player {
+	for _, p := range t.Players {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Standings returns the tournament players ordered by their position:
+// players still in competition first, then by points and score
+func (t *Tournament) Standings() []*Player {
+	standings := make([]*Player, len(t.Players))
+	copy(standings, t.Players)
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Eliminated != b.Eliminated {
+			return !a.Eliminated
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Score > b.Score
+	})
+	return standings
+}
+
+// record updates the table and its players with the passed results.
+// Players without a result, or with rank 0, are considered to have ended last.
+func (t *Tournament) record(table *Table, results []Result) {
+	ranks := map[string]int{}
+	scores := map[string]int{}
+	for _, r := range results {
+		if r.Rank > 0 {
+			ranks[r.Player] = r.Rank
+		}
+		scores[r.Player] = r.Score
+	}
+	for _, name := range table.Players {
+		if _, ok := ranks[name]; !ok {
+			ranks[name] = len(table.Players) + 1
+		}
+	}
+
+	table.Done = true
+	table.Ranks = ranks
+	table.Scores = map[string]int{}
+	for _, name := range table.Players {
+		p := t.Player(name)
+		p.Games++
+		p.Score += scores[name]
+		table.Scores[name] = scores[name]
+		for _, other := range table.Players {
+			if other == name {
+				continue
+			}
+			if ranks[name] < ranks[other] {
+				p.Points++
+			} else if ranks[name] == ranks[other] {
+				p.Points += 0.5
+			}
+		}
+	}
+
+	if t.Settings.Format == Elimination {
+		t.eliminate(table)
+	}
+}
+
+// eliminate marks as eliminated the players of the table who don't advance to the next round.
+// At least one player is eliminated from each table, even if all of them are tied,
+// so elimination tournaments always come to an end.
+func (t *Tournament) eliminate(table *Table) {
+	advance := t.Settings.Advance
+	if advance >= len(table.Players) {
+		advance = len(table.Players) - 1
+	}
+	for i, name := range table.order() {
+		if i >= advance {
+			t.Player(name).Eliminated = true
+		}
+	}
+}
+
+// order returns the players of a played table from best to worst. Players with the same
+// rank are ordered by their score at the table, and then by their seat.
+func (table *Table) order() []string {
+	ordered := append([]string{}, table.Players...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if table.Ranks[a] != table.Ranks[b] {
+			return table.Ranks[a] < table.Ranks[b]
+		}
+		return table.Scores[a] > table.Scores[b]
+	})
+	return ordered
+}
+
+// roundFinished returns true if all tables of the current round have been played
+func (t *Tournament) roundFinished() bool {
+	for _, table := range t.CurrentRound().Tables {
+		if !table.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// next starts a new round with the active players or finishes the tournament
+// if all its rounds have been played
+func (t *Tournament) next() {
+	active := []string{}
+	for _, p := range t.Standings() {
+		if !p.Eliminated {
+			active = append(active, p.Name)
+		}
+	}
+
+	current := t.CurrentRound()
+	switch {
+	case t.Settings.Format == Swiss && len(t.Rounds) >= t.Settings.Rounds:
+		t.finish(t.Standings()[0].Name)
+	case t.Settings.Format == Elimination && current != nil && len(current.Tables) == 1 && len(current.Byes) == 0:
+		t.finish(winner(current.Tables[0]))
+	case len(active) == 0:
+		t.finish("")
+	case len(active) == 1:
+		t.finish(active[0])
+	default:
+		t.Rounds = append(t.Rounds, t.pair(len(t.Rounds)+1, active))
+	}
+}
+
+func (t *Tournament) finish(winner string) {
+	t.State = Finished
+	t.Winner = winner
+}
+
+// pair distributes the passed players, ordered by standings, in as few tables as possible,
+// keeping tables as even as possible. Consecutive players in standings share table.
+// A player left alone in a table gets a bye, counted as a win.
+func (t *Tournament) pair(number int, players []string) *Round {
+	round := &Round{Number: number}
+	numberTables := (len(players) + t.Settings.TableSize - 1) / t.Settings.TableSize
+	base := len(players) / numberTables
+	extra := len(players) % numberTables
+
+	start := 0
+	for i := 0; i < numberTables; i++ {
+		size := base
+		if i < extra {
+			size++
+		}
+		seated := players[start : start+size]
+		start += size
+		if size == 1 {
+			p := t.Player(seated[0])
+			p.Byes++
+			p.Points += float64(t.Settings.TableSize - 1)
+			round.Byes = append(round.Byes, seated[0])
+			continue
+		}
+		round.Tables = append(round.Tables, &Table{
+			Number:  len(round.Tables) + 1,
+			Players: append([]string{}, seated...),
+		})
+	}
+	return round
+}
+
+// winner returns the best ranked player of a table
+func winner(table *Table) string {
+	return table.order()[0]
+}
+
+// clone returns a deep copy of the tournament, which can be safely used
+// while the original one keeps being updated
+func (t *Tournament) clone() *Tournament {
+	c := *t
+	c.Players = make([]*Player, len(t.Players))
+	for i, p := range t.Players {
+		player := *p
+		c.Players[i] = &player
+	}
+	c.Rounds = make([]*Round, len(t.Rounds))
+	for i, r := range t.Rounds {
+		round := *r
+		round.Byes = append([]string{}, r.Byes...)
+		round.Tables = make([]*Table, len(r.Tables))
+		for j, tb := range r.Tables {
+			table := *tb
+			table.Players = append([]string{}, tb.Players...)
+			if tb.Ranks != nil {
+				table.Ranks = make(map[string]int, len(tb.Ranks))
+				for name, rank := range tb.Ranks {
+					table.Ranks[name] = rank
+				}
+			}
+			if tb.Scores != nil {
+				table.Scores = make(map[string]int, len(tb.Scores))
+				for name, score := range tb.Scores {
+					table.Scores[name] = score
+				}
+			}
+			round.Tables[j] = &table
+		}
+		c.Rounds[i] = &round
+	}
+	return &c
+}
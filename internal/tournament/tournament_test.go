@@ -0,0 +1,175 @@
+package tournament
+
+import (
+	"fmt"
+	"testing"
+)
+
+func setup(s Settings, players ...string) (*Manager, *Tournament) {
+	counter := 0
+	m := NewManager(func() string {
+		counter++
+		return fmt.Sprintf("T%d", counter)
+	})
+	t, _ := m.Create("organizer", "Organizer", s)
+	for _, p := range players {
+		m.Join(t.ID, "id-"+p, p)
+	}
+	t, _ = m.Start(t.ID, "organizer")
+	return m, t
+}
+
+// playRound records every table of the current round, ranking players in the order they are seated
+func playRound(m *Manager, t *Tournament) *Tournament {
+	round := t.CurrentRound().Number
+	for _, table := range t.CurrentRound().Tables {
+		results := []Result{}
+		for i, p := range table.Players {
+			results = append(results, Result{Player: p, Rank: i + 1})
+		}
+		roomID := fmt.Sprintf("R%d-%d", round, table.Number)
+		m.SetTableRoom(t.ID, table.Number, roomID)
+		t, _, _ = m.Record(roomID, results)
+	}
+	return t
+}
+
+func TestCreateValidatesSettings(t *testing.T) {
+	m := NewManager(func() string { return "T" })
+	invalid := []Settings{
+		{Format: "league", TableSize: 4, Rounds: 1},
+		{Format: Swiss, TableSize: 1, Rounds: 1},
+		{Format: Swiss, TableSize: 4},
+		{Format: Elimination, TableSize: 4, Advance: 4},
+	}
+	for _, s := range invalid {
+		if _, err := m.Create("organizer", "Organizer", s); err == nil {
+			t.Errorf("Create must return an error with settings %v", s)
+		}
+	}
+}
+
+func TestOnlyOrganizerCanStart(t *testing.T) {
+	m := NewManager(func() string { return "T" })
+	tr, _ := m.Create("organizer", "Organizer", Settings{Format: Swiss, TableSize: 2, Rounds: 1})
+	m.Join(tr.ID, "sergio", "Sergio")
+	m.Join(tr.ID, "miguel", "Miguel")
+
+	if _, err := m.Start(tr.ID, "sergio"); err == nil || err.Error() != Forbidden {
+		t.Errorf("Only the organizer must be able to start a tournament")
+	}
+	if _, err := m.Join(tr.ID, "impostor", "Sergio"); err == nil || err.Error() != NameTaken {
+		t.Errorf("Players must not be able to join with the name of another participant")
+	}
+}
+
+func TestTablesAreRecordedOnce(t *testing.T) {
+	m, tr := setup(Settings{Format: Swiss, TableSize: 2, Rounds: 2}, "A", "B", "C", "D")
+	m.SetTableRoom(tr.ID, 1, "R1")
+	m.SetTableRoom(tr.ID, 2, "R2")
+
+	if id, ok := m.EndTable("R1"); !ok || id != tr.ID {
+		t.Fatalf("Ending a tournament table must return its tournament")
+	}
+	if _, ok := m.EndTable("R1"); ok {
+		t.Errorf("A table must only be ended once")
+	}
+	m.Record("R1", []Result{{Player: "A", Rank: 1}, {Player: "B", Rank: 2}})
+	tr, _, _ = m.Record("R2", []Result{{Player: "C", Rank: 1}, {Player: "D", Rank: 2}})
+	if _, _, err := m.Record("R1", nil); err == nil {
+		t.Errorf("Results of a table cannot be recorded twice")
+	}
+	if _, _, err := m.RecordForfeit(tr.ID, 1, 1, nil); err == nil || err.Error() != TableAlreadyPlayed {
+		t.Errorf("Results of a table of a previous round cannot be recorded")
+	}
+	for _, table := range tr.CurrentRound().Tables {
+		if table.Done {
+			t.Errorf("Tables of the new round must not be played yet, got %v", table)
+		}
+	}
+}
+
+func TestPairingKeepsTablesEven(t *testing.T) {
+	_, tr := setup(Settings{Format: Swiss, TableSize: 4, Rounds: 1}, "A", "B", "C", "D", "E", "F", "G")
+
+	tables := tr.CurrentRound().Tables
+	if len(tables) != 2 || len(tables[0].Players) != 4 || len(tables[1].Players) != 3 {
+		t.Errorf("7 players must be seated in tables of 4 and 3 players")
+	}
+}
+
+func TestPairingGivesByes(t *testing.T) {
+	_, tr := setup(Settings{Format: Swiss, TableSize: 2, Rounds: 1}, "A", "B", "C")
+
+	round := tr.CurrentRound()
+	if len(round.Tables) != 1 || len(round.Byes) != 1 || tr.Player(round.Byes[0]).Points != 1 {
+		t.Errorf("Player left alone must get a bye counted as a win, got %v", round)
+	}
+}
+
+func TestSwissTournament(t *testing.T) {
+	m, tr := setup(Settings{Format: Swiss, TableSize: 2, Rounds: 2}, "A", "B", "C", "D")
+
+	tr = playRound(m, tr)
+	if tr.State != Running || len(tr.Rounds) != 2 {
+		t.Fatalf("Second round must be paired after the first one finishes")
+	}
+	if tables := tr.CurrentRound().Tables; tables[0].Players[0] != "A" || tables[0].Players[1] != "C" {
+		t.Errorf("Winners of the first round must share table in the second one, got %v", tables[0].Players)
+	}
+
+	tr = playRound(m, tr)
+	if tr.State != Finished || tr.Winner != "A" {
+		t.Errorf("Tournament must be finished and won by A, got state %s and winner %s", tr.State, tr.Winner)
+	}
+}
+
+func TestEliminationTournament(t *testing.T) {
+	m, tr := setup(Settings{Format: Elimination, TableSize: 2}, "A", "B", "C", "D")
+
+	tr = playRound(m, tr)
+	if len(tr.CurrentRound().Tables) != 1 {
+		t.Fatalf("Only winners must play the final")
+	}
+	for _, p := range []string{"B", "D"} {
+		if !tr.Player(p).Eliminated {
+			t.Errorf("Player %s must be eliminated", p)
+		}
+	}
+
+	tr = playRound(m, tr)
+	if tr.State != Finished || tr.Winner != "A" {
+		t.Errorf("Tournament must be finished and won by A, got state %s and winner %s", tr.State, tr.Winner)
+	}
+}
+
+func TestEliminationTiesAreBroken(t *testing.T) {
+	m, tr := setup(Settings{Format: Elimination, TableSize: 2}, "A", "B", "C", "D")
+
+	for round := 1; tr.State == Running; round++ {
+		if round > 2 {
+			t.Fatalf("Tied tables must eliminate players, got %d rounds", len(tr.Rounds))
+		}
+		for _, table := range tr.CurrentRound().Tables {
+			results := []Result{{Player: table.Players[0], Rank: 1}, {Player: table.Players[1], Rank: 1, Score: 10}}
+			roomID := fmt.Sprintf("R%d-%d", round, table.Number)
+			m.SetTableRoom(tr.ID, table.Number, roomID)
+			tr, _, _ = m.Record(roomID, results)
+		}
+	}
+	if tr.Winner != "D" {
+		t.Errorf("Ties must be broken by score, got winner %s", tr.Winner)
+	}
+}
+
+func TestMissingResultsRankLast(t *testing.T) {
+	m, tr := setup(Settings{Format: Elimination, TableSize: 3}, "A", "B", "C")
+
+	tr, _, _ = m.RecordForfeit(tr.ID, 1, 1, []Result{{Player: "B", Rank: 1}})
+	if tr.State != Finished || tr.Winner != "B" {
+		t.Errorf("Players without results must rank last, got winner %s", tr.Winner)
+	}
+	if _, _, err := m.RecordForfeit(tr.ID, 1, 1, nil); err == nil {
+		t.Errorf("Results of a table cannot be recorded twice")
+	}
+}
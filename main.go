@@ -1,8 +1,13 @@
 package main
 
 import (
-	"log"
+	"context"
+	"net"
 	"net/http"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/svera/sackson-server/internal/drivers"
 
@@ -10,16 +15,30 @@ import (
 	"os"
 
 	"github.com/gorilla/mux"
+	"github.com/svera/sackson-server/internal/admin"
+	"github.com/svera/sackson-server/internal/auth"
+	"github.com/svera/sackson-server/internal/ban"
 	"github.com/svera/sackson-server/internal/client"
 	"github.com/svera/sackson-server/internal/config"
+	"github.com/svera/sackson-server/internal/history"
 	"github.com/svera/sackson-server/internal/hub"
+	"github.com/svera/sackson-server/internal/logger"
+	"github.com/svera/sackson-server/internal/messages"
+	"github.com/svera/sackson-server/internal/metrics"
+	"github.com/svera/sackson-server/internal/notify"
+	"github.com/svera/sackson-server/internal/rating"
+	"github.com/svera/sackson-server/internal/snapshot"
+	"github.com/svera/sackson-server/internal/tournament"
 	"github.com/svera/sackson-server/observer"
 )
 
 var (
-	hb      *hub.Hub
-	cfg     *config.Config
-	gitHash = "No git hash provided"
+	hb            *hub.Hub
+	cfg           *config.Config
+	authenticator auth.Authenticator
+	httpLog       *logger.Logger
+	clientLog     *logger.Logger
+	gitHash       = "No git hash provided"
 )
 
 func main() {
@@ -31,24 +50,116 @@ func main() {
 	if cfg, err = config.Load(f); err != nil {
 		fmt.Println(err.Error())
 	} else {
+		// Options are already validated when loading the configuration
+		opts, _ := cfg.LogOptions()
+		lg := logger.New(os.Stdout, opts)
+		httpLog = lg.Subsystem("http")
+		clientLog = lg.Subsystem("client")
+
 		r := mux.NewRouter()
 		obs := observer.New()
-		hb = hub.New(cfg, obs)
-		drivers.Load()
+		hb = hub.New(cfg, obs, lg)
+
+		if cfg.HistoryFile != "" {
+			store, err := history.NewFileStore(cfg.HistoryFile)
+			if err != nil {
+				lg.Error("Couldn't open match history file", "file", cfg.HistoryFile, "error", err)
+				return
+			}
+			history.RegisterEvents(obs, store, lg.Subsystem("history"))
+			history.RegisterRoutes(r, store)
+		}
+
+		if cfg.RatingsFile != "" {
+			store, err := rating.NewFileStore(cfg.RatingsFile)
+			if err != nil {
+				lg.Error("Couldn't open ratings file", "file", cfg.RatingsFile, "error", err)
+				return
+			}
+			hb.Ratings = store
+			rating.RegisterEvents(obs, store, cfg.RatingIncludeBotGames, lg.Subsystem("rating"))
+			rating.RegisterRoutes(r, store)
+		}
+
+		switch cfg.Notifier {
+		case "log":
+			notify.RegisterEvents(obs, notify.NewLogNotifier(lg.Subsystem("notify")), lg.Subsystem("notify"))
+		case "webhook":
+			notify.RegisterEvents(obs, notify.NewWebhookNotifier(cfg.NotifierWebhookURL), lg.Subsystem("notify"))
+		}
+
+		if cfg.SnapshotsDir != "" {
+			store, err := snapshot.NewFileStore(cfg.SnapshotsDir)
+			if err != nil {
+				lg.Error("Couldn't open snapshots directory", "dir", cfg.SnapshotsDir, "error", err)
+				return
+			}
+			hb.Snapshots = store
+		}
+
+		if cfg.BansFile != "" {
+			list, err := ban.NewFileList(cfg.BansFile)
+			if err != nil {
+				lg.Error("Couldn't open bans file", "file", cfg.BansFile, "error", err)
+				return
+			}
+			hb.BanList = list
+		}
+
+		if cfg.AuthSecret != "" {
+			authenticator = auth.NewJWT(cfg.AuthSecret)
+		}
+
+		drivers.Load(lg.Subsystem("drivers"))
 		go hb.Run()
 
+		tournament.RegisterRoutes(r, hb.Tournaments)
+		if cfg.AdminToken != "" {
+			admin.RegisterRoutes(r, hb, cfg.AdminToken)
+		}
+		if cfg.Metrics {
+			hb.RegisterMetrics(metrics.Default)
+			r.Handle("/metrics", metrics.Default.Handler()).Methods(http.MethodGet)
+		}
 		r.HandleFunc("/", newClient)
-		fmt.Printf("Sackson server listening on port %s\n", cfg.Port)
-		fmt.Printf("Git commit hash: %s\n", gitHash)
+		lg.Info("Sackson server listening", "port", cfg.Port, "commit", gitHash)
 
+		srv := &http.Server{Addr: cfg.Port, Handler: r}
+		go shutdownOnSignal(srv, lg)
 		if cfg.Secure {
-			log.Fatal(http.ListenAndServeTLS(cfg.Port, cfg.SecureCertFileName, cfg.SecureKeyFileName, r))
+			err = srv.ListenAndServeTLS(cfg.SecureCertFileName, cfg.SecureKeyFileName)
 		} else {
-			log.Fatal(http.ListenAndServe(cfg.Port, r))
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			lg.Error("Server stopped", "error", err)
+			os.Exit(1)
 		}
+		// Wait for the shutdown to finish, which exits the process
+		select {}
 	}
 }
 
+// shutdownOnSignal waits for a termination signal, then stops accepting connections
+// and shuts the hub down, letting running games finish and notifying clients
+func shutdownOnSignal(srv *http.Server, lg *logger.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	lg.Info("Termination signal received", "signal", sig.String())
+
+	// Websocket connections are hijacked, so they are not affected by this
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		lg.Warn("Error stopping HTTP server", "error", err)
+	}
+	hb.Shutdown(time.Second * cfg.ShutdownDrainTimeout)
+	// Gives write pumps some time to send the pending messages and close frames
+	time.Sleep(time.Second)
+	os.Exit(0)
+}
+
 func newClient(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -57,21 +168,58 @@ func newClient(w http.ResponseWriter, r *http.Request) {
 
 	gameDriverName := r.FormValue("g")
 	if !drivers.Exist(gameDriverName) {
-		if cfg.Debug {
-			log.Printf("Tried connection to non-existent game driver: %s\n", gameDriverName)
-		}
+		httpLog.Debug("Tried connection to non-existent game driver", "driver", gameDriverName)
 		http.Error(w, "Game not found", http.StatusNotFound)
 		return
 	}
-	if c, err := client.NewHuman(w, r, cfg); err == nil {
+
+	// Clients which don't pass the protocol version they use can declare it later with a hello message
+	var version int
+	if v := r.FormValue("v"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || !messages.IsSupportedProtocolVersion(version) {
+			httpLog.Debug("Tried connection with an unsupported protocol version", "version", v)
+			http.Error(w, "Unsupported protocol version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Authentication must happen before upgrading the connection,
+	// so unauthorized users get a proper HTTP error
+	var user auth.User
+	if authenticator != nil {
+		token := auth.TokenFromRequest(r)
+		if token != "" || cfg.AuthRequired {
+			var err error
+			if user, err = authenticator.Authenticate(token); err != nil {
+				httpLog.Info("Authentication failed", "error", err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+	}
+
+	// Clients pass their previous session identifier to get back to asynchronous rooms
+	sessionID := r.FormValue("s")
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if hb.BanList.Banned(user.ID, sessionID, host) {
+		httpLog.Info("Banned client refused", "address", host, "user", user.ID)
+		http.Error(w, hub.Banned, http.StatusForbidden)
+		return
+	}
+
+	if c, err := client.NewHuman(w, r, cfg, clientLog); err == nil {
 		c.SetGame(gameDriverName)
+		c.SetUserID(user.ID)
+		c.SetName(user.Name)
+		c.SetSessionID(sessionID)
+		c.SetProtocolVersion(version)
+		c.SetAddress(host)
 		hb.Register <- c
 		go c.WritePump()
 		c.ReadPump(hb.Messages, hb.Unregister)
 	} else {
-		if cfg.Debug {
-			log.Println(err.Error())
-		}
+		httpLog.Debug("Couldn't open websocket connection", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}